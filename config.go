@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"futures-arbitrage-scanner/exchanges"
+)
+
+// Duration is a time.Duration that reads from JSON strings like "10s" or "200ms"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string like \"10s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ConnectorConfig holds the per-connector settings
+type ConnectorConfig struct {
+	// Enabled defaults to true when omitted
+	Enabled  *bool  `json:"enabled,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// StaleTimeout overrides the global stale_timeout for this connector
+	StaleTimeout Duration `json:"stale_timeout,omitempty"`
+	// Depth overrides the global depth for this connector
+	Depth int `json:"depth,omitempty"`
+}
+
+// ThresholdConfig controls when arbitrage alerts are sent
+type ThresholdConfig struct {
+	MinProfitPct  float64  `json:"min_profit_pct"`
+	AlertCooldown Duration `json:"alert_cooldown"`
+}
+
+// BroadcastConfig controls how often snapshots are pushed to browser clients
+type BroadcastConfig struct {
+	PricesInterval Duration `json:"prices_interval"`
+	StatusInterval Duration `json:"status_interval"`
+}
+
+// MetadataConfig controls loading of instrument specs (tick, lot and
+// contract sizes) from the venue REST APIs at startup
+type MetadataConfig struct {
+	// CacheFile keeps the last fetched specs for offline runs; empty disables it
+	CacheFile string   `json:"cache_file"`
+	Timeout   Duration `json:"timeout"`
+}
+
+// Config is the scanner configuration loaded from a JSON file with
+// environment variable overrides
+type Config struct {
+	Port       string                     `json:"port"`
+	Symbols    []string                   `json:"symbols"`
+	Connectors map[string]ConnectorConfig `json:"connectors"`
+	Thresholds ThresholdConfig            `json:"thresholds"`
+	Broadcast  BroadcastConfig            `json:"broadcast"`
+	Metadata   MetadataConfig             `json:"metadata"`
+	// StaleTimeout is how long a feed may go without messages before it is
+	// marked stale and reconnected
+	StaleTimeout Duration `json:"stale_timeout"`
+	// Depth is how many book levels per side connectors attach to orderbook
+	// updates; 0 sends only the top of book
+	Depth int `json:"depth"`
+
+	// enabledOverride is set from the CONNECTORS environment variable and
+	// replaces the enabled flags from the file
+	enabledOverride []string
+}
+
+// DefaultConfig returns the built-in configuration used when no file is given
+func DefaultConfig() *Config {
+	return &Config{
+		Port:         "8082",
+		Symbols:      []string{"BTCUSDT", "ETHUSDT", "XRPUSDT", "SOLUSDT"},
+		Connectors:   make(map[string]ConnectorConfig),
+		StaleTimeout: Duration(exchanges.DefaultStaleTimeout),
+		Thresholds: ThresholdConfig{
+			MinProfitPct:  0.05,
+			AlertCooldown: Duration(10 * time.Second),
+		},
+		Broadcast: BroadcastConfig{
+			PricesInterval: Duration(200 * time.Millisecond),
+			StatusInterval: Duration(time.Second),
+		},
+		Metadata: MetadataConfig{
+			CacheFile: "instruments.json",
+			Timeout:   Duration(10 * time.Second),
+		},
+	}
+}
+
+// LoadConfig reads the config file at path (if non-empty) on top of the
+// defaults, applies environment overrides and validates the result
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config: %w", err)
+		}
+		// Unknown keys are rejected so a misspelled setting fails at
+		// startup instead of silently falling back to the default
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("parsing config %s: %w", path, err)
+		}
+		if cfg.Connectors == nil {
+			cfg.Connectors = make(map[string]ConnectorConfig)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// applyEnv overrides config values from environment variables
+func (c *Config) applyEnv() error {
+	if port := os.Getenv("PORT"); port != "" {
+		c.Port = port
+	}
+
+	if symbols := os.Getenv("SYMBOLS"); symbols != "" {
+		c.Symbols = splitList(symbols)
+	}
+
+	if connectors := os.Getenv("CONNECTORS"); connectors != "" {
+		c.enabledOverride = splitList(connectors)
+	}
+
+	if value := os.Getenv("MIN_PROFIT_PCT"); value != "" {
+		minProfit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("MIN_PROFIT_PCT: %w", err)
+		}
+		c.Thresholds.MinProfitPct = minProfit
+	}
+
+	if value := os.Getenv("ALERT_COOLDOWN"); value != "" {
+		cooldown, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("ALERT_COOLDOWN: %w", err)
+		}
+		c.Thresholds.AlertCooldown = Duration(cooldown)
+	}
+
+	if value := os.Getenv("PRICES_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("PRICES_INTERVAL: %w", err)
+		}
+		c.Broadcast.PricesInterval = Duration(interval)
+	}
+
+	if value := os.Getenv("STALE_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("STALE_TIMEOUT: %w", err)
+		}
+		c.StaleTimeout = Duration(timeout)
+	}
+
+	return nil
+}
+
+// Validate checks the config for values the scanner cannot run with
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Port == "" {
+		errs = append(errs, errors.New("port must be set"))
+	}
+
+	if len(c.Symbols) == 0 {
+		errs = append(errs, errors.New("at least one symbol is required"))
+	}
+	seen := make(map[string]bool)
+	for i, symbol := range c.Symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol == "" {
+			errs = append(errs, fmt.Errorf("symbols[%d] is empty", i))
+			continue
+		}
+		if seen[symbol] {
+			errs = append(errs, fmt.Errorf("symbol %s listed twice", symbol))
+		}
+		seen[symbol] = true
+		c.Symbols[i] = symbol
+	}
+
+	for name, connector := range c.Connectors {
+		if _, ok := exchanges.Lookup(name); !ok {
+			errs = append(errs, fmt.Errorf("unknown connector %q", name))
+			continue
+		}
+		if connector.Endpoint != "" {
+			endpoint, err := url.Parse(connector.Endpoint)
+			if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+				errs = append(errs, fmt.Errorf("connector %s: invalid endpoint %q", name, connector.Endpoint))
+			}
+		}
+		if connector.StaleTimeout < 0 {
+			errs = append(errs, fmt.Errorf("connector %s: stale_timeout must not be negative", name))
+		}
+		if connector.Depth < 0 {
+			errs = append(errs, fmt.Errorf("connector %s: depth must not be negative", name))
+		}
+	}
+
+	for _, name := range c.enabledOverride {
+		if _, ok := exchanges.Lookup(name); !ok {
+			errs = append(errs, fmt.Errorf("CONNECTORS: unknown connector %q", name))
+		}
+	}
+
+	if c.Thresholds.MinProfitPct <= 0 {
+		errs = append(errs, errors.New("thresholds.min_profit_pct must be positive"))
+	}
+	if c.Thresholds.AlertCooldown < 0 {
+		errs = append(errs, errors.New("thresholds.alert_cooldown must not be negative"))
+	}
+	if c.StaleTimeout <= 0 {
+		errs = append(errs, errors.New("stale_timeout must be positive"))
+	}
+	if c.Depth < 0 {
+		errs = append(errs, errors.New("depth must not be negative"))
+	}
+	if c.Broadcast.PricesInterval <= 0 {
+		errs = append(errs, errors.New("broadcast.prices_interval must be positive"))
+	}
+	if c.Broadcast.StatusInterval <= 0 {
+		errs = append(errs, errors.New("broadcast.status_interval must be positive"))
+	}
+	if c.Metadata.Timeout <= 0 {
+		errs = append(errs, errors.New("metadata.timeout must be positive"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// EnabledConnectors returns the names of the connectors to start, sorted by name
+func (c *Config) EnabledConnectors() []string {
+	if len(c.enabledOverride) > 0 {
+		return c.enabledOverride
+	}
+
+	var names []string
+	for _, name := range exchanges.Names() {
+		connector, configured := c.Connectors[name]
+		if configured && connector.Enabled != nil && !*connector.Enabled {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// ConnectorOptions returns the runtime options for the named connector
+func (c *Config) ConnectorOptions(name string) exchanges.ConnectorOptions {
+	connector := c.Connectors[name]
+
+	staleTimeout := c.StaleTimeout
+	if connector.StaleTimeout > 0 {
+		staleTimeout = connector.StaleTimeout
+	}
+
+	depth := c.Depth
+	if connector.Depth > 0 {
+		depth = connector.Depth
+	}
+
+	return exchanges.ConnectorOptions{
+		Endpoint:     connector.Endpoint,
+		StaleTimeout: time.Duration(staleTimeout),
+		Depth:        depth,
+	}
+}
+
+// splitList splits a comma separated environment value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configEnv lists the environment variables LoadConfig reads
+var configEnv = []string{"PORT", "SYMBOLS", "CONNECTORS", "MIN_PROFIT_PCT", "ALERT_COOLDOWN", "PRICES_INTERVAL", "STALE_TIMEOUT"}
+
+// clearConfigEnv blanks the overrides for the test, since LoadConfig ignores
+// empty values
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range configEnv {
+		t.Setenv(name, "")
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	clearConfigEnv(t)
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if want := DefaultConfig(); !reflect.DeepEqual(cfg, want) {
+		t.Errorf("LoadConfig(\"\") = %+v, want the defaults %+v", cfg, want)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	clearConfigEnv(t)
+
+	path := writeConfig(t, `{
+		"symbols": [" btcusdt ", "ETHUSDT"],
+		"connectors": {
+			"okx_futures": {"enabled": false},
+			"binance_futures": {"endpoint": "wss://example.com/ws"}
+		},
+		"thresholds": {"min_profit_pct": 0.2}
+	}`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	if want := []string{"BTCUSDT", "ETHUSDT"}; !reflect.DeepEqual(cfg.Symbols, want) {
+		t.Errorf("Symbols = %v, want %v", cfg.Symbols, want)
+	}
+	if cfg.Thresholds.MinProfitPct != 0.2 {
+		t.Errorf("MinProfitPct = %v, want 0.2", cfg.Thresholds.MinProfitPct)
+	}
+	// Keys missing from the file keep their defaults
+	if cfg.Port != "8082" || cfg.Thresholds.AlertCooldown != Duration(10*time.Second) {
+		t.Errorf("defaults not kept: port %q, alert_cooldown %v", cfg.Port, time.Duration(cfg.Thresholds.AlertCooldown))
+	}
+	if got := cfg.Connectors["binance_futures"].Endpoint; got != "wss://example.com/ws" {
+		t.Errorf("binance_futures endpoint = %q", got)
+	}
+	for _, name := range cfg.EnabledConnectors() {
+		if name == "okx_futures" {
+			t.Error("disabled okx_futures is enabled")
+		}
+	}
+}
+
+// The example config must keep loading under the strict decoder
+func TestLoadShippedConfig(t *testing.T) {
+	clearConfigEnv(t)
+	if _, err := LoadConfig("config.json"); err != nil {
+		t.Fatalf("config.json: %v", err)
+	}
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("PORT", "9000")
+	t.Setenv("SYMBOLS", "solusdt, xrpusdt,")
+	t.Setenv("CONNECTORS", "okx_futures,binance_futures")
+	t.Setenv("MIN_PROFIT_PCT", "0.5")
+	t.Setenv("ALERT_COOLDOWN", "1m")
+	t.Setenv("PRICES_INTERVAL", "1s")
+	t.Setenv("STALE_TIMEOUT", "45s")
+
+	// The environment wins over the file
+	path := writeConfig(t, `{"port": "8000", "symbols": ["BTCUSDT"]}`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	if cfg.Port != "9000" {
+		t.Errorf("Port = %q, want 9000", cfg.Port)
+	}
+	if want := []string{"SOLUSDT", "XRPUSDT"}; !reflect.DeepEqual(cfg.Symbols, want) {
+		t.Errorf("Symbols = %v, want %v", cfg.Symbols, want)
+	}
+	if want := []string{"okx_futures", "binance_futures"}; !reflect.DeepEqual(cfg.EnabledConnectors(), want) {
+		t.Errorf("EnabledConnectors = %v, want %v", cfg.EnabledConnectors(), want)
+	}
+	if cfg.Thresholds.MinProfitPct != 0.5 {
+		t.Errorf("MinProfitPct = %v, want 0.5", cfg.Thresholds.MinProfitPct)
+	}
+	if cfg.Thresholds.AlertCooldown != Duration(time.Minute) {
+		t.Errorf("AlertCooldown = %v, want 1m", time.Duration(cfg.Thresholds.AlertCooldown))
+	}
+	if cfg.Broadcast.PricesInterval != Duration(time.Second) {
+		t.Errorf("PricesInterval = %v, want 1s", time.Duration(cfg.Broadcast.PricesInterval))
+	}
+	if cfg.StaleTimeout != Duration(45*time.Second) {
+		t.Errorf("StaleTimeout = %v, want 45s", time.Duration(cfg.StaleTimeout))
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		env     map[string]string
+		wantErr string
+	}{
+		{"unknown key", `{"symbol": ["BTCUSDT"]}`, nil, `unknown field "symbol"`},
+		{"unknown connector key", `{"connectors": {"okx_futures": {"endpont": "wss://example.com"}}}`, nil, `unknown field "endpont"`},
+		{"malformed json", `{"port": }`, nil, "parsing config"},
+		{"bad duration", `{"thresholds": {"alert_cooldown": 10}}`, nil, "duration must be a string"},
+		{"no symbols", `{"symbols": []}`, nil, "at least one symbol is required"},
+		{"empty symbol", `{"symbols": ["BTCUSDT", " "]}`, nil, "symbols[1] is empty"},
+		{"duplicate symbol", `{"symbols": ["BTCUSDT", "btcusdt"]}`, nil, "symbol BTCUSDT listed twice"},
+		{"unknown connector", `{"connectors": {"nope": {}}}`, nil, `unknown connector "nope"`},
+		{"bad endpoint", `{"connectors": {"okx_futures": {"endpoint": "not a url"}}}`, nil, "invalid endpoint"},
+		{"zero threshold", `{"thresholds": {"min_profit_pct": 0}}`, nil, "min_profit_pct must be positive"},
+		{"negative cooldown", `{"thresholds": {"min_profit_pct": 1, "alert_cooldown": "-1s"}}`, nil, "alert_cooldown must not be negative"},
+		{"zero interval", `{"broadcast": {"prices_interval": "0s"}}`, nil, "prices_interval must be positive"},
+		{"zero stale timeout", `{"stale_timeout": "0s"}`, nil, "stale_timeout must be positive"},
+		{"negative connector stale timeout", `{"connectors": {"okx_futures": {"stale_timeout": "-1s"}}}`, nil, "connector okx_futures: stale_timeout must not be negative"},
+		{"unknown env connector", `{}`, map[string]string{"CONNECTORS": "nope"}, `CONNECTORS: unknown connector "nope"`},
+		{"bad env number", `{}`, map[string]string{"MIN_PROFIT_PCT": "lots"}, "MIN_PROFIT_PCT"},
+		{"bad env duration", `{}`, map[string]string{"ALERT_COOLDOWN": "soon"}, "ALERT_COOLDOWN"},
+		{"bad env stale timeout", `{}`, map[string]string{"STALE_TIMEOUT": "soon"}, "STALE_TIMEOUT"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := LoadConfig(writeConfig(t, test.config))
+			if err == nil {
+				t.Fatalf("LoadConfig(%s) succeeded, want an error containing %q", test.config, test.wantErr)
+			}
+			if !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("LoadConfig(%s) = %v, want an error containing %q", test.config, err, test.wantErr)
+			}
+		})
+	}
+
+	clearConfigEnv(t)
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadConfig of a missing file succeeded")
+	}
+}
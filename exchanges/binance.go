@@ -1,6 +1,7 @@
 package exchanges
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -32,25 +33,240 @@ type BinanceFuturesBookTicker struct {
 	BestAskQty   string `json:"A"`
 }
 
-func ConnectBinanceFutures(symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
-	streamNames := make([]string, len(symbols)*2)
-	for i, symbol := range symbols {
-		streamNames[i*2] = strings.ToLower(symbol) + "@bookTicker"
-		streamNames[i*2+1] = strings.ToLower(symbol) + "@aggTrade"
+func init() {
+	Register(NewConnector("binance_futures", MarketFutures, nil, ConnectBinanceFutures))
+	Register(NewConnector("binance_coinm_futures", MarketFutures, nil, ConnectBinanceCoinMFutures))
+	Register(NewConnector("binance_quarterly_futures", MarketFutures, nil, ConnectBinanceQuarterlyFutures))
+	Register(NewConnector("binance_coinm_quarterly_futures", MarketFutures, nil, ConnectBinanceCoinMQuarterlyFutures))
+	Register(NewConnector("binance_spot", MarketSpot, nil, ConnectBinanceSpot))
+
+	Instruments.RegisterVenue("binance_futures", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractPerpetual,
+		Format:   concatFormat,
+		Aliases:  thousandAliases,
+	})
+	// COIN-M perps are inverse and USD quoted, sized in contracts of 100 USD
+	// for BTC and 10 USD for the rest (BTCUSD_PERP)
+	Instruments.RegisterVenue("binance_coinm_futures", VenueSpec{
+		Quote:    "USD",
+		Contract: ContractPerpetual,
+		Format: func(base, quote string) string {
+			return base + quote + "_PERP"
+		},
+		Contracts: true,
+		Inverse:   true,
+	})
+	// Quarterly contracts carry their delivery date (BTCUSDT_261225); the
+	// native ID is the contract Binance currently lists as CURRENT_QUARTER
+	Instruments.RegisterVenue("binance_quarterly_futures", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractFuture,
+		Format: func(base, quote string) string {
+			code, _ := binanceQuarter(time.Now())
+			return base + quote + "_" + code
+		},
+		Aliases: thousandAliases,
+	})
+	Instruments.RegisterVenue("binance_coinm_quarterly_futures", VenueSpec{
+		Quote:    "USD",
+		Contract: ContractFuture,
+		Format: func(base, quote string) string {
+			code, _ := binanceQuarter(time.Now())
+			return base + quote + "_" + code
+		},
+		Contracts: true,
+		Inverse:   true,
+	})
+	Instruments.RegisterVenue("binance_spot", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractSpot,
+		Format:   concatFormat,
+	})
+
+	RegisterMetadata("binance_futures", binanceMetadata("https://fapi.binance.com/fapi/v1/exchangeInfo"))
+	RegisterMetadata("binance_coinm_futures", binanceMetadata("https://dapi.binance.com/dapi/v1/exchangeInfo"))
+	RegisterMetadata("binance_quarterly_futures", binanceMetadata("https://fapi.binance.com/fapi/v1/exchangeInfo"))
+	RegisterMetadata("binance_coinm_quarterly_futures", binanceMetadata("https://dapi.binance.com/dapi/v1/exchangeInfo"))
+	RegisterMetadata("binance_spot", binanceMetadata("https://api.binance.com/api/v3/exchangeInfo"))
+}
+
+// binanceExchangeInfo is the part of the exchangeInfo response we use; the
+// futures and spot APIs share the format. Contract type and delivery date
+// are only set on futures, and contract size only on COIN-M.
+type binanceExchangeInfo struct {
+	Symbols []struct {
+		Symbol       string  `json:"symbol"`
+		ContractType string  `json:"contractType"`
+		DeliveryDate int64   `json:"deliveryDate"`
+		ContractSize float64 `json:"contractSize"`
+		Filters      []struct {
+			FilterType string `json:"filterType"`
+			TickSize   string `json:"tickSize"`
+			StepSize   string `json:"stepSize"`
+			MinQty     string `json:"minQty"`
+		} `json:"filters"`
+	} `json:"symbols"`
+}
+
+// binanceMetadata reads tick and lot sizes from the PRICE_FILTER and
+// LOT_SIZE filters. Binance quantities are in the base asset, except on
+// COIN-M where they are contracts of contractSize USD.
+func binanceMetadata(url string) MetadataFetcher {
+	return func(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+		var info binanceExchangeInfo
+		if err := getJSON(ctx, url, &info); err != nil {
+			return nil, err
+		}
+
+		wanted := wantedIDs(nativeIDs)
+		var specs []InstrumentSpec
+		for _, symbol := range info.Symbols {
+			if !wanted[symbol.Symbol] {
+				continue
+			}
+
+			spec := InstrumentSpec{NativeID: symbol.Symbol, ContractSize: 1}
+			if symbol.ContractSize > 0 {
+				spec.ContractSize = symbol.ContractSize
+			}
+			// Perpetuals report a far future delivery date
+			if symbol.ContractType != "" && symbol.ContractType != "PERPETUAL" {
+				spec.Expiry = symbol.DeliveryDate
+			}
+			for _, filter := range symbol.Filters {
+				switch filter.FilterType {
+				case "PRICE_FILTER":
+					spec.TickSize = parseFloatOrZero(filter.TickSize)
+				case "LOT_SIZE":
+					spec.LotSize = parseFloatOrZero(filter.StepSize)
+					spec.MinSize = parseFloatOrZero(filter.MinQty)
+				}
+			}
+			specs = append(specs, spec)
+		}
+		return specs, nil
 	}
-	streamParam := strings.Join(streamNames, "/")
+}
+
+// binanceQuarter returns the date code and delivery time of the quarterly
+// contract that is CURRENT_QUARTER at now. Quarterlies deliver at 08:00 UTC
+// on the last Friday of March, June, September and December.
+func binanceQuarter(now time.Time) (string, time.Time) {
+	now = now.UTC()
+	year, month := now.Year(), now.Month()
+	for {
+		quarterMonth := time.Month((int(month) + 2) / 3 * 3)
+		// Day 0 of the next month is the last day of this one
+		lastDay := time.Date(year, quarterMonth+1, 0, 8, 0, 0, 0, time.UTC)
+		delivery := lastDay.AddDate(0, 0, -((int(lastDay.Weekday()) - int(time.Friday) + 7) % 7))
+		if now.Before(delivery) {
+			return delivery.Format("060102"), delivery
+		}
+		month = quarterMonth + 1
+		if month > time.December {
+			month = time.January
+			year++
+		}
+	}
+}
+
+func ConnectBinanceFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectBinanceFutures(ctx, "binance_futures", "USD-M", "wss://fstream.binance.com", false, symbols, orderbookChan, tradeChan)
+}
+
+// ConnectBinanceCoinMFutures streams COIN-M inverse perpetuals from dstream
+func ConnectBinanceCoinMFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectBinanceFutures(ctx, "binance_coinm_futures", "COIN-M", "wss://dstream.binance.com", false, symbols, orderbookChan, tradeChan)
+}
+
+// ConnectBinanceQuarterlyFutures streams the current quarter USD-M delivery
+// contracts
+func ConnectBinanceQuarterlyFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectBinanceFutures(ctx, "binance_quarterly_futures", "USD-M quarterly", "wss://fstream.binance.com", true, symbols, orderbookChan, tradeChan)
+}
+
+// ConnectBinanceCoinMQuarterlyFutures streams the current quarter COIN-M
+// delivery contracts
+func ConnectBinanceCoinMQuarterlyFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectBinanceFutures(ctx, "binance_coinm_quarterly_futures", "COIN-M quarterly", "wss://dstream.binance.com", true, symbols, orderbookChan, tradeChan)
+}
+
+// connectBinanceFutures runs one Binance futures stream. fstream and dstream
+// send the same bookTicker and aggTrade events; market only names the feed
+// in logs. Dated feeds reconnect at delivery and roll their symbols to the
+// next quarter.
+func connectBinanceFutures(ctx context.Context, source, market, defaultURL string, dated bool, symbols []string, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	var (
+		instruments []VenueInstrument
+		wsURL       string
+		rollAt      time.Time
+	)
+	resolve := func() {
+		instruments = resolveSymbols(source, symbols)
+		streamNames := make([]string, len(instruments)*2)
+		for i, instrument := range instruments {
+			streamNames[i*2] = strings.ToLower(instrument.NativeID) + "@bookTicker"
+			streamNames[i*2+1] = strings.ToLower(instrument.NativeID) + "@aggTrade"
+		}
+		streamParam := strings.Join(streamNames, "/")
+
+		wsURL = fmt.Sprintf("%s/stream?streams=%s", endpoint(source, defaultURL), streamParam)
+		_, rollAt = binanceQuarter(time.Now())
+
+		// The delivery time is known from the contract name, so dated
+		// instruments get their expiry even when exchangeInfo is down
+		if dated {
+			for _, instrument := range instruments {
+				if instrument.Spec.Expiry == 0 {
+					spec := instrument.Spec
+					spec.Expiry = rollAt.UnixMilli()
+					Instruments.SetSpec(source, spec)
+				}
+			}
+		}
+	}
+	resolve()
 
-	wsURL := fmt.Sprintf("wss://fstream.binance.com/stream?streams=%s", streamParam)
+	sup := NewSupervisor(source)
+	defer sup.Stopped()
 
 	for {
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if dated && !time.Now().Before(rollAt) {
+			log.Printf("Binance %s contracts delivered, rolling to the next quarter", market)
+			Instruments.Forget(source)
+			resolve()
+			LoadInstrumentSpecs(ctx, []string{source}, symbols, "")
+		}
+
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
 		if err != nil {
-			log.Printf("Binance futures connection error: %v", err)
-			time.Sleep(5 * time.Second)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Binance %s connection error: %v", market, err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
-		log.Printf("Connected to Binance futures WebSocket")
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor(source).StaleTimeout)
+
+		log.Printf("Connected to Binance %s WebSocket", market)
+
+		sup.Subscribed()
+
+		// Delivered contracts stop streaming; reconnect to roll them
+		stopRoll := func() bool { return false }
+		if dated {
+			stopRoll = time.AfterFunc(time.Until(rollAt), func() { conn.Close() }).Stop
+		}
 
 		for {
 			var message struct {
@@ -58,12 +274,18 @@ func ConnectBinanceFutures(symbols []string, priceChan chan<- PriceData, orderbo
 				Data   json.RawMessage `json:"data"`
 			}
 
-			err := conn.ReadJSON(&message)
+			err := sess.ReadJSON(&message)
 			if err != nil {
-				log.Printf("Binance futures read error: %v", err)
+				if ctx.Err() == nil {
+					log.Printf("Binance %s read error: %v", market, err)
+				}
+				sup.Disconnected(err)
 				conn.Close()
 				break
 			}
+			sess.Message()
+
+			receivedAt := time.Now()
 
 			if strings.Contains(message.Stream, "@bookTicker") {
 				var bookTicker BinanceFuturesBookTicker
@@ -71,18 +293,28 @@ func ConnectBinanceFutures(symbols []string, priceChan chan<- PriceData, orderbo
 					continue
 				}
 
-				bidPrice, err1 := strconv.ParseFloat(bookTicker.BestBidPrice, 64)
-				askPrice, err2 := strconv.ParseFloat(bookTicker.BestAskPrice, 64)
+				bidPrice, err1 := ParseDecimal(bookTicker.BestBidPrice)
+				askPrice, err2 := ParseDecimal(bookTicker.BestAskPrice)
 				if err1 != nil || err2 != nil {
 					continue
 				}
+				bidSize, _ := strconv.ParseFloat(bookTicker.BestBidQty, 64)
+				askSize, _ := strconv.ParseFloat(bookTicker.BestAskQty, 64)
+
+				instrument, ok := Instruments.Lookup(source, bookTicker.Symbol)
+				if !ok {
+					continue
+				}
 
 				orderbookData := OrderbookData{
-					Symbol:    bookTicker.Symbol,
-					Source:    "binance_futures",
-					BestBid:   bidPrice,
-					BestAsk:   askPrice,
-					Timestamp: bookTicker.EventTime,
+					Symbol:      instrument.Symbol,
+					Source:      source,
+					BestBid:     instrument.NormalizePrice(bidPrice),
+					BestAsk:     instrument.NormalizePrice(askPrice),
+					BestBidSize: instrument.NormalizeSize(bidSize, bidPrice),
+					BestAskSize: instrument.NormalizeSize(askSize, askPrice),
+					Timestamp:   bookTicker.EventTime,
+					ReceivedAt:  receivedAt,
 				}
 
 				orderbookChan <- orderbookData
@@ -93,7 +325,7 @@ func ConnectBinanceFutures(symbols []string, priceChan chan<- PriceData, orderbo
 					continue
 				}
 
-				price, err := strconv.ParseFloat(trade.Price, 64)
+				price, err := ParseDecimal(trade.Price)
 				if err != nil {
 					continue
 				}
@@ -106,20 +338,22 @@ func ConnectBinanceFutures(symbols []string, priceChan chan<- PriceData, orderbo
 					side = "sell"
 				}
 
-				tradeData := TradeData{
-					Symbol:    trade.Symbol,
-					Source:    "binance_futures",
-					Price:     price,
-					Quantity:  trade.Quantity,
-					Side:      side,
-					Timestamp: trade.TradeTime,
+				instrument, ok := Instruments.Lookup(source, trade.Symbol)
+				if !ok {
+					continue
 				}
 
+				tradeData := newTradeData(instrument, price, trade.Quantity, side, trade.TradeTime, receivedAt)
+
 				tradeChan <- tradeData
 			}
 		}
 
-		time.Sleep(2 * time.Second)
+		stopRoll()
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
 	}
 }
 
@@ -146,38 +380,62 @@ type BinanceSpotBookTicker struct {
 }
 
 // ConnectBinanceSpot connects to Binance spot trading WebSocket API
-func ConnectBinanceSpot(symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
-	streamNames := make([]string, len(symbols)*2)
-	for i, symbol := range symbols {
-		streamNames[i*2] = strings.ToLower(symbol) + "@bookTicker"
-		streamNames[i*2+1] = strings.ToLower(symbol) + "@aggTrade"
+func ConnectBinanceSpot(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	instruments := resolveSymbols("binance_spot", symbols)
+	streamNames := make([]string, len(instruments)*2)
+	for i, instrument := range instruments {
+		streamNames[i*2] = strings.ToLower(instrument.NativeID) + "@bookTicker"
+		streamNames[i*2+1] = strings.ToLower(instrument.NativeID) + "@aggTrade"
 	}
 	streamParam := strings.Join(streamNames, "/")
 
-	wsURL := fmt.Sprintf("wss://stream.binance.com:9443/stream?streams=%s", streamParam)
+	wsURL := fmt.Sprintf("%s/stream?streams=%s", endpoint("binance_spot", "wss://stream.binance.com:9443"), streamParam)
+
+	sup := NewSupervisor("binance_spot")
+	defer sup.Stopped()
 
 	for {
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			log.Printf("Binance spot connection error: %v", err)
-			time.Sleep(5 * time.Second)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("binance_spot").StaleTimeout)
+
 		log.Printf("Connected to Binance spot WebSocket")
 
+		sup.Subscribed()
+
 		for {
 			var message struct {
 				Stream string          `json:"stream"`
 				Data   json.RawMessage `json:"data"`
 			}
 
-			err := conn.ReadJSON(&message)
+			err := sess.ReadJSON(&message)
 			if err != nil {
-				log.Printf("Binance spot read error: %v", err)
+				if ctx.Err() == nil {
+					log.Printf("Binance spot read error: %v", err)
+				}
+				sup.Disconnected(err)
 				conn.Close()
 				break
 			}
+			sess.Message()
+
+			receivedAt := time.Now()
 
 			if strings.Contains(message.Stream, "@bookTicker") {
 				var bookTicker BinanceSpotBookTicker
@@ -185,18 +443,28 @@ func ConnectBinanceSpot(symbols []string, priceChan chan<- PriceData, orderbookC
 					continue
 				}
 
-				bidPrice, err1 := strconv.ParseFloat(bookTicker.BestBidPrice, 64)
-				askPrice, err2 := strconv.ParseFloat(bookTicker.BestAskPrice, 64)
+				bidPrice, err1 := ParseDecimal(bookTicker.BestBidPrice)
+				askPrice, err2 := ParseDecimal(bookTicker.BestAskPrice)
 				if err1 != nil || err2 != nil {
 					continue
 				}
+				bidSize, _ := strconv.ParseFloat(bookTicker.BestBidQty, 64)
+				askSize, _ := strconv.ParseFloat(bookTicker.BestAskQty, 64)
+
+				instrument, ok := Instruments.Lookup("binance_spot", bookTicker.Symbol)
+				if !ok {
+					continue
+				}
 
 				orderbookData := OrderbookData{
-					Symbol:    bookTicker.Symbol,
-					Source:    "binance_spot",
-					BestBid:   bidPrice,
-					BestAsk:   askPrice,
-					Timestamp: bookTicker.EventTime,
+					Symbol:      instrument.Symbol,
+					Source:      "binance_spot",
+					BestBid:     instrument.NormalizePrice(bidPrice),
+					BestAsk:     instrument.NormalizePrice(askPrice),
+					BestBidSize: instrument.NormalizeSize(bidSize, bidPrice),
+					BestAskSize: instrument.NormalizeSize(askSize, askPrice),
+					Timestamp:   bookTicker.EventTime,
+					ReceivedAt:  receivedAt,
 				}
 
 				orderbookChan <- orderbookData
@@ -207,7 +475,7 @@ func ConnectBinanceSpot(symbols []string, priceChan chan<- PriceData, orderbookC
 					continue
 				}
 
-				price, err := strconv.ParseFloat(trade.Price, 64)
+				price, err := ParseDecimal(trade.Price)
 				if err != nil {
 					continue
 				}
@@ -220,19 +488,20 @@ func ConnectBinanceSpot(symbols []string, priceChan chan<- PriceData, orderbookC
 					side = "sell"
 				}
 
-				tradeData := TradeData{
-					Symbol:    trade.Symbol,
-					Source:    "binance_spot",
-					Price:     price,
-					Quantity:  trade.Quantity,
-					Side:      side,
-					Timestamp: trade.TradeTime,
+				instrument, ok := Instruments.Lookup("binance_spot", trade.Symbol)
+				if !ok {
+					continue
 				}
 
+				tradeData := newTradeData(instrument, price, trade.Quantity, side, trade.TradeTime, receivedAt)
+
 				tradeChan <- tradeData
 			}
 		}
 
-		time.Sleep(2 * time.Second)
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
 	}
 }
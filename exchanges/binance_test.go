@@ -0,0 +1,84 @@
+package exchanges
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBinanceQuarter(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      time.Time
+		code     string
+		delivery time.Time
+	}{
+		{
+			name:     "mid quarter",
+			now:      time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+			code:     "261225",
+			delivery: time.Date(2026, 12, 25, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "first day of the quarter month",
+			now:      time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+			code:     "260626",
+			delivery: time.Date(2026, 6, 26, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "last Friday is the last day of the month",
+			now:      time.Date(2023, 3, 30, 0, 0, 0, 0, time.UTC),
+			code:     "230331",
+			delivery: time.Date(2023, 3, 31, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "last Friday is a week before month end",
+			now:      time.Date(2027, 9, 24, 7, 0, 0, 0, time.UTC),
+			code:     "270924",
+			delivery: time.Date(2027, 9, 24, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "after delivery, before month end",
+			now:      time.Date(2027, 9, 28, 0, 0, 0, 0, time.UTC),
+			code:     "271231",
+			delivery: time.Date(2027, 12, 31, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "just before 08:00 UTC delivery",
+			now:      time.Date(2026, 9, 25, 7, 59, 59, 999_999_999, time.UTC),
+			code:     "260925",
+			delivery: time.Date(2026, 9, 25, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "exactly at delivery",
+			now:      time.Date(2026, 9, 25, 8, 0, 0, 0, time.UTC),
+			code:     "261225",
+			delivery: time.Date(2026, 12, 25, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "December delivery rolls into March",
+			now:      time.Date(2026, 12, 25, 8, 0, 0, 0, time.UTC),
+			code:     "270326",
+			delivery: time.Date(2027, 3, 26, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "between Christmas delivery and New Year",
+			now:      time.Date(2021, 12, 31, 9, 0, 0, 0, time.UTC),
+			code:     "220325",
+			delivery: time.Date(2022, 3, 25, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "non UTC input",
+			now:      time.Date(2026, 12, 25, 9, 30, 0, 0, time.FixedZone("CET", 3600)),
+			code:     "270326",
+			delivery: time.Date(2027, 3, 26, 8, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, delivery := binanceQuarter(test.now)
+			if code != test.code || !delivery.Equal(test.delivery) {
+				t.Errorf("binanceQuarter(%v) = %s, %v; want %s, %v", test.now, code, delivery, test.code, test.delivery)
+			}
+		})
+	}
+}
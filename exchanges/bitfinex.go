@@ -0,0 +1,458 @@
+package exchanges
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Bitfinex conf flags: OB_CHECKSUM sends a "cs" message after every book
+// update and TIMESTAMP appends the send time to every channel message
+const (
+	bitfinexFlagTimestamp = 32768
+	bitfinexFlagChecksum  = 131072
+)
+
+// bitfinexChecksumLevels is how many levels per side the checksum covers
+const bitfinexChecksumLevels = 25
+
+// BitfinexEvent is a JSON object frame: info, conf, subscribed, error
+type BitfinexEvent struct {
+	Event   string `json:"event"`
+	Channel string `json:"channel"`
+	ChanID  int64  `json:"chanId"`
+	Symbol  string `json:"symbol"`
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+}
+
+// BitfinexBookEntry is one [price, count, amount] book row. A positive
+// amount is a bid, a negative one an ask, and a zero count removes the level.
+type BitfinexBookEntry struct {
+	Price  Decimal
+	Count  int64
+	Amount float64
+}
+
+func (e *BitfinexBookEntry) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) < 3 {
+		return fmt.Errorf("bitfinex book entry %s: want 3 fields", data)
+	}
+	if err := json.Unmarshal(fields[0], &e.Price); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[1], &e.Count); err != nil {
+		return err
+	}
+	return json.Unmarshal(fields[2], &e.Amount)
+}
+
+// BitfinexTrade is one [id, mts, amount, price] trade; a positive amount is
+// a taker buy
+type BitfinexTrade struct {
+	ID        int64
+	Timestamp int64
+	Amount    json.Number
+	Price     Decimal
+}
+
+func (t *BitfinexTrade) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) < 4 {
+		return fmt.Errorf("bitfinex trade %s: want 4 fields", data)
+	}
+	if err := json.Unmarshal(fields[0], &t.ID); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[1], &t.Timestamp); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[2], &t.Amount); err != nil {
+		return err
+	}
+	return json.Unmarshal(fields[3], &t.Price)
+}
+
+// bitfinexChannel is what a numeric channel ID was subscribed to
+type bitfinexChannel struct {
+	channel  string // book or trades
+	nativeID string
+}
+
+// BitfinexOrderBook is a local book that is only published once a snapshot
+// has been applied
+type BitfinexOrderBook struct {
+	*OrderBook
+	Synced bool
+}
+
+func init() {
+	Register(NewConnector("bitfinex_futures", MarketFutures, nil, ConnectBitfinexFutures))
+	Register(NewConnector("bitfinex_spot", MarketSpot, nil, ConnectBitfinexSpot))
+
+	// Bitfinex calls USDT UST, and perps are quoted against the USTF0
+	// collateral currency (tBTCF0:USTF0). Prices are rounded to five
+	// significant digits rather than a tick and sizes are in the base asset,
+	// so no metadata is needed.
+	Instruments.RegisterVenue("bitfinex_futures", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractPerpetual,
+		Format: func(base, quote string) string {
+			return "t" + base + "F0:" + bitfinexCurrency(quote) + "F0"
+		},
+	})
+	Instruments.RegisterVenue("bitfinex_spot", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractSpot,
+		Format: func(base, quote string) string {
+			base, quote = bitfinexCurrency(base), bitfinexCurrency(quote)
+			// Pairs with a currency longer than three letters are separated
+			if len(base) > 3 || len(quote) > 3 {
+				return "t" + base + ":" + quote
+			}
+			return "t" + base + quote
+		},
+	})
+}
+
+// bitfinexCurrency maps a currency to Bitfinex's own ticker
+func bitfinexCurrency(currency string) string {
+	if currency == "USDT" {
+		return "UST"
+	}
+	return currency
+}
+
+// jsNumber formats f the way JavaScript's Number.prototype.toString does,
+// which is how Bitfinex renders the values it checksums
+func jsNumber(f float64) string {
+	abs := math.Abs(f)
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		// Go pads the exponent to two digits (1e-07), JavaScript doesn't
+		s := strconv.FormatFloat(f, 'e', -1, 64)
+		mantissa, exponent, _ := strings.Cut(s, "e")
+		sign := exponent[:1]
+		exponent = strings.TrimLeft(exponent[1:], "0")
+		if sign == "-" {
+			return mantissa + "e-" + exponent
+		}
+		return mantissa + "e+" + exponent
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// bitfinexChecksum is the CRC32 of the top 25 bids and asks interleaved as
+// bid price:bid amount:ask price:ask amount, with ask amounts negative.
+// Prices are written from the venue's own text, since a float round trip
+// would turn 1.0494 into 1.0493999999999999 on some paths.
+func bitfinexChecksum(book *OrderBook) uint32 {
+	bids := book.Levels(Bid, bitfinexChecksumLevels)
+	asks := book.Levels(Ask, bitfinexChecksumLevels)
+
+	parts := make([]string, 0, 2*(len(bids)+len(asks)))
+	for i := 0; i < bitfinexChecksumLevels; i++ {
+		if i < len(bids) {
+			parts = append(parts, bids[i].Price.String(), jsNumber(bids[i].Size))
+		}
+		if i < len(asks) {
+			parts = append(parts, asks[i].Price.String(), jsNumber(-asks[i].Size))
+		}
+	}
+	return crc32.ChecksumIEEE([]byte(strings.Join(parts, ":")))
+}
+
+// bitfinexLevels splits book rows into bid and ask levels
+func bitfinexLevels(entries []BitfinexBookEntry) (bids, asks []PriceLevel) {
+	for _, entry := range entries {
+		level := PriceLevel{Price: entry.Price, Size: math.Abs(entry.Amount)}
+		if entry.Count == 0 {
+			level.Size = 0
+		}
+		if entry.Amount > 0 {
+			bids = append(bids, level)
+		} else {
+			asks = append(asks, level)
+		}
+	}
+	return bids, asks
+}
+
+// ConnectBitfinexFutures streams USDT collateral perpetuals (tBTCF0:USTF0)
+func ConnectBitfinexFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectBitfinex(ctx, "bitfinex_futures", symbols, orderbookChan, tradeChan)
+}
+
+// ConnectBitfinexSpot streams spot pairs (tBTCUST)
+func ConnectBitfinexSpot(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectBitfinex(ctx, "bitfinex_spot", symbols, orderbookChan, tradeChan)
+}
+
+// connectBitfinex runs one Bitfinex public feed. The book channel keeps a
+// local 25 level book that is checked against the checksum Bitfinex sends
+// after every update and resubscribed when they disagree.
+func connectBitfinex(ctx context.Context, source string, symbols []string, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint(source, "wss://api-pub.bitfinex.com/ws/2")
+	instruments := resolveSymbols(source, symbols)
+	depth := optionsFor(source).Depth
+
+	sup := NewSupervisor(source)
+	defer sup.Stopped()
+
+	for {
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Bitfinex %s connection error: %v", source, err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor(source).StaleTimeout)
+
+		log.Printf("Connected to Bitfinex %s WebSocket", source)
+
+		// Channel IDs are assigned per connection in the subscribed events
+		channels := make(map[int64]bitfinexChannel)
+		books := make(map[string]*BitfinexOrderBook)
+
+		subscribeBook := func(nativeID string) error {
+			return sess.WriteJSON(map[string]string{
+				"event":   "subscribe",
+				"channel": "book",
+				"symbol":  nativeID,
+				"prec":    "P0",
+				"freq":    "F0",
+				"len":     "25",
+			})
+		}
+
+		err = sess.WriteJSON(map[string]interface{}{
+			"event": "conf",
+			"flags": bitfinexFlagChecksum | bitfinexFlagTimestamp,
+		})
+		for _, instrument := range instruments {
+			book := &BitfinexOrderBook{OrderBook: NewOrderBook()}
+			book.SetChecksum(bitfinexChecksum)
+			books[instrument.NativeID] = book
+
+			if err == nil {
+				err = subscribeBook(instrument.NativeID)
+			}
+			if err == nil {
+				err = sess.WriteJSON(map[string]string{
+					"event":   "subscribe",
+					"channel": "trades",
+					"symbol":  instrument.NativeID,
+				})
+			}
+		}
+		if err != nil {
+			log.Printf("Bitfinex %s subscription error: %v", source, err)
+			sup.Disconnected(err)
+			stopClose()
+			conn.Close()
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		sup.Subscribed()
+
+		// resync drops a book and resubscribes its channel for a new snapshot
+		resync := func(chanID int64, nativeID, reason string) {
+			log.Printf("Bitfinex %s book resync: %s", nativeID, reason)
+			sup.Resync(nativeID + ": " + reason)
+			book := books[nativeID]
+			book.Reset()
+			book.Synced = false
+			delete(channels, chanID)
+
+			err := sess.WriteJSON(map[string]interface{}{
+				"event":  "unsubscribe",
+				"chanId": chanID,
+			})
+			if err == nil {
+				err = subscribeBook(nativeID)
+			}
+			if err != nil {
+				log.Printf("Bitfinex resubscribe error for %s: %v", nativeID, err)
+				conn.Close()
+			}
+		}
+
+		for {
+			data, err := sess.ReadMessage()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Bitfinex %s read error: %v", source, err)
+				}
+				sup.Disconnected(err)
+				conn.Close()
+				break
+			}
+
+			receivedAt := time.Now()
+
+			if bytes.HasPrefix(data, []byte("{")) {
+				var event BitfinexEvent
+				if err := json.Unmarshal(data, &event); err != nil {
+					log.Printf("Bitfinex %s unmarshal error: %v", source, err)
+					continue
+				}
+
+				switch event.Event {
+				case "subscribed":
+					channels[event.ChanID] = bitfinexChannel{channel: event.Channel, nativeID: event.Symbol}
+				case "error":
+					log.Printf("Bitfinex %s error %d: %s", source, event.Code, event.Msg)
+				case "info":
+					// 20051 asks clients to reconnect before a server restart
+					if event.Code == 20051 {
+						log.Printf("Bitfinex %s requested reconnect", source)
+						conn.Close()
+					}
+				}
+				continue
+			}
+
+			// Channel messages are [chanId, payload..., timestamp]
+			var fields []json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil || len(fields) < 2 {
+				continue
+			}
+
+			var chanID int64
+			if err := json.Unmarshal(fields[0], &chanID); err != nil {
+				continue
+			}
+			channel, ok := channels[chanID]
+			if !ok {
+				continue
+			}
+
+			// A string after the channel ID names the message type (hb, cs,
+			// te, tu); what remains is the payload and the send time
+			fields = fields[1:]
+			var kind string
+			if bytes.HasPrefix(fields[0], []byte(`"`)) {
+				json.Unmarshal(fields[0], &kind)
+				fields = fields[1:]
+			}
+			// Heartbeats don't count as data for the stale watchdog
+			if kind == "hb" || len(fields) == 0 {
+				continue
+			}
+			sess.Message()
+			payload := fields[0]
+
+			var timestamp int64
+			if len(fields) > 1 {
+				json.Unmarshal(fields[len(fields)-1], &timestamp)
+			}
+
+			instrument, ok := Instruments.Lookup(source, channel.nativeID)
+			if !ok {
+				continue
+			}
+
+			switch channel.channel {
+			case "book":
+				book := books[channel.nativeID]
+				if book == nil {
+					continue
+				}
+
+				if kind == "cs" {
+					if !book.Synced {
+						continue
+					}
+					var checksum int32
+					if err := json.Unmarshal(payload, &checksum); err != nil {
+						continue
+					}
+					if !book.VerifyChecksum(uint32(checksum)) {
+						resync(chanID, channel.nativeID, "checksum mismatch")
+					}
+					continue
+				}
+
+				// A snapshot is a list of rows, an update a single row
+				if bytes.HasPrefix(payload, []byte("[[")) {
+					var entries []BitfinexBookEntry
+					if err := json.Unmarshal(payload, &entries); err != nil {
+						log.Printf("Bitfinex %s book snapshot unmarshal error: %v", source, err)
+						continue
+					}
+					book.ApplySnapshot(bitfinexLevels(entries))
+					book.Synced = true
+				} else {
+					if !book.Synced {
+						continue
+					}
+					var entry BitfinexBookEntry
+					if err := json.Unmarshal(payload, &entry); err != nil {
+						continue
+					}
+					book.ApplyDelta(bitfinexLevels([]BitfinexBookEntry{entry}))
+				}
+
+				orderbookData, ok := book.OrderbookData(instrument, depth, timestamp, receivedAt)
+				if !ok {
+					continue
+				}
+
+				orderbookChan <- orderbookData
+
+			case "trades":
+				// The snapshot replays recent trades and every execution is
+				// sent twice, as te and then tu; only te is counted
+				if kind != "te" {
+					continue
+				}
+
+				var trade BitfinexTrade
+				if err := json.Unmarshal(payload, &trade); err != nil {
+					continue
+				}
+
+				side := "sell"
+				if !strings.HasPrefix(trade.Amount.String(), "-") {
+					side = "buy"
+				}
+
+				tradeChan <- newTradeData(instrument, trade.Price, trade.Amount.String(), side, trade.Timestamp, receivedAt)
+			}
+		}
+
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
+	}
+}
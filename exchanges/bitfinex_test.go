@@ -0,0 +1,70 @@
+package exchanges
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// bitfinexXRPSnapshot is a tXRPUST P0 book snapshot with four decimal prices,
+// which don't survive a float round trip. Its checksums below are the
+// signed CRC32s of the checksum string Bitfinex documents for P0 books,
+// computed independently of this package.
+const bitfinexXRPSnapshot = `[[1.0494,1,1520.5],[1.0493,2,833.29],[1.049,3,4000],[1.0488,4,120.1234],[1.0487,1,77.5],[1.0485,2,10010],[1.0482,3,2500.75],[1.048,4,431],[1.0478,1,9.87654321],[1.0475,2,650],[1.0473,3,3333.3],[1.047,4,15],[1.0468,1,250.5],[1.0465,2,1200],[1.0463,3,88.88],[1.046,4,7000],[1.0458,1,42.42],[1.0455,2,999.9],[1.045,3,5],[1.0448,4,61.2],[1.0445,1,18000],[1.044,2,3.3],[1.0438,3,710],[1.0435,4,2222],[1.043,1,100],[1.0425,2,400],[1.0495,1,-10010],[1.0497,2,-2500.75],[1.05,3,-431],[1.0502,1,-9.87654321],[1.0505,2,-650],[1.0508,3,-3333.3],[1.051,1,-15],[1.0513,2,-250.5],[1.0515,3,-1200],[1.0518,1,-88.88],[1.052,2,-7000],[1.0525,3,-42.42],[1.053,1,-999.9],[1.0535,2,-5],[1.054,3,-61.2],[1.0545,1,-18000],[1.055,2,-3.3],[1.056,3,-710],[1.057,1,-2222],[1.058,2,-100],[1.06,3,-400],[1.07,1,-1520.5],[1.08,2,-833.29],[1.1,3,-4000],[1.118,1,-120.1234],[1.12,2,-77.5]]`
+
+// Bitfinex sends checksums as signed 32 bit integers
+var (
+	bitfinexXRPSnapshotChecksum int32 = -164357355
+	// After removing the 1.0494 bid and resizing the 1.0497 ask to 12.5
+	bitfinexXRPUpdateChecksum int32 = 1628781146
+)
+
+func bitfinexEntries(t *testing.T, data string) []BitfinexBookEntry {
+	t.Helper()
+	var entries []BitfinexBookEntry
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+	return entries
+}
+
+func TestBitfinexChecksum(t *testing.T) {
+	book := NewOrderBook()
+	book.SetChecksum(bitfinexChecksum)
+
+	bids, asks := bitfinexLevels(bitfinexEntries(t, bitfinexXRPSnapshot))
+	book.ApplySnapshot(bids, asks)
+	if !book.VerifyChecksum(uint32(bitfinexXRPSnapshotChecksum)) {
+		t.Errorf("snapshot checksum = %d, want %d", int32(bitfinexChecksum(book)), bitfinexXRPSnapshotChecksum)
+	}
+
+	// A count of 0 removes the level; the amount sign picks the side
+	bids, asks = bitfinexLevels(bitfinexEntries(t, `[[1.0494,0,1],[1.0497,2,-12.5]]`))
+	book.ApplyDelta(bids, asks)
+	if !book.VerifyChecksum(uint32(bitfinexXRPUpdateChecksum)) {
+		t.Errorf("update checksum = %d, want %d", int32(bitfinexChecksum(book)), bitfinexXRPUpdateChecksum)
+	}
+	if book.VerifyChecksum(uint32(bitfinexXRPSnapshotChecksum)) {
+		t.Error("stale checksum verified after an update")
+	}
+}
+
+func TestJSNumber(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{0, "0"},
+		{4000, "4000"},
+		{-2500.75, "-2500.75"},
+		{9.87654321, "9.87654321"},
+		{0.000001, "0.000001"},
+		{1e-7, "1e-7"},
+		{-2.5e-8, "-2.5e-8"},
+		{1e21, "1e+21"},
+	}
+	for _, test := range tests {
+		if got := jsNumber(test.in); got != test.want {
+			t.Errorf("jsNumber(%v) = %s, want %s", test.in, got, test.want)
+		}
+	}
+}
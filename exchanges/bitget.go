@@ -0,0 +1,319 @@
+package exchanges
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// BitgetMessage is a push on the v2 public websocket. Subscription acks and
+// errors carry event instead of action.
+type BitgetMessage struct {
+	Event  string `json:"event"`
+	Code   int    `json:"code"`
+	Msg    string `json:"msg"`
+	Action string `json:"action"` // "snapshot" or "update"
+	Arg    struct {
+		InstType string `json:"instType"`
+		Channel  string `json:"channel"`
+		InstID   string `json:"instId"`
+	} `json:"arg"`
+	Data json.RawMessage `json:"data"`
+}
+
+type BitgetBook struct {
+	Bids      [][]string `json:"bids"`
+	Asks      [][]string `json:"asks"`
+	Timestamp int64      `json:"ts,string"`
+}
+
+type BitgetTrade struct {
+	Price     string `json:"price"`
+	Size      string `json:"size"`
+	Side      string `json:"side"`
+	TradeID   string `json:"tradeId"`
+	Timestamp int64  `json:"ts,string"`
+}
+
+func init() {
+	Register(NewConnector("bitget_futures", MarketFutures, nil, ConnectBitgetFutures))
+	Register(NewConnector("bitget_spot", MarketSpot, nil, ConnectBitgetSpot))
+
+	Instruments.RegisterVenue("bitget_futures", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractPerpetual,
+		Format:   concatFormat,
+	})
+	Instruments.RegisterVenue("bitget_spot", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractSpot,
+		Format:   concatFormat,
+	})
+
+	RegisterMetadata("bitget_futures", bitgetFuturesMetadata)
+	RegisterMetadata("bitget_spot", bitgetSpotMetadata)
+}
+
+type bitgetContracts struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		Symbol         string `json:"symbol"`
+		PricePlace     string `json:"pricePlace"`
+		PriceEndStep   string `json:"priceEndStep"`
+		SizeMultiplier string `json:"sizeMultiplier"`
+		MinTradeNum    string `json:"minTradeNum"`
+	} `json:"data"`
+}
+
+// bitgetFuturesMetadata loads USDT-M contracts. The tick is priceEndStep in
+// units of the last price place; sizes are in the base coin.
+func bitgetFuturesMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var contracts bitgetContracts
+	if err := getJSON(ctx, "https://api.bitget.com/api/v2/mix/market/contracts?productType=USDT-FUTURES", &contracts); err != nil {
+		return nil, err
+	}
+	if contracts.Code != "00000" {
+		return nil, fmt.Errorf("contracts: %s", contracts.Msg)
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, contract := range contracts.Data {
+		if !wanted[contract.Symbol] {
+			continue
+		}
+		step := parseFloatOrZero(contract.PriceEndStep)
+		if step == 0 {
+			step = 1
+		}
+		specs = append(specs, InstrumentSpec{
+			NativeID:     contract.Symbol,
+			TickSize:     step * decimalStep(int(parseFloatOrZero(contract.PricePlace))),
+			LotSize:      parseFloatOrZero(contract.SizeMultiplier),
+			MinSize:      parseFloatOrZero(contract.MinTradeNum),
+			ContractSize: 1,
+		})
+	}
+	return specs, nil
+}
+
+type bitgetSymbols struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		Symbol            string `json:"symbol"`
+		PricePrecision    string `json:"pricePrecision"`
+		QuantityPrecision string `json:"quantityPrecision"`
+		MinTradeAmount    string `json:"minTradeAmount"`
+	} `json:"data"`
+}
+
+func bitgetSpotMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var symbols bitgetSymbols
+	if err := getJSON(ctx, "https://api.bitget.com/api/v2/spot/public/symbols", &symbols); err != nil {
+		return nil, err
+	}
+	if symbols.Code != "00000" {
+		return nil, fmt.Errorf("symbols: %s", symbols.Msg)
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, symbol := range symbols.Data {
+		if !wanted[symbol.Symbol] {
+			continue
+		}
+		lotSize := decimalStep(int(parseFloatOrZero(symbol.QuantityPrecision)))
+		specs = append(specs, InstrumentSpec{
+			NativeID:     symbol.Symbol,
+			TickSize:     decimalStep(int(parseFloatOrZero(symbol.PricePrecision))),
+			LotSize:      lotSize,
+			MinSize:      max(parseFloatOrZero(symbol.MinTradeAmount), lotSize),
+			ContractSize: 1,
+		})
+	}
+	return specs, nil
+}
+
+// ConnectBitgetFutures streams USDT-M perpetual top of book and trades
+func ConnectBitgetFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectBitget(ctx, "bitget_futures", "USDT-FUTURES", symbols, orderbookChan, tradeChan)
+}
+
+// ConnectBitgetSpot streams spot top of book and trades
+func ConnectBitgetSpot(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectBitget(ctx, "bitget_spot", "SPOT", symbols, orderbookChan, tradeChan)
+}
+
+// connectBitget runs one Bitget public feed; futures and spot differ only in
+// instType. books1 pushes a full one level snapshot each time, so no local
+// book is needed.
+func connectBitget(ctx context.Context, source, instType string, symbols []string, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint(source, "wss://ws.bitget.com/v2/ws/public")
+	instruments := resolveSymbols(source, symbols)
+
+	sup := NewSupervisor(source)
+	defer sup.Stopped()
+
+	for {
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Bitget %s connection error: %v", instType, err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor(source).StaleTimeout)
+
+		log.Printf("Connected to Bitget %s WebSocket", instType)
+
+		args := make([]map[string]string, 0, len(instruments)*2)
+		for _, instrument := range instruments {
+			for _, channel := range []string{"books1", "trade"} {
+				args = append(args, map[string]string{
+					"instType": instType,
+					"channel":  channel,
+					"instId":   instrument.NativeID,
+				})
+			}
+		}
+
+		err = sess.WriteJSON(map[string]interface{}{
+			"op":   "subscribe",
+			"args": args,
+		})
+		if err != nil {
+			log.Printf("Bitget %s subscription error: %v", instType, err)
+			sup.Disconnected(err)
+			stopClose()
+			conn.Close()
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		sup.Subscribed()
+
+		// Bitget drops connections that send nothing for two minutes; the
+		// ping is a plain text frame answered with "pong"
+		stopPing := sess.KeepAlive(30*time.Second, func() error {
+			return sess.WriteMessage(websocket.TextMessage, []byte("ping"))
+		})
+
+		for {
+			data, err := sess.ReadMessage()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Bitget %s read error: %v", instType, err)
+				}
+				sup.Disconnected(err)
+				conn.Close()
+				break
+			}
+
+			receivedAt := time.Now()
+
+			// Ping replies don't count as data for the stale watchdog
+			if string(data) == "pong" {
+				continue
+			}
+
+			var message BitgetMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				log.Printf("Bitget %s unmarshal error: %v", instType, err)
+				continue
+			}
+			if message.Event == "error" {
+				log.Printf("Bitget %s error %d: %s", instType, message.Code, message.Msg)
+				continue
+			}
+			if message.Event != "" {
+				continue
+			}
+			sess.Message()
+
+			instrument, ok := Instruments.Lookup(source, message.Arg.InstID)
+			if !ok {
+				continue
+			}
+
+			switch message.Arg.Channel {
+			case "books1":
+				var books []BitgetBook
+				if err := json.Unmarshal(message.Data, &books); err != nil || len(books) == 0 {
+					continue
+				}
+				book := books[0]
+
+				bids, err := parseStringLevels(book.Bids)
+				if err != nil || len(bids) == 0 {
+					continue
+				}
+				asks, err := parseStringLevels(book.Asks)
+				if err != nil || len(asks) == 0 {
+					continue
+				}
+
+				orderbookChan <- OrderbookData{
+					Symbol:      instrument.Symbol,
+					Source:      source,
+					BestBid:     instrument.NormalizePrice(bids[0].Price),
+					BestAsk:     instrument.NormalizePrice(asks[0].Price),
+					BestBidSize: instrument.NormalizeSize(bids[0].Size, bids[0].Price),
+					BestAskSize: instrument.NormalizeSize(asks[0].Size, asks[0].Price),
+					Timestamp:   book.Timestamp,
+					ReceivedAt:  receivedAt,
+				}
+
+			case "trade":
+				// The first push after subscribing replays recent history,
+				// which would double count trades after a reconnect
+				if message.Action == "snapshot" {
+					continue
+				}
+
+				var trades []BitgetTrade
+				if err := json.Unmarshal(message.Data, &trades); err != nil {
+					continue
+				}
+
+				for _, trade := range trades {
+					price, err := ParseDecimal(trade.Price)
+					if err != nil {
+						continue
+					}
+
+					// Bitget reports the taker side as "buy" or "sell"
+					side := "sell"
+					if trade.Side == "buy" {
+						side = "buy"
+					}
+
+					tradeChan <- newTradeData(instrument, price, trade.Size, side, trade.Timestamp, receivedAt)
+				}
+			}
+		}
+
+		stopPing()
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package exchanges
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// BitmexMessage is a frame on the realtime websocket. Table pushes carry
+// table, action and data; the welcome, subscription acks and errors don't.
+type BitmexMessage struct {
+	Table  string          `json:"table"`
+	Action string          `json:"action"` // partial, insert, update, delete
+	Data   json.RawMessage `json:"data"`
+	Error  string          `json:"error"`
+}
+
+type BitmexQuote struct {
+	Symbol    string    `json:"symbol"`
+	BidPrice  Decimal   `json:"bidPrice"`
+	BidSize   float64   `json:"bidSize"`
+	AskPrice  Decimal   `json:"askPrice"`
+	AskSize   float64   `json:"askSize"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// BitmexBookEntry is a row of orderBookL2_25. Rows are keyed by ID; updates
+// and deletes may omit the price, so it is remembered from the insert.
+type BitmexBookEntry struct {
+	Symbol    string    `json:"symbol"`
+	ID        int64     `json:"id"`
+	Side      string    `json:"side"` // Buy or Sell
+	Size      float64   `json:"size"`
+	Price     Decimal   `json:"price"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type BitmexTrade struct {
+	Symbol    string      `json:"symbol"`
+	Side      string      `json:"side"` // taker side, Buy or Sell
+	Size      json.Number `json:"size"`
+	Price     Decimal     `json:"price"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// bitmexLevel is where a book row ID sits
+type bitmexLevel struct {
+	side  BookSide
+	price Decimal
+}
+
+// BitmexOrderBook is a local book plus the row IDs of its levels
+type BitmexOrderBook struct {
+	*OrderBook
+	Levels map[int64]bitmexLevel
+	Synced bool
+}
+
+func (b *BitmexOrderBook) reset() {
+	b.Reset()
+	b.Levels = make(map[int64]bitmexLevel)
+	b.Synced = false
+}
+
+// apply applies one table action to the book. It returns an error when a
+// row refers to an ID the book doesn't hold, which means an update was lost.
+func (b *BitmexOrderBook) apply(action string, entries []BitmexBookEntry) error {
+	for _, entry := range entries {
+		side := Bid
+		if entry.Side == "Sell" {
+			side = Ask
+		}
+
+		switch action {
+		case "partial", "insert":
+			b.Levels[entry.ID] = bitmexLevel{side: side, price: entry.Price}
+			b.Update(side, entry.Price, entry.Size)
+		case "update":
+			level, ok := b.Levels[entry.ID]
+			if !ok {
+				return fmt.Errorf("update of unknown level %d", entry.ID)
+			}
+			b.Update(level.side, level.price, entry.Size)
+		case "delete":
+			level, ok := b.Levels[entry.ID]
+			if !ok {
+				return fmt.Errorf("delete of unknown level %d", entry.ID)
+			}
+			delete(b.Levels, entry.ID)
+			b.Update(level.side, level.price, 0)
+		}
+	}
+	return nil
+}
+
+func init() {
+	Register(NewConnector("bitmex_futures", MarketFutures, nil, ConnectBitmex))
+
+	// XBTUSD is inverse and the alt perps (ETHUSD) are quanto. The contract
+	// kind and settlement currency come from the instrument metadata, and
+	// sizes stay unknown until it has loaded.
+	Instruments.RegisterVenue("bitmex_futures", VenueSpec{
+		Quote:    "USD",
+		Contract: ContractPerpetual,
+		Format:   concatFormat,
+		Aliases: map[string]BaseAlias{
+			"BTC": {Name: "XBT", Multiplier: 1},
+		},
+		Contracts: true,
+	})
+
+	RegisterMetadata("bitmex_futures", bitmexMetadata)
+}
+
+// bitmexCurrency maps a BitMEX currency code (XBt, USDt, in their smallest
+// unit) to the asset name used elsewhere
+func bitmexCurrency(code string) string {
+	code = strings.ToUpper(code)
+	if code == "XBT" {
+		return "BTC"
+	}
+	return code
+}
+
+type bitmexInstrument struct {
+	Symbol                         string   `json:"symbol"`
+	TickSize                       float64  `json:"tickSize"`
+	LotSize                        float64  `json:"lotSize"`
+	IsInverse                      bool     `json:"isInverse"`
+	IsQuanto                       bool     `json:"isQuanto"`
+	SettlCurrency                  string   `json:"settlCurrency"` // XBt, USDt
+	UnderlyingToPositionMultiplier *float64 `json:"underlyingToPositionMultiplier"`
+}
+
+// bitmexMetadata loads active instruments and their contract kind. An
+// inverse contract is worth one quote unit, a linear one a fixed fraction
+// of the underlying, and a quanto one has no fixed base size.
+func bitmexMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var instruments []bitmexInstrument
+	if err := getJSON(ctx, "https://www.bitmex.com/api/v1/instrument/active", &instruments); err != nil {
+		return nil, err
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, instrument := range instruments {
+		if !wanted[instrument.Symbol] {
+			continue
+		}
+
+		var contractSize float64
+		switch {
+		case instrument.IsQuanto:
+		case instrument.IsInverse:
+			contractSize = 1
+		case instrument.UnderlyingToPositionMultiplier != nil && *instrument.UnderlyingToPositionMultiplier > 0:
+			contractSize = 1 / *instrument.UnderlyingToPositionMultiplier
+		}
+
+		specs = append(specs, InstrumentSpec{
+			NativeID:     instrument.Symbol,
+			TickSize:     instrument.TickSize,
+			LotSize:      instrument.LotSize,
+			MinSize:      instrument.LotSize,
+			ContractSize: contractSize,
+			Inverse:      instrument.IsInverse,
+			Quanto:       instrument.IsQuanto,
+			Settlement:   bitmexCurrency(instrument.SettlCurrency),
+		})
+	}
+	return specs, nil
+}
+
+// ConnectBitmex streams BitMEX perpetuals. Top of book comes from the quote
+// table; when a depth is configured orderBookL2_25 is maintained locally
+// instead so levels can be sent.
+func ConnectBitmex(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint("bitmex_futures", "wss://ws.bitmex.com/realtime")
+	instruments := resolveSymbols("bitmex_futures", symbols)
+	depth := optionsFor("bitmex_futures").Depth
+
+	books := make(map[string]*BitmexOrderBook)
+
+	sup := NewSupervisor("bitmex_futures")
+	defer sup.Stopped()
+
+	for {
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("BitMEX connection error: %v", err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("bitmex_futures").StaleTimeout)
+
+		log.Printf("Connected to BitMEX WebSocket")
+
+		var args []string
+		for _, instrument := range instruments {
+			args = append(args, "quote:"+instrument.NativeID, "trade:"+instrument.NativeID)
+			if depth > 0 {
+				args = append(args, "orderBookL2_25:"+instrument.NativeID)
+				book := &BitmexOrderBook{OrderBook: NewOrderBook()}
+				book.reset()
+				books[instrument.NativeID] = book
+			}
+		}
+
+		err = sess.WriteJSON(map[string]interface{}{
+			"op":   "subscribe",
+			"args": args,
+		})
+		if err != nil {
+			log.Printf("BitMEX subscription error: %v", err)
+			sup.Disconnected(err)
+			stopClose()
+			conn.Close()
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		sup.Subscribed()
+
+		// BitMEX expects a text "ping" when the connection is quiet and
+		// answers it with "pong"
+		stopPing := sess.KeepAlive(5*time.Second, func() error {
+			return sess.WriteMessage(websocket.TextMessage, []byte("ping"))
+		})
+
+		// resync drops a book and resubscribes its table for a new partial
+		resync := func(book *BitmexOrderBook, symbol, reason string) {
+			log.Printf("BitMEX %s book resync: %s", symbol, reason)
+			sup.Resync(symbol + ": " + reason)
+			book.reset()
+
+			topic := []string{"orderBookL2_25:" + symbol}
+			err := sess.WriteJSON(map[string]interface{}{"op": "unsubscribe", "args": topic})
+			if err == nil {
+				err = sess.WriteJSON(map[string]interface{}{"op": "subscribe", "args": topic})
+			}
+			if err != nil {
+				log.Printf("BitMEX resubscribe error for %s: %v", symbol, err)
+				conn.Close()
+			}
+		}
+
+		for {
+			data, err := sess.ReadMessage()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("BitMEX read error: %v", err)
+				}
+				sup.Disconnected(err)
+				conn.Close()
+				break
+			}
+
+			receivedAt := time.Now()
+
+			// Ping replies don't count as data for the stale watchdog
+			if string(data) == "pong" {
+				continue
+			}
+
+			var message BitmexMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				log.Printf("BitMEX unmarshal error: %v", err)
+				continue
+			}
+			if message.Error != "" {
+				log.Printf("BitMEX error: %s", message.Error)
+				continue
+			}
+			// Neither do the welcome and subscription replies, which have
+			// no table
+			if message.Table == "" {
+				continue
+			}
+			sess.Message()
+
+			switch message.Table {
+			case "quote":
+				if depth > 0 {
+					// The local book is the top of book source
+					continue
+				}
+
+				var quotes []BitmexQuote
+				if err := json.Unmarshal(message.Data, &quotes); err != nil {
+					log.Printf("BitMEX quote unmarshal error: %v", err)
+					continue
+				}
+
+				for _, quote := range quotes {
+					if quote.BidPrice.Sign() <= 0 || quote.AskPrice.Sign() <= 0 {
+						continue
+					}
+
+					instrument, ok := Instruments.Lookup("bitmex_futures", quote.Symbol)
+					if !ok {
+						continue
+					}
+
+					orderbookChan <- OrderbookData{
+						Symbol:      instrument.Symbol,
+						Source:      "bitmex_futures",
+						BestBid:     instrument.NormalizePrice(quote.BidPrice),
+						BestAsk:     instrument.NormalizePrice(quote.AskPrice),
+						BestBidSize: instrument.NormalizeSize(quote.BidSize, quote.BidPrice),
+						BestAskSize: instrument.NormalizeSize(quote.AskSize, quote.AskPrice),
+						Timestamp:   quote.Timestamp.UnixMilli(),
+						ReceivedAt:  receivedAt,
+					}
+				}
+
+			case "orderBookL2_25":
+				var entries []BitmexBookEntry
+				if err := json.Unmarshal(message.Data, &entries); err != nil {
+					log.Printf("BitMEX book unmarshal error: %v", err)
+					continue
+				}
+
+				// A push may span symbols; group the rows per book
+				bySymbol := make(map[string][]BitmexBookEntry)
+				for _, entry := range entries {
+					bySymbol[entry.Symbol] = append(bySymbol[entry.Symbol], entry)
+				}
+
+				for symbol, rows := range bySymbol {
+					book, exists := books[symbol]
+					if !exists {
+						continue
+					}
+
+					if message.Action == "partial" {
+						book.reset()
+						book.Synced = true
+					} else if !book.Synced {
+						continue
+					}
+
+					if err := book.apply(message.Action, rows); err != nil {
+						resync(book, symbol, err.Error())
+						continue
+					}
+
+					if book.Crossed() {
+						resync(book, symbol, "crossed book")
+						continue
+					}
+
+					instrument, ok := Instruments.Lookup("bitmex_futures", symbol)
+					if !ok {
+						continue
+					}
+
+					var timestamp int64
+					for _, row := range rows {
+						timestamp = max(timestamp, row.Timestamp.UnixMilli())
+					}
+
+					orderbookData, ok := book.OrderbookData(instrument, depth, timestamp, receivedAt)
+					if !ok {
+						continue
+					}
+
+					orderbookChan <- orderbookData
+				}
+
+			case "trade":
+				// The partial sent on subscribe replays the last trades, which
+				// would double count them after a reconnect
+				if message.Action != "insert" {
+					continue
+				}
+
+				var trades []BitmexTrade
+				if err := json.Unmarshal(message.Data, &trades); err != nil {
+					log.Printf("BitMEX trade unmarshal error: %v", err)
+					continue
+				}
+
+				for _, trade := range trades {
+					instrument, ok := Instruments.Lookup("bitmex_futures", trade.Symbol)
+					if !ok {
+						continue
+					}
+
+					side := "sell"
+					if trade.Side == "Buy" {
+						side = "buy"
+					}
+
+					tradeChan <- newTradeData(instrument, trade.Price, trade.Size.String(), side, trade.Timestamp.UnixMilli(), receivedAt)
+				}
+			}
+		}
+
+		stopPing()
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package exchanges
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -24,9 +25,10 @@ type BybitFuturesTrade struct {
 }
 
 type BybitFuturesOrderbook struct {
-	Topic string `json:"topic"`
-	Type  string `json:"type"`
-	Data  struct {
+	Topic     string `json:"topic"`
+	Type      string `json:"type"`
+	Timestamp int64  `json:"ts"`
+	Data      struct {
 		Symbol string     `json:"s"`
 		Bids   [][]string `json:"b"`
 		Asks   [][]string `json:"a"`
@@ -35,63 +37,207 @@ type BybitFuturesOrderbook struct {
 	} `json:"data"`
 }
 
-func ConnectBybitFutures(symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
-	wsURL := "wss://stream.bybit.com/v5/public/linear"
+func init() {
+	Register(NewConnector("bybit_futures", MarketFutures, nil, ConnectBybitFutures))
+	Register(NewConnector("bybit_spot", MarketSpot, nil, ConnectBybitSpot))
+
+	Instruments.RegisterVenue("bybit_futures", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractPerpetual,
+		Format:   concatFormat,
+		Aliases: map[string]BaseAlias{
+			"PEPE": {Name: "1000PEPE", Multiplier: 1000},
+			"BONK": {Name: "1000BONK", Multiplier: 1000},
+			"SHIB": {Name: "SHIB1000", Multiplier: 1000},
+		},
+	})
+	Instruments.RegisterVenue("bybit_spot", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractSpot,
+		Format:   concatFormat,
+	})
+
+	RegisterMetadata("bybit_futures", bybitMetadata("linear"))
+	RegisterMetadata("bybit_spot", bybitMetadata("spot"))
+}
+
+type bybitInstrumentsInfo struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Result  struct {
+		List []struct {
+			Symbol      string `json:"symbol"`
+			PriceFilter struct {
+				TickSize string `json:"tickSize"`
+			} `json:"priceFilter"`
+			LotSizeFilter struct {
+				QtyStep       string `json:"qtyStep"`       // linear
+				BasePrecision string `json:"basePrecision"` // spot
+				MinOrderQty   string `json:"minOrderQty"`
+			} `json:"lotSizeFilter"`
+		} `json:"list"`
+	} `json:"result"`
+}
+
+// bybitMetadata queries instruments-info per symbol, which avoids paging
+// through the full listing. Bybit quantities are in the base asset.
+func bybitMetadata(category string) MetadataFetcher {
+	return func(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+		var specs []InstrumentSpec
+		for _, nativeID := range nativeIDs {
+			var info bybitInstrumentsInfo
+			url := "https://api.bybit.com/v5/market/instruments-info?category=" + category + "&symbol=" + nativeID
+			if err := getJSON(ctx, url, &info); err != nil {
+				return nil, err
+			}
+			if info.RetCode != 0 {
+				return nil, fmt.Errorf("instruments-info %s: %s", nativeID, info.RetMsg)
+			}
+
+			for _, instrument := range info.Result.List {
+				lotSize := instrument.LotSizeFilter.QtyStep
+				if lotSize == "" {
+					lotSize = instrument.LotSizeFilter.BasePrecision
+				}
+				specs = append(specs, InstrumentSpec{
+					NativeID:     instrument.Symbol,
+					TickSize:     parseFloatOrZero(instrument.PriceFilter.TickSize),
+					LotSize:      parseFloatOrZero(lotSize),
+					MinSize:      parseFloatOrZero(instrument.LotSizeFilter.MinOrderQty),
+					ContractSize: 1,
+				})
+			}
+		}
+		return specs, nil
+	}
+}
+
+// applyBybitBook applies an orderbook.N snapshot or delta to the local book
+// for symbol. Bybit sends a fresh snapshot after every (re)subscribe, which
+// replaces whatever the previous connection left behind.
+func applyBybitBook(books map[string]*OrderBook, updateType, symbol string, rawBids, rawAsks [][]string) (*OrderBook, error) {
+	bids, err := parseStringLevels(rawBids)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := parseStringLevels(rawAsks)
+	if err != nil {
+		return nil, err
+	}
+
+	book, exists := books[symbol]
+	if !exists {
+		book = NewOrderBook()
+		books[symbol] = book
+	}
+
+	if updateType == "snapshot" {
+		book.ApplySnapshot(bids, asks)
+	} else {
+		book.ApplyDelta(bids, asks)
+	}
+	return book, nil
+}
+
+func ConnectBybitFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint("bybit_futures", "wss://stream.bybit.com/v5/public/linear")
+	instruments := resolveSymbols("bybit_futures", symbols)
+	depth := optionsFor("bybit_futures").Depth
+
+	// Local books rebuilt from each connection's snapshot and deltas
+	books := make(map[string]*OrderBook)
+
+	sup := NewSupervisor("bybit_futures")
+	defer sup.Stopped()
 
 	for {
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			log.Printf("Bybit futures connection error: %v", err)
-			time.Sleep(5 * time.Second)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("bybit_futures").StaleTimeout)
+
 		log.Printf("Connected to Bybit futures WebSocket")
 
 		subscribeMsg := map[string]interface{}{
 			"op":   "subscribe",
-			"args": make([]string, len(symbols)*2),
+			"args": make([]string, len(instruments)*2),
 		}
 
-		for i, symbol := range symbols {
-			subscribeMsg["args"].([]string)[i*2] = fmt.Sprintf("orderbook.1.%s", symbol)
-			subscribeMsg["args"].([]string)[i*2+1] = fmt.Sprintf("publicTrade.%s", symbol)
+		for i, instrument := range instruments {
+			subscribeMsg["args"].([]string)[i*2] = fmt.Sprintf("orderbook.1.%s", instrument.NativeID)
+			subscribeMsg["args"].([]string)[i*2+1] = fmt.Sprintf("publicTrade.%s", instrument.NativeID)
 		}
 
-		err = conn.WriteJSON(subscribeMsg)
+		err = sess.WriteJSON(subscribeMsg)
 		if err != nil {
 			log.Printf("Bybit futures subscription error: %v", err)
+			sup.Disconnected(err)
+			stopClose()
 			conn.Close()
-			time.Sleep(5 * time.Second)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
+		sup.Subscribed()
+
+		// Bybit closes idle sockets unless the client pings every 20s
+		stopPing := sess.KeepAlive(20*time.Second, func() error {
+			return sess.WriteJSON(map[string]string{"op": "ping"})
+		})
+
 		for {
 			var message json.RawMessage
-			err := conn.ReadJSON(&message)
+			err := sess.ReadJSON(&message)
 			if err != nil {
-				log.Printf("Bybit futures read error: %v", err)
+				if ctx.Err() == nil {
+					log.Printf("Bybit futures read error: %v", err)
+				}
+				sup.Disconnected(err)
 				conn.Close()
 				break
 			}
+			if isBybitPong(message) {
+				continue
+			}
+			sess.Message()
+
+			receivedAt := time.Now()
 
 			// Try to parse as orderbook first
 			var orderbookMsg BybitFuturesOrderbook
-			if err := json.Unmarshal(message, &orderbookMsg); err == nil && 
-			   len(orderbookMsg.Data.Asks) > 0 && len(orderbookMsg.Data.Bids) > 0 {
-				
-				bidPrice, err1 := strconv.ParseFloat(orderbookMsg.Data.Bids[0][0], 64)
-				askPrice, err2 := strconv.ParseFloat(orderbookMsg.Data.Asks[0][0], 64)
-				if err1 != nil || err2 != nil {
+			if err := json.Unmarshal(message, &orderbookMsg); err == nil &&
+				strings.HasPrefix(orderbookMsg.Topic, "orderbook.") {
+
+				book, err := applyBybitBook(books, orderbookMsg.Type, orderbookMsg.Data.Symbol, orderbookMsg.Data.Bids, orderbookMsg.Data.Asks)
+				if err != nil {
+					log.Printf("Bybit futures orderbook error for %s: %v", orderbookMsg.Data.Symbol, err)
 					continue
 				}
 
-				orderbookData := OrderbookData{
-					Symbol:    orderbookMsg.Data.Symbol,
-					Source:    "bybit_futures",
-					BestBid:   bidPrice,
-					BestAsk:   askPrice,
-					Timestamp: time.Now().UnixMilli(),
+				instrument, ok := Instruments.Lookup("bybit_futures", orderbookMsg.Data.Symbol)
+				if !ok {
+					continue
+				}
+
+				orderbookData, ok := book.OrderbookData(instrument, depth, orderbookMsg.Timestamp, receivedAt)
+				if !ok {
+					continue
 				}
 
 				orderbookChan <- orderbookData
@@ -104,7 +250,7 @@ func ConnectBybitFutures(symbols []string, priceChan chan<- PriceData, orderbook
 			   (tradeMsg.Type == "snapshot" || tradeMsg.Type == "delta") {
 				
 				for _, trade := range tradeMsg.Data {
-					price, err := strconv.ParseFloat(trade.Price, 64)
+					price, err := ParseDecimal(trade.Price)
 					if err != nil {
 						continue
 					}
@@ -117,21 +263,23 @@ func ConnectBybitFutures(symbols []string, priceChan chan<- PriceData, orderbook
 						side = "sell"
 					}
 
-					tradeData := TradeData{
-						Symbol:    trade.Symbol,
-						Source:    "bybit_futures",
-						Price:     price,
-						Quantity:  trade.Size,
-						Side:      side,
-						Timestamp: trade.Timestamp,
+					instrument, ok := Instruments.Lookup("bybit_futures", trade.Symbol)
+					if !ok {
+						continue
 					}
 
+					tradeData := newTradeData(instrument, price, trade.Size, side, trade.Timestamp, receivedAt)
+
 					tradeChan <- tradeData
 				}
 			}
 		}
 
-		time.Sleep(2 * time.Second)
+		stopPing()
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
 	}
 }
 
@@ -150,9 +298,10 @@ type BybitSpotTrade struct {
 }
 
 type BybitSpotOrderbook struct {
-	Topic string `json:"topic"`
-	Type  string `json:"type"`
-	Data  struct {
+	Topic     string `json:"topic"`
+	Type      string `json:"type"`
+	Timestamp int64  `json:"ts"`
+	Data      struct {
 		Symbol string     `json:"s"`
 		Bids   [][]string `json:"b"`
 		Asks   [][]string `json:"a"`
@@ -162,63 +311,105 @@ type BybitSpotOrderbook struct {
 }
 
 // ConnectBybitSpot connects to Bybit spot trading WebSocket API
-func ConnectBybitSpot(symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
-	wsURL := "wss://stream.bybit.com/v5/public/spot"
+func ConnectBybitSpot(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint("bybit_spot", "wss://stream.bybit.com/v5/public/spot")
+	instruments := resolveSymbols("bybit_spot", symbols)
+	depth := optionsFor("bybit_spot").Depth
+
+	// Local books rebuilt from each connection's snapshot and deltas
+	books := make(map[string]*OrderBook)
+
+	sup := NewSupervisor("bybit_spot")
+	defer sup.Stopped()
 
 	for {
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			log.Printf("Bybit spot connection error: %v", err)
-			time.Sleep(5 * time.Second)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("bybit_spot").StaleTimeout)
+
 		log.Printf("Connected to Bybit spot WebSocket")
 
 		subscribeMsg := map[string]interface{}{
 			"op":   "subscribe",
-			"args": make([]string, len(symbols)*2),
+			"args": make([]string, len(instruments)*2),
 		}
 
-		for i, symbol := range symbols {
-			subscribeMsg["args"].([]string)[i*2] = fmt.Sprintf("orderbook.1.%s", symbol)
-			subscribeMsg["args"].([]string)[i*2+1] = fmt.Sprintf("publicTrade.%s", symbol)
+		for i, instrument := range instruments {
+			subscribeMsg["args"].([]string)[i*2] = fmt.Sprintf("orderbook.1.%s", instrument.NativeID)
+			subscribeMsg["args"].([]string)[i*2+1] = fmt.Sprintf("publicTrade.%s", instrument.NativeID)
 		}
 
-		err = conn.WriteJSON(subscribeMsg)
+		err = sess.WriteJSON(subscribeMsg)
 		if err != nil {
 			log.Printf("Bybit spot subscription error: %v", err)
+			sup.Disconnected(err)
+			stopClose()
 			conn.Close()
-			time.Sleep(5 * time.Second)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
+		sup.Subscribed()
+
+		// Bybit closes idle sockets unless the client pings every 20s
+		stopPing := sess.KeepAlive(20*time.Second, func() error {
+			return sess.WriteJSON(map[string]string{"op": "ping"})
+		})
+
 		for {
 			var message json.RawMessage
-			err := conn.ReadJSON(&message)
+			err := sess.ReadJSON(&message)
 			if err != nil {
-				log.Printf("Bybit spot read error: %v", err)
+				if ctx.Err() == nil {
+					log.Printf("Bybit spot read error: %v", err)
+				}
+				sup.Disconnected(err)
 				conn.Close()
 				break
 			}
+			if isBybitPong(message) {
+				continue
+			}
+			sess.Message()
+
+			receivedAt := time.Now()
 
 			// Try to parse as orderbook first
 			var orderbookMsg BybitSpotOrderbook
-			if err := json.Unmarshal(message, &orderbookMsg); err == nil && 
-			   len(orderbookMsg.Data.Asks) > 0 && len(orderbookMsg.Data.Bids) > 0 {
-				
-				bidPrice, err1 := strconv.ParseFloat(orderbookMsg.Data.Bids[0][0], 64)
-				askPrice, err2 := strconv.ParseFloat(orderbookMsg.Data.Asks[0][0], 64)
-				if err1 != nil || err2 != nil {
+			if err := json.Unmarshal(message, &orderbookMsg); err == nil &&
+				strings.HasPrefix(orderbookMsg.Topic, "orderbook.") {
+
+				book, err := applyBybitBook(books, orderbookMsg.Type, orderbookMsg.Data.Symbol, orderbookMsg.Data.Bids, orderbookMsg.Data.Asks)
+				if err != nil {
+					log.Printf("Bybit spot orderbook error for %s: %v", orderbookMsg.Data.Symbol, err)
 					continue
 				}
 
-				orderbookData := OrderbookData{
-					Symbol:    orderbookMsg.Data.Symbol,
-					Source:    "bybit_spot",
-					BestBid:   bidPrice,
-					BestAsk:   askPrice,
-					Timestamp: time.Now().UnixMilli(),
+				instrument, ok := Instruments.Lookup("bybit_spot", orderbookMsg.Data.Symbol)
+				if !ok {
+					continue
+				}
+
+				orderbookData, ok := book.OrderbookData(instrument, depth, orderbookMsg.Timestamp, receivedAt)
+				if !ok {
+					continue
 				}
 
 				orderbookChan <- orderbookData
@@ -231,7 +422,7 @@ func ConnectBybitSpot(symbols []string, priceChan chan<- PriceData, orderbookCha
 			   (tradeMsg.Type == "snapshot" || tradeMsg.Type == "delta") {
 				
 				for _, trade := range tradeMsg.Data {
-					price, err := strconv.ParseFloat(trade.Price, 64)
+					price, err := ParseDecimal(trade.Price)
 					if err != nil {
 						continue
 					}
@@ -244,20 +435,35 @@ func ConnectBybitSpot(symbols []string, priceChan chan<- PriceData, orderbookCha
 						side = "sell"
 					}
 
-					tradeData := TradeData{
-						Symbol:    trade.Symbol,
-						Source:    "bybit_spot",
-						Price:     price,
-						Quantity:  trade.Size,
-						Side:      side,
-						Timestamp: trade.Timestamp,
+					instrument, ok := Instruments.Lookup("bybit_spot", trade.Symbol)
+					if !ok {
+						continue
 					}
 
+					tradeData := newTradeData(instrument, price, trade.Size, side, trade.Timestamp, receivedAt)
+
 					tradeChan <- tradeData
 				}
 			}
 		}
 
-		time.Sleep(2 * time.Second)
+		stopPing()
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
+	}
+}
+
+// isBybitPong reports whether message is the reply to an op ping. Linear
+// streams answer with ret_msg "pong", spot streams with op "pong".
+func isBybitPong(message json.RawMessage) bool {
+	var reply struct {
+		Op     string `json:"op"`
+		RetMsg string `json:"ret_msg"`
+	}
+	if err := json.Unmarshal(message, &reply); err != nil {
+		return false
 	}
+	return reply.Op == "pong" || reply.RetMsg == "pong"
 }
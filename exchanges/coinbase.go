@@ -0,0 +1,337 @@
+package exchanges
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// CoinbaseMessage is a frame on the Advanced Trade market data websocket.
+// sequence_num counts every message on the connection, across channels.
+type CoinbaseMessage struct {
+	Channel     string          `json:"channel"` // l2_data, market_trades, heartbeats, subscriptions
+	Timestamp   time.Time       `json:"timestamp"`
+	SequenceNum int64           `json:"sequence_num"`
+	Events      json.RawMessage `json:"events"`
+	Type        string          `json:"type"` // error frames only
+	Message     string          `json:"message"`
+}
+
+type CoinbaseL2Event struct {
+	Type      string `json:"type"` // snapshot or update
+	ProductID string `json:"product_id"`
+	Updates   []struct {
+		Side        string `json:"side"` // bid or offer
+		PriceLevel  string `json:"price_level"`
+		NewQuantity string `json:"new_quantity"`
+	} `json:"updates"`
+}
+
+type CoinbaseTradesEvent struct {
+	Type   string `json:"type"` // snapshot or update
+	Trades []struct {
+		TradeID   string    `json:"trade_id"`
+		ProductID string    `json:"product_id"`
+		Price     string    `json:"price"`
+		Size      string    `json:"size"`
+		Side      string    `json:"side"` // BUY or SELL
+		Time      time.Time `json:"time"`
+	} `json:"trades"`
+}
+
+// CoinbaseOrderBook is a local book that is only published once a snapshot
+// has been applied
+type CoinbaseOrderBook struct {
+	*OrderBook
+	Synced bool
+}
+
+func init() {
+	Register(NewConnector("coinbase_spot", MarketSpot, nil, ConnectCoinbaseSpot))
+
+	// Coinbase spot is quoted in USD; USDC pairs share the USD books
+	// (BTC-USD)
+	Instruments.RegisterVenue("coinbase_spot", VenueSpec{
+		Quote:    "USD",
+		Contract: ContractSpot,
+		Format: func(base, quote string) string {
+			return base + "-" + quote
+		},
+	})
+
+	RegisterMetadata("coinbase_spot", coinbaseMetadata)
+}
+
+type coinbaseProducts struct {
+	Products []struct {
+		ProductID      string `json:"product_id"`
+		QuoteIncrement string `json:"quote_increment"`
+		BaseIncrement  string `json:"base_increment"`
+		BaseMinSize    string `json:"base_min_size"`
+	} `json:"products"`
+}
+
+func coinbaseMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var products coinbaseProducts
+	if err := getJSON(ctx, "https://api.coinbase.com/api/v3/brokerage/market/products?product_type=SPOT", &products); err != nil {
+		return nil, err
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, product := range products.Products {
+		if !wanted[product.ProductID] {
+			continue
+		}
+		specs = append(specs, InstrumentSpec{
+			NativeID:     product.ProductID,
+			TickSize:     parseFloatOrZero(product.QuoteIncrement),
+			LotSize:      parseFloatOrZero(product.BaseIncrement),
+			MinSize:      parseFloatOrZero(product.BaseMinSize),
+			ContractSize: 1,
+		})
+	}
+	return specs, nil
+}
+
+// ConnectCoinbaseSpot streams Coinbase Advanced Trade level2 books and
+// market trades. Subscriptions without traffic are closed by the server,
+// so the heartbeats channel is subscribed too. A gap in sequence_num means
+// a message was lost and every book is rebuilt from a new snapshot.
+func ConnectCoinbaseSpot(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint("coinbase_spot", "wss://advanced-trade-ws.coinbase.com")
+	instruments := resolveSymbols("coinbase_spot", symbols)
+	depth := optionsFor("coinbase_spot").Depth
+
+	productIDs := make([]string, len(instruments))
+	for i, instrument := range instruments {
+		productIDs[i] = instrument.NativeID
+	}
+
+	sup := NewSupervisor("coinbase_spot")
+	defer sup.Stopped()
+
+	for {
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Coinbase connection error: %v", err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("coinbase_spot").StaleTimeout)
+
+		log.Printf("Connected to Coinbase WebSocket")
+
+		books := make(map[string]*CoinbaseOrderBook)
+		for _, productID := range productIDs {
+			books[productID] = &CoinbaseOrderBook{OrderBook: NewOrderBook()}
+		}
+
+		subscribe := func(kind, channel string, productIDs []string) error {
+			request := map[string]interface{}{
+				"type":    kind,
+				"channel": channel,
+			}
+			if productIDs != nil {
+				request["product_ids"] = productIDs
+			}
+			return sess.WriteJSON(request)
+		}
+
+		err = subscribe("subscribe", "heartbeats", nil)
+		if err == nil {
+			err = subscribe("subscribe", "level2", productIDs)
+		}
+		if err == nil {
+			err = subscribe("subscribe", "market_trades", productIDs)
+		}
+		if err != nil {
+			log.Printf("Coinbase subscription error: %v", err)
+			sup.Disconnected(err)
+			stopClose()
+			conn.Close()
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		sup.Subscribed()
+
+		// resync drops the given books and resubscribes level2 for them so
+		// Coinbase sends new snapshots
+		resync := func(resynced []string, reason string) {
+			for _, productID := range resynced {
+				log.Printf("Coinbase %s book resync: %s", productID, reason)
+				sup.Resync(productID + ": " + reason)
+				books[productID].Reset()
+				books[productID].Synced = false
+			}
+
+			err := subscribe("unsubscribe", "level2", resynced)
+			if err == nil {
+				err = subscribe("subscribe", "level2", resynced)
+			}
+			if err != nil {
+				log.Printf("Coinbase resubscribe error: %v", err)
+				conn.Close()
+			}
+		}
+
+		lastSequence := int64(-1)
+		for {
+			var message CoinbaseMessage
+			err := sess.ReadJSON(&message)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Coinbase read error: %v", err)
+				}
+				sup.Disconnected(err)
+				conn.Close()
+				break
+			}
+
+			receivedAt := time.Now()
+
+			if message.Type == "error" {
+				log.Printf("Coinbase error: %s", message.Message)
+				continue
+			}
+
+			// A skipped sequence number may have been a book update
+			if message.SequenceNum != lastSequence+1 {
+				reason := fmt.Sprintf("sequence gap: expected %d, got %d", lastSequence+1, message.SequenceNum)
+				var synced []string
+				for productID, book := range books {
+					if book.Synced {
+						synced = append(synced, productID)
+					}
+				}
+				if len(synced) > 0 {
+					resync(synced, reason)
+				}
+			}
+			lastSequence = message.SequenceNum
+
+			// Heartbeats keep the sequence but don't count as data for the
+			// stale watchdog
+			if message.Channel == "heartbeats" || message.Channel == "subscriptions" {
+				continue
+			}
+			sess.Message()
+
+			switch message.Channel {
+			case "l2_data":
+				var events []CoinbaseL2Event
+				if err := json.Unmarshal(message.Events, &events); err != nil {
+					log.Printf("Coinbase level2 unmarshal error: %v", err)
+					continue
+				}
+
+				for _, event := range events {
+					book, exists := books[event.ProductID]
+					if !exists {
+						continue
+					}
+					if event.Type != "snapshot" && !book.Synced {
+						continue
+					}
+
+					var bidRows, askRows [][]string
+					for _, update := range event.Updates {
+						row := []string{update.PriceLevel, update.NewQuantity}
+						if update.Side == "bid" {
+							bidRows = append(bidRows, row)
+						} else {
+							askRows = append(askRows, row)
+						}
+					}
+					bids, err1 := parseStringLevels(bidRows)
+					asks, err2 := parseStringLevels(askRows)
+					if err1 != nil || err2 != nil {
+						continue
+					}
+
+					if event.Type == "snapshot" {
+						book.ApplySnapshot(bids, asks)
+						book.Synced = true
+					} else {
+						book.ApplyDelta(bids, asks)
+					}
+
+					if book.Crossed() {
+						resync([]string{event.ProductID}, "crossed book")
+						continue
+					}
+
+					instrument, ok := Instruments.Lookup("coinbase_spot", event.ProductID)
+					if !ok {
+						continue
+					}
+
+					orderbookData, ok := book.OrderbookData(instrument, depth, message.Timestamp.UnixMilli(), receivedAt)
+					if !ok {
+						continue
+					}
+
+					orderbookChan <- orderbookData
+				}
+
+			case "market_trades":
+				var events []CoinbaseTradesEvent
+				if err := json.Unmarshal(message.Events, &events); err != nil {
+					log.Printf("Coinbase trades unmarshal error: %v", err)
+					continue
+				}
+
+				for _, event := range events {
+					// The snapshot replays recent trades, which would double
+					// count them after a reconnect
+					if event.Type == "snapshot" {
+						continue
+					}
+
+					for _, trade := range event.Trades {
+						price, err := ParseDecimal(trade.Price)
+						if err != nil {
+							continue
+						}
+
+						instrument, ok := Instruments.Lookup("coinbase_spot", trade.ProductID)
+						if !ok {
+							continue
+						}
+
+						side := "sell"
+						if strings.EqualFold(trade.Side, "BUY") {
+							side = "buy"
+						}
+
+						tradeChan <- newTradeData(instrument, price, trade.Size, side, trade.Time.UnixMilli(), receivedAt)
+					}
+				}
+			}
+		}
+
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package exchanges
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MarketType describes what kind of market a connector streams
+type MarketType string
+
+const (
+	MarketFutures MarketType = "futures"
+	MarketSpot    MarketType = "spot"
+	MarketOracle  MarketType = "oracle"
+)
+
+// ConnectFunc is the common signature shared by every Connect* function
+type ConnectFunc func(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData)
+
+// Connector is a streaming market data source that can be started by the scanner
+type Connector interface {
+	// Name is the unique connector name, also used as the Source of emitted data
+	Name() string
+	MarketType() MarketType
+	// SupportedSymbols lists the symbols the venue can stream; nil means any symbol
+	SupportedSymbols() []string
+	// Run streams until ctx is cancelled
+	Run(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData)
+}
+
+type funcConnector struct {
+	name       string
+	marketType MarketType
+	symbols    []string
+	connect    ConnectFunc
+}
+
+func (c *funcConnector) Name() string               { return c.name }
+func (c *funcConnector) MarketType() MarketType     { return c.marketType }
+func (c *funcConnector) SupportedSymbols() []string { return c.symbols }
+
+func (c *funcConnector) Run(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	c.connect(ctx, symbols, priceChan, orderbookChan, tradeChan)
+}
+
+// NewConnector wraps a Connect* function into a Connector
+func NewConnector(name string, marketType MarketType, symbols []string, connect ConnectFunc) Connector {
+	return &funcConnector{
+		name:       name,
+		marketType: marketType,
+		symbols:    symbols,
+		connect:    connect,
+	}
+}
+
+var (
+	registry      = make(map[string]Connector)
+	registryMutex sync.RWMutex
+)
+
+// Register adds a connector to the registry. It panics on duplicate names since
+// registration happens from init functions.
+func Register(c Connector) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, exists := registry[c.Name()]; exists {
+		panic(fmt.Sprintf("exchanges: connector %q registered twice", c.Name()))
+	}
+	registry[c.Name()] = c
+}
+
+// Lookup returns the registered connector with the given name
+func Lookup(name string) (Connector, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	c, ok := registry[name]
+	return c, ok
+}
+
+// Connectors returns all registered connectors sorted by name
+func Connectors() []Connector {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	connectors := make([]Connector, 0, len(registry))
+	for _, c := range registry {
+		connectors = append(connectors, c)
+	}
+	sort.Slice(connectors, func(i, j int) bool {
+		return connectors[i].Name() < connectors[j].Name()
+	})
+	return connectors
+}
+
+// Names returns the names of all registered connectors sorted alphabetically
+func Names() []string {
+	connectors := Connectors()
+	names := make([]string, len(connectors))
+	for i, c := range connectors {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// ConnectorOptions holds runtime settings applied to a registered connector
+type ConnectorOptions struct {
+	// Endpoint overrides the connector's default websocket/SSE base URL
+	Endpoint string
+	// StaleTimeout is how long the feed may go without a message before it
+	// is marked stale and reconnected; zero means DefaultStaleTimeout
+	StaleTimeout time.Duration
+	// Depth is the number of book levels per side attached to orderbook
+	// events by connectors that keep a local book; zero sends top of book only
+	Depth int
+}
+
+var (
+	connectorOptions      = make(map[string]ConnectorOptions)
+	connectorOptionsMutex sync.RWMutex
+)
+
+// Configure sets the options for the named connector. It must be called
+// before the connector is started.
+func Configure(name string, opts ConnectorOptions) {
+	connectorOptionsMutex.Lock()
+	defer connectorOptionsMutex.Unlock()
+
+	connectorOptions[name] = opts
+}
+
+// optionsFor returns the options configured for the named connector
+func optionsFor(name string) ConnectorOptions {
+	connectorOptionsMutex.RLock()
+	defer connectorOptionsMutex.RUnlock()
+
+	return connectorOptions[name]
+}
+
+// endpoint returns the configured endpoint for the connector or defaultURL
+func endpoint(name, defaultURL string) string {
+	if opts := optionsFor(name); opts.Endpoint != "" {
+		return opts.Endpoint
+	}
+	return defaultURL
+}
+
+// FilterSymbols returns the subset of symbols the connector supports
+func FilterSymbols(c Connector, symbols []string) []string {
+	supported := c.SupportedSymbols()
+	if supported == nil {
+		return symbols
+	}
+
+	allowed := make(map[string]bool, len(supported))
+	for _, symbol := range supported {
+		allowed[symbol] = true
+	}
+
+	var filtered []string
+	for _, symbol := range symbols {
+		if allowed[symbol] {
+			filtered = append(filtered, symbol)
+		}
+	}
+	return filtered
+}
+
+// sleepContext waits for d or until ctx is cancelled. It returns false if the
+// connector should stop.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,304 @@
+package exchanges
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// decimalPlaces is the fixed precision of Decimal. Nine places covers every
+// venue's tick size, including per unit prices of 1000PEPE style contracts,
+// while leaving room for prices up to about 9.2 billion.
+const decimalPlaces = 9
+
+const decimalUnit = 1_000_000_000
+
+var errDecimalRange = errors.New("decimal out of range")
+
+// Decimal is a fixed-point price with nine decimal places. Comparisons and
+// differences are exact, so book levels can be matched by price and spreads
+// on low priced assets don't pick up float noise. A Decimal parsed from a
+// venue keeps the venue's original text, which String and the JSON encoding
+// return unchanged; results of arithmetic are formatted from the value.
+//
+// Use Equal or Cmp rather than ==, since two equal prices may carry
+// different text ("0.10" and "0.1").
+type Decimal struct {
+	units int64  // value * 10^decimalPlaces
+	text  string // venue text, empty when computed
+}
+
+// ParseDecimal parses a venue price string. Digits beyond nine decimal places
+// are rounded away, in which case the text is not kept.
+func ParseDecimal(s string) (Decimal, error) {
+	if units, exact, ok := parseDecimalUnits(s); ok {
+		if !exact {
+			return Decimal{units: units}, nil
+		}
+		return Decimal{units: units, text: s}, nil
+	}
+
+	// Exponent notation and anything else the fast path doesn't handle
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Decimal{}, err
+	}
+	d, err := DecimalFromFloat(f)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("decimal %q: %w", s, err)
+	}
+	return d, nil
+}
+
+// DecimalFromFloat rounds f to nine decimal places. Only use it for venues
+// that send prices as binary floats; strings should go through ParseDecimal.
+func DecimalFromFloat(f float64) (Decimal, error) {
+	scaled := math.Round(f * decimalUnit)
+	if math.IsNaN(scaled) || scaled >= math.MaxInt64 || scaled <= math.MinInt64 {
+		return Decimal{}, errDecimalRange
+	}
+	return Decimal{units: int64(scaled)}, nil
+}
+
+// NewDecimal returns mantissa * 10^exponent, rounding when the exponent is
+// below -9. It is exact for the (price, expo) pairs oracles like Pyth publish.
+func NewDecimal(mantissa int64, exponent int) (Decimal, error) {
+	shift := exponent + decimalPlaces
+	units := mantissa
+	switch {
+	case shift > 0:
+		for ; shift > 0; shift-- {
+			if units > math.MaxInt64/10 || units < math.MinInt64/10 {
+				return Decimal{}, errDecimalRange
+			}
+			units *= 10
+		}
+	case shift < 0:
+		if shift < -18 {
+			return Decimal{}, nil
+		}
+		units = divRound(units, pow10(-shift))
+	}
+	return Decimal{units: units}, nil
+}
+
+// parseDecimalUnits handles plain [-+]digits[.digits] strings without going
+// through float64. exact is false when digits had to be rounded away.
+func parseDecimalUnits(s string) (units int64, exact, ok bool) {
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return 0, false, false
+	}
+
+	for i := 0; i < len(whole); i++ {
+		c := whole[i]
+		if c < '0' || c > '9' {
+			return 0, false, false
+		}
+		if units > (math.MaxInt64/decimalUnit-10)/10 {
+			return 0, false, false
+		}
+		units = units*10 + int64(c-'0')
+	}
+	units *= decimalUnit
+
+	var frac int64
+	scale := int64(decimalUnit)
+	roundUp := false
+	exact = true
+	for i := 0; i < len(fraction); i++ {
+		c := fraction[i]
+		if c < '0' || c > '9' {
+			return 0, false, false
+		}
+		if i < decimalPlaces {
+			scale /= 10
+			frac += int64(c-'0') * scale
+			continue
+		}
+		if i == decimalPlaces {
+			roundUp = c >= '5'
+		}
+		if c != '0' {
+			exact = false
+		}
+	}
+	units += frac
+	if roundUp {
+		units++
+	}
+
+	if negative {
+		units = -units
+	}
+	return units, exact, true
+}
+
+// maxExactUnits is the largest magnitude of units a float64 holds exactly
+const maxExactUnits = 1 << 53
+
+// Float64 returns the nearest float, for display and ratios. Below 2^53
+// units both operands of the division are exact, so the single rounding of
+// the division gives the correctly rounded result; larger values go through
+// ParseFloat.
+func (d Decimal) Float64() float64 {
+	if d.units <= maxExactUnits && d.units >= -maxExactUnits {
+		return float64(d.units) / decimalUnit
+	}
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// IsZero reports whether the value is zero
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+// Sign returns -1, 0 or 1
+func (d Decimal) Sign() int {
+	switch {
+	case d.units < 0:
+		return -1
+	case d.units > 0:
+		return 1
+	}
+	return 0
+}
+
+// Cmp compares two decimals by value, returning -1, 0 or 1
+func (d Decimal) Cmp(other Decimal) int {
+	switch {
+	case d.units < other.units:
+		return -1
+	case d.units > other.units:
+		return 1
+	}
+	return 0
+}
+
+// Equal reports whether two decimals have the same value
+func (d Decimal) Equal(other Decimal) bool {
+	return d.units == other.units
+}
+
+// Less reports whether d is below other
+func (d Decimal) Less(other Decimal) bool {
+	return d.units < other.units
+}
+
+// Add returns d + other
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{units: d.units + other.units}
+}
+
+// Sub returns d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{units: d.units - other.units}
+}
+
+// Mid returns the midpoint of d and other, rounded half away from zero at
+// the ninth decimal place
+func (d Decimal) Mid(other Decimal) Decimal {
+	return Decimal{units: divRound(d.units+other.units, 2)}
+}
+
+// DivInt returns d / n rounded to nine decimal places; n must be positive
+func (d Decimal) DivInt(n int64) Decimal {
+	if n <= 1 {
+		return d
+	}
+	return Decimal{units: divRound(d.units, n)}
+}
+
+// String returns the venue text when there is one, and otherwise the value
+// without trailing zeros
+func (d Decimal) String() string {
+	if d.text != "" {
+		return d.text
+	}
+
+	units := d.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+	}
+	whole := units / decimalUnit
+	frac := units % decimalUnit
+	if whole < 0 {
+		whole = -whole
+	}
+	if frac < 0 {
+		frac = -frac
+	}
+
+	if frac == 0 {
+		return sign + strconv.FormatInt(whole, 10)
+	}
+	fraction := strings.TrimRight(fmt.Sprintf("%09d", frac), "0")
+	return sign + strconv.FormatInt(whole, 10) + "." + fraction
+}
+
+// MarshalJSON encodes the decimal as a string so no precision is lost on the
+// way to the UI
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON accepts both quoted strings and bare JSON numbers. A number
+// is parsed from its literal text, never through float64.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	if s == "" {
+		*d = Decimal{}
+		return nil
+	}
+
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// divRound divides by a positive divisor, rounding half away from zero
+func divRound(value, divisor int64) int64 {
+	quotient := value / divisor
+	remainder := value % divisor
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	if remainder*2 >= divisor {
+		if value < 0 {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+	return quotient
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for ; n > 0; n-- {
+		result *= 10
+	}
+	return result
+}
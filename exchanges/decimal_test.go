@@ -0,0 +1,183 @@
+package exchanges
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in    string
+		units int64
+		text  string // String() result
+	}{
+		{"0", 0, "0"},
+		{"1", 1_000_000_000, "1"},
+		{"0.1", 100_000_000, "0.1"},
+		{"0.10", 100_000_000, "0.10"},
+		{"+2.5", 2_500_000_000, "+2.5"},
+		{"-2.5", -2_500_000_000, "-2.5"},
+		{".5", 500_000_000, ".5"},
+		{"5.", 5_000_000_000, "5."},
+		{"67123.4", 67_123_400_000_000, "67123.4"},
+		{"0.000000001", 1, "0.000000001"},
+		{"0.00000000100", 1, "0.00000000100"},
+		// Past nine places the value is rounded half away from zero and the
+		// text is dropped
+		{"0.1234567894", 123_456_789, "0.123456789"},
+		{"0.1234567895", 123_456_790, "0.12345679"},
+		{"0.12345678949", 123_456_789, "0.123456789"},
+		{"-0.0000000005", -1, "-0.000000001"},
+		{"-0.0000000004", 0, "0"},
+		{"0.9999999999", 1_000_000_000, "1"},
+		// Exponent notation goes through the float path
+		{"1e-3", 1_000_000, "0.001"},
+		{"2.5E2", 250_000_000_000, "250"},
+	}
+	for _, test := range tests {
+		d, err := ParseDecimal(test.in)
+		if err != nil {
+			t.Errorf("ParseDecimal(%q): %v", test.in, err)
+			continue
+		}
+		if d.units != test.units {
+			t.Errorf("ParseDecimal(%q) units = %d, want %d", test.in, d.units, test.units)
+		}
+		if got := d.String(); got != test.text {
+			t.Errorf("ParseDecimal(%q).String() = %q, want %q", test.in, got, test.text)
+		}
+	}
+}
+
+func TestParseDecimalErrors(t *testing.T) {
+	for _, in := range []string{"", "-", ".", "abc", "1.2.3", "1,5", "NaN", "1e300"} {
+		if d, err := ParseDecimal(in); err == nil {
+			t.Errorf("ParseDecimal(%q) = %v, want an error", in, d)
+		}
+	}
+}
+
+func TestNewDecimal(t *testing.T) {
+	tests := []struct {
+		mantissa int64
+		exponent int
+		want     string
+	}{
+		{12345, -2, "123.45"},
+		{-12345, -2, "-123.45"},
+		{6712345000000, -8, "67123.45"},
+		{1, 3, "1000"},
+		{7, 0, "7"},
+		{5, -10, "0.000000001"},
+		{4, -10, "0"},
+		{-5, -10, "-0.000000001"},
+		{123456789123, -12, "0.123456789"},
+		{1, -30, "0"},
+	}
+	for _, test := range tests {
+		d, err := NewDecimal(test.mantissa, test.exponent)
+		if err != nil {
+			t.Errorf("NewDecimal(%d, %d): %v", test.mantissa, test.exponent, err)
+			continue
+		}
+		if got := d.String(); got != test.want {
+			t.Errorf("NewDecimal(%d, %d) = %s, want %s", test.mantissa, test.exponent, got, test.want)
+		}
+	}
+
+	if _, err := NewDecimal(math.MaxInt64, 0); err == nil {
+		t.Error("NewDecimal(MaxInt64, 0) did not overflow")
+	}
+	if _, err := NewDecimal(1, 10); err == nil {
+		t.Error("NewDecimal(1, 10) did not overflow")
+	}
+	if d, err := NewDecimal(1, 9); err != nil || d.String() != "1000000000" {
+		t.Errorf("NewDecimal(1, 9) = %v, %v", d, err)
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	a := mustDecimal(t, "0.5")
+	b := mustDecimal(t, "0.50")
+	if !a.Equal(b) || a.Cmp(b) != 0 || a.Less(b) {
+		t.Error("0.5 and 0.50 compare unequal")
+	}
+
+	bid := mustDecimal(t, "0.5123")
+	ask := mustDecimal(t, "0.5124")
+	if got := ask.Sub(bid).String(); got != "0.0001" {
+		t.Errorf("Sub = %s, want 0.0001", got)
+	}
+	if got := bid.Add(ask).String(); got != "1.0247" {
+		t.Errorf("Add = %s, want 1.0247", got)
+	}
+	if got := mustDecimal(t, "0.000000001").Mid(mustDecimal(t, "0.000000002")).String(); got != "0.000000002" {
+		t.Errorf("Mid = %s, want 0.000000002", got)
+	}
+	if got := mustDecimal(t, "100").DivInt(3).String(); got != "33.333333333" {
+		t.Errorf("DivInt = %s, want 33.333333333", got)
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	type message struct {
+		Price Decimal  `json:"price"`
+		Size  *Decimal `json:"size,omitempty"`
+	}
+
+	tests := []struct {
+		in, out string
+	}{
+		// Venue text survives the round trip
+		{`{"price":"0.10"}`, `{"price":"0.10"}`},
+		{`{"price":"67123.40"}`, `{"price":"67123.40"}`},
+		// Bare numbers are parsed from their literal text
+		{`{"price":0.1}`, `{"price":"0.1"}`},
+		{`{"price":1.0494}`, `{"price":"1.0494"}`},
+		{`{"price":""}`, `{"price":"0"}`},
+		{`{"price":null}`, `{"price":"0"}`},
+	}
+	for _, test := range tests {
+		var m message
+		if err := json.Unmarshal([]byte(test.in), &m); err != nil {
+			t.Errorf("Unmarshal(%s): %v", test.in, err)
+			continue
+		}
+		out, err := json.Marshal(m)
+		if err != nil {
+			t.Errorf("Marshal(%s): %v", test.in, err)
+			continue
+		}
+		if string(out) != test.out {
+			t.Errorf("round trip of %s = %s, want %s", test.in, out, test.out)
+		}
+	}
+
+	var m message
+	if err := json.Unmarshal([]byte(`{"price":"abc"}`), &m); err == nil {
+		t.Error("Unmarshal of a malformed price did not fail")
+	}
+}
+
+func TestDecimalFloat64(t *testing.T) {
+	// Float64 must match the correctly rounded parse of the same text
+	for _, s := range []string{
+		"0", "0.1", "0.3", "1.0494", "1.118", "0.5123", "2.2002",
+		"67123.4", "30000.1", "-1.0494", "0.000000001", "123456.789012345",
+		"9007199.254740993", "9000000000.123456789", "-9000000000.123456789",
+	} {
+		d := mustDecimal(t, s)
+		want, _ := strconv.ParseFloat(d.String(), 64)
+		if got := d.Float64(); got != want {
+			t.Errorf("Float64(%s) = %v, want %v", s, got, want)
+		}
+	}
+
+	// Computed values have no text but must still round correctly
+	mid := mustDecimal(t, "1.0493").Mid(mustDecimal(t, "1.0495"))
+	if got := mid.Float64(); got != 1.0494 {
+		t.Errorf("Float64 of mid = %v, want 1.0494", got)
+	}
+}
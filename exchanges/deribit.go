@@ -0,0 +1,398 @@
+package exchanges
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// deribitMessage is the JSON-RPC envelope of everything Deribit sends:
+// responses carry id and result or error, notifications carry method and params
+type deribitMessage struct {
+	ID     int64  `json:"id"`
+	Method string `json:"method"`
+	Params struct {
+		Channel string          `json:"channel"`
+		Type    string          `json:"type"` // heartbeat kind
+		Data    json.RawMessage `json:"data"`
+	} `json:"params"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type DeribitTicker struct {
+	InstrumentName string  `json:"instrument_name"`
+	BestBidPrice   Decimal `json:"best_bid_price"`
+	BestAskPrice   Decimal `json:"best_ask_price"`
+	BestBidAmount  float64 `json:"best_bid_amount"`
+	BestAskAmount  float64 `json:"best_ask_amount"`
+	Timestamp      int64   `json:"timestamp"`
+}
+
+type DeribitTrade struct {
+	InstrumentName string      `json:"instrument_name"`
+	Price          Decimal     `json:"price"`
+	Amount         json.Number `json:"amount"`
+	Direction      string      `json:"direction"`
+	Timestamp      int64       `json:"timestamp"`
+}
+
+type DeribitBook struct {
+	Type           string              `json:"type"` // "snapshot" or "change"
+	InstrumentName string              `json:"instrument_name"`
+	ChangeID       int64               `json:"change_id"`
+	PrevChangeID   int64               `json:"prev_change_id"`
+	Bids           []DeribitBookChange `json:"bids"`
+	Asks           []DeribitBookChange `json:"asks"`
+	Timestamp      int64               `json:"timestamp"`
+}
+
+// DeribitBookChange is one ["new"|"change"|"delete", price, amount] entry
+type DeribitBookChange struct {
+	Action string
+	Price  Decimal
+	Amount float64
+}
+
+func (c *DeribitBookChange) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) < 3 {
+		return fmt.Errorf("deribit book entry %s: want 3 fields", data)
+	}
+	if err := json.Unmarshal(fields[0], &c.Action); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[1], &c.Price); err != nil {
+		return err
+	}
+	return json.Unmarshal(fields[2], &c.Amount)
+}
+
+// deribitLevels converts book changes into levels; deletes become zero sizes
+func deribitLevels(changes []DeribitBookChange) []PriceLevel {
+	levels := make([]PriceLevel, len(changes))
+	for i, change := range changes {
+		levels[i] = PriceLevel{Price: change.Price, Size: change.Amount}
+		if change.Action == "delete" {
+			levels[i].Size = 0
+		}
+	}
+	return levels
+}
+
+// DeribitOrderBook is a local book plus the change ID it is synced to
+type DeribitOrderBook struct {
+	*OrderBook
+	ChangeID int64
+	Synced   bool
+}
+
+func init() {
+	Register(NewConnector("deribit_futures", MarketFutures, []string{"BTCUSDT", "ETHUSDT"}, ConnectDeribit))
+
+	// Deribit's BTC and ETH perps are inverse: USD quoted, sized in USD and
+	// settled in the coin (BTC-PERPETUAL)
+	Instruments.RegisterVenue("deribit_futures", VenueSpec{
+		Quote:    "USD",
+		Contract: ContractPerpetual,
+		Inverse:  true,
+		Format: func(base, quote string) string {
+			return base + "-PERPETUAL"
+		},
+	})
+
+	RegisterMetadata("deribit_futures", deribitMetadata)
+}
+
+type deribitInstruments struct {
+	Result []struct {
+		InstrumentName string  `json:"instrument_name"`
+		TickSize       float64 `json:"tick_size"`
+		MinTradeAmount float64 `json:"min_trade_amount"`
+	} `json:"result"`
+}
+
+// deribitMetadata loads futures per currency. Amounts on the inverse perps
+// are in USD, so the contract size stays 1 and NormalizeSize divides by the
+// price.
+func deribitMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	currencies := make(map[string]bool)
+	for _, nativeID := range nativeIDs {
+		currency, _, _ := strings.Cut(nativeID, "-")
+		currencies[currency] = true
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for currency := range currencies {
+		var instruments deribitInstruments
+		url := "https://www.deribit.com/api/v2/public/get_instruments?kind=future&currency=" + currency
+		if err := getJSON(ctx, url, &instruments); err != nil {
+			return nil, err
+		}
+
+		for _, instrument := range instruments.Result {
+			if !wanted[instrument.InstrumentName] {
+				continue
+			}
+			specs = append(specs, InstrumentSpec{
+				NativeID:     instrument.InstrumentName,
+				TickSize:     instrument.TickSize,
+				LotSize:      instrument.MinTradeAmount,
+				MinSize:      instrument.MinTradeAmount,
+				ContractSize: 1,
+			})
+		}
+	}
+	return specs, nil
+}
+
+// ConnectDeribit streams Deribit perpetuals over the JSON-RPC websocket. Top
+// of book comes from the ticker channel; when a depth is configured the full
+// book channel is maintained locally instead so levels can be sent.
+func ConnectDeribit(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint("deribit_futures", "wss://www.deribit.com/ws/api/v2")
+	instruments := resolveSymbols("deribit_futures", symbols)
+	depth := optionsFor("deribit_futures").Depth
+
+	books := make(map[string]*DeribitOrderBook)
+
+	sup := NewSupervisor("deribit_futures")
+	defer sup.Stopped()
+
+	for {
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Deribit connection error: %v", err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("deribit_futures").StaleTimeout)
+
+		log.Printf("Connected to Deribit WebSocket")
+
+		var requestID int64
+		call := func(method string, params interface{}) error {
+			requestID++
+			return sess.WriteJSON(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"id":      requestID,
+				"method":  method,
+				"params":  params,
+			})
+		}
+
+		var channels []string
+		for _, instrument := range instruments {
+			channels = append(channels,
+				"ticker."+instrument.NativeID+".100ms",
+				"trades."+instrument.NativeID+".100ms",
+			)
+			if depth > 0 {
+				channels = append(channels, "book."+instrument.NativeID+".100ms")
+				books[instrument.NativeID] = &DeribitOrderBook{OrderBook: NewOrderBook()}
+			}
+		}
+
+		// Deribit sends a test_request every interval and drops the socket
+		// unless it is answered with public/test
+		err = call("public/set_heartbeat", map[string]interface{}{"interval": 15})
+		if err == nil {
+			err = call("public/subscribe", map[string]interface{}{"channels": channels})
+		}
+		if err != nil {
+			log.Printf("Deribit subscription error: %v", err)
+			sup.Disconnected(err)
+			stopClose()
+			conn.Close()
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		sup.Subscribed()
+
+		// resync drops a book and resubscribes its channel for a new snapshot
+		resync := func(book *DeribitOrderBook, nativeID, reason string) {
+			log.Printf("Deribit %s book resync: %s", nativeID, reason)
+			sup.Resync(nativeID + ": " + reason)
+			book.Reset()
+			book.Synced = false
+
+			channel := []string{"book." + nativeID + ".100ms"}
+			err := call("public/unsubscribe", map[string]interface{}{"channels": channel})
+			if err == nil {
+				err = call("public/subscribe", map[string]interface{}{"channels": channel})
+			}
+			if err != nil {
+				log.Printf("Deribit resubscribe error for %s: %v", nativeID, err)
+				conn.Close()
+			}
+		}
+
+		for {
+			var message deribitMessage
+			err := sess.ReadJSON(&message)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Deribit read error: %v", err)
+				}
+				sup.Disconnected(err)
+				conn.Close()
+				break
+			}
+
+			receivedAt := time.Now()
+
+			if message.Error != nil {
+				log.Printf("Deribit request %d error: %d %s", message.ID, message.Error.Code, message.Error.Message)
+				continue
+			}
+
+			switch message.Method {
+			case "heartbeat":
+				if message.Params.Type == "test_request" {
+					if err := call("public/test", map[string]interface{}{}); err != nil {
+						log.Printf("Deribit heartbeat reply error: %v", err)
+					}
+				}
+				continue
+			case "subscription":
+			default:
+				continue
+			}
+			// Heartbeats and request replies don't count as data for the
+			// stale watchdog
+			sess.Message()
+
+			channel := message.Params.Channel
+			switch {
+			case strings.HasPrefix(channel, "ticker."):
+				if depth > 0 {
+					// The local book is the top of book source
+					continue
+				}
+
+				var ticker DeribitTicker
+				if err := json.Unmarshal(message.Params.Data, &ticker); err != nil {
+					log.Printf("Deribit ticker unmarshal error: %v", err)
+					continue
+				}
+				if ticker.BestBidPrice.Sign() <= 0 || ticker.BestAskPrice.Sign() <= 0 {
+					continue
+				}
+
+				instrument, ok := Instruments.Lookup("deribit_futures", ticker.InstrumentName)
+				if !ok {
+					continue
+				}
+
+				orderbookChan <- OrderbookData{
+					Symbol:      instrument.Symbol,
+					Source:      "deribit_futures",
+					BestBid:     instrument.NormalizePrice(ticker.BestBidPrice),
+					BestAsk:     instrument.NormalizePrice(ticker.BestAskPrice),
+					BestBidSize: instrument.NormalizeSize(ticker.BestBidAmount, ticker.BestBidPrice),
+					BestAskSize: instrument.NormalizeSize(ticker.BestAskAmount, ticker.BestAskPrice),
+					Timestamp:   ticker.Timestamp,
+					ReceivedAt:  receivedAt,
+				}
+
+			case strings.HasPrefix(channel, "book."):
+				var update DeribitBook
+				if err := json.Unmarshal(message.Params.Data, &update); err != nil {
+					log.Printf("Deribit book unmarshal error: %v", err)
+					continue
+				}
+
+				book, exists := books[update.InstrumentName]
+				if !exists {
+					continue
+				}
+
+				if update.Type == "snapshot" {
+					book.ApplySnapshot(deribitLevels(update.Bids), deribitLevels(update.Asks))
+					book.Synced = true
+				} else {
+					if !book.Synced {
+						continue
+					}
+					// Each change names the one before it; a mismatch means a
+					// lost update
+					if update.PrevChangeID != book.ChangeID {
+						resync(book, update.InstrumentName, fmt.Sprintf("change id gap: expected %d, got %d", book.ChangeID, update.PrevChangeID))
+						continue
+					}
+					book.ApplyDelta(deribitLevels(update.Bids), deribitLevels(update.Asks))
+				}
+				book.ChangeID = update.ChangeID
+
+				if book.Crossed() {
+					resync(book, update.InstrumentName, "crossed book")
+					continue
+				}
+
+				instrument, ok := Instruments.Lookup("deribit_futures", update.InstrumentName)
+				if !ok {
+					continue
+				}
+
+				orderbookData, ok := book.OrderbookData(instrument, depth, update.Timestamp, receivedAt)
+				if !ok {
+					continue
+				}
+
+				orderbookChan <- orderbookData
+
+			case strings.HasPrefix(channel, "trades."):
+				var trades []DeribitTrade
+				if err := json.Unmarshal(message.Params.Data, &trades); err != nil {
+					log.Printf("Deribit trades unmarshal error: %v", err)
+					continue
+				}
+
+				for _, trade := range trades {
+					instrument, ok := Instruments.Lookup("deribit_futures", trade.InstrumentName)
+					if !ok {
+						continue
+					}
+
+					// Deribit reports the taker direction as "buy" or "sell"
+					side := "sell"
+					if trade.Direction == "buy" {
+						side = "buy"
+					}
+
+					tradeChan <- newTradeData(instrument, trade.Price, trade.Amount.String(), side, trade.Timestamp, receivedAt)
+				}
+			}
+		}
+
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
+	}
+}
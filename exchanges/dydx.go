@@ -0,0 +1,341 @@
+package exchanges
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DydxMessage is a frame from the v4 indexer websocket. message_id counts
+// every message on the connection, across channels.
+type DydxMessage struct {
+	Type      string          `json:"type"` // connected, subscribed, channel_data, unsubscribed, error
+	MessageID int64           `json:"message_id"`
+	Channel   string          `json:"channel"`
+	ID        string          `json:"id"`
+	Message   string          `json:"message"`
+	Contents  json.RawMessage `json:"contents"`
+}
+
+// DydxBookSnapshot is the orderbook sent on subscribe; levels are objects
+type DydxBookSnapshot struct {
+	Bids []struct {
+		Price string `json:"price"`
+		Size  string `json:"size"`
+	} `json:"bids"`
+	Asks []struct {
+		Price string `json:"price"`
+		Size  string `json:"size"`
+	} `json:"asks"`
+}
+
+// DydxBookUpdate is an incremental update; levels are [price, size] pairs and
+// a zero size removes the level
+type DydxBookUpdate struct {
+	Bids [][]string `json:"bids"`
+	Asks [][]string `json:"asks"`
+}
+
+type DydxTrades struct {
+	Trades []struct {
+		ID        string    `json:"id"`
+		Side      string    `json:"side"` // taker side, BUY or SELL
+		Size      string    `json:"size"`
+		Price     string    `json:"price"`
+		CreatedAt time.Time `json:"createdAt"`
+	} `json:"trades"`
+}
+
+// DydxOrderBook is a local book that is only published once a snapshot
+// has been applied
+type DydxOrderBook struct {
+	*OrderBook
+	Synced bool
+}
+
+func init() {
+	Register(NewConnector("dydx_futures", MarketFutures, nil, ConnectDydxFutures))
+
+	// dYdX v4 perps are USD quoted and USDC settled (BTC-USD)
+	Instruments.RegisterVenue("dydx_futures", VenueSpec{
+		Quote:      "USD",
+		Settlement: "USDC",
+		Contract:   ContractPerpetual,
+		Format: func(base, quote string) string {
+			return base + "-" + quote
+		},
+	})
+
+	RegisterMetadata("dydx_futures", dydxMetadata)
+}
+
+type dydxMarkets struct {
+	Markets map[string]struct {
+		Ticker   string `json:"ticker"`
+		TickSize string `json:"tickSize"`
+		StepSize string `json:"stepSize"`
+	} `json:"markets"`
+}
+
+// dydxMetadata loads perpetual markets from the indexer; sizes are in the
+// base asset
+func dydxMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var markets dydxMarkets
+	if err := getJSON(ctx, "https://indexer.dydx.trade/v4/perpetualMarkets", &markets); err != nil {
+		return nil, err
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, market := range markets.Markets {
+		if !wanted[market.Ticker] {
+			continue
+		}
+		stepSize := parseFloatOrZero(market.StepSize)
+		specs = append(specs, InstrumentSpec{
+			NativeID:     market.Ticker,
+			TickSize:     parseFloatOrZero(market.TickSize),
+			LotSize:      stepSize,
+			MinSize:      stepSize,
+			ContractSize: 1,
+		})
+	}
+	return specs, nil
+}
+
+// ConnectDydxFutures streams dYdX v4 perpetuals from the indexer websocket.
+// v4_orderbook sends a snapshot on subscribe and incremental updates after
+// it; the indexer carries no per book sequence, so a gap in message_id or a
+// crossed book triggers a resubscribe.
+func ConnectDydxFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint("dydx_futures", "wss://indexer.dydx.trade/v4/ws")
+	instruments := resolveSymbols("dydx_futures", symbols)
+	depth := optionsFor("dydx_futures").Depth
+
+	books := make(map[string]*DydxOrderBook)
+
+	sup := NewSupervisor("dydx_futures")
+	defer sup.Stopped()
+
+	for {
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("dYdX connection error: %v", err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("dydx_futures").StaleTimeout)
+
+		log.Printf("Connected to dYdX WebSocket")
+
+		for _, instrument := range instruments {
+			books[instrument.NativeID] = &DydxOrderBook{OrderBook: NewOrderBook()}
+			for _, channel := range []string{"v4_orderbook", "v4_trades"} {
+				if err == nil {
+					err = sess.WriteJSON(map[string]interface{}{
+						"type":    "subscribe",
+						"channel": channel,
+						"id":      instrument.NativeID,
+					})
+				}
+			}
+		}
+		if err != nil {
+			log.Printf("dYdX subscription error: %v", err)
+			sup.Disconnected(err)
+			stopClose()
+			conn.Close()
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		sup.Subscribed()
+
+		// resync drops a book and resubscribes its channel for a new snapshot
+		resync := func(book *DydxOrderBook, market, reason string) {
+			log.Printf("dYdX %s book resync: %s", market, reason)
+			sup.Resync(market + ": " + reason)
+			book.Reset()
+			book.Synced = false
+
+			err := sess.WriteJSON(map[string]interface{}{
+				"type":    "unsubscribe",
+				"channel": "v4_orderbook",
+				"id":      market,
+			})
+			if err == nil {
+				err = sess.WriteJSON(map[string]interface{}{
+					"type":    "subscribe",
+					"channel": "v4_orderbook",
+					"id":      market,
+				})
+			}
+			if err != nil {
+				log.Printf("dYdX resubscribe error for %s: %v", market, err)
+				conn.Close()
+			}
+		}
+
+		var lastMessageID int64
+		for {
+			var message DydxMessage
+			err := sess.ReadJSON(&message)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("dYdX read error: %v", err)
+				}
+				sup.Disconnected(err)
+				conn.Close()
+				break
+			}
+
+			receivedAt := time.Now()
+
+			// A skipped message_id may have been a book update on any market
+			if message.Type != "connected" && message.MessageID != lastMessageID+1 {
+				reason := fmt.Sprintf("message id gap: expected %d, got %d", lastMessageID+1, message.MessageID)
+				for market, book := range books {
+					if book.Synced {
+						resync(book, market, reason)
+					}
+				}
+			}
+			lastMessageID = message.MessageID
+
+			switch message.Type {
+			case "error":
+				log.Printf("dYdX error: %s", message.Message)
+				continue
+			case "subscribed", "channel_data":
+			default:
+				continue
+			}
+			sess.Message()
+
+			switch message.Channel {
+			case "v4_orderbook":
+				book, exists := books[message.ID]
+				if !exists {
+					continue
+				}
+
+				if message.Type == "subscribed" {
+					var snapshot DydxBookSnapshot
+					if err := json.Unmarshal(message.Contents, &snapshot); err != nil {
+						log.Printf("dYdX book snapshot unmarshal error: %v", err)
+						continue
+					}
+
+					bids := make([][]string, len(snapshot.Bids))
+					for i, level := range snapshot.Bids {
+						bids[i] = []string{level.Price, level.Size}
+					}
+					asks := make([][]string, len(snapshot.Asks))
+					for i, level := range snapshot.Asks {
+						asks[i] = []string{level.Price, level.Size}
+					}
+
+					bidLevels, err1 := parseStringLevels(bids)
+					askLevels, err2 := parseStringLevels(asks)
+					if err1 != nil || err2 != nil {
+						continue
+					}
+					book.ApplySnapshot(bidLevels, askLevels)
+					book.Synced = true
+				} else {
+					if !book.Synced {
+						continue
+					}
+
+					var update DydxBookUpdate
+					if err := json.Unmarshal(message.Contents, &update); err != nil {
+						log.Printf("dYdX book update unmarshal error: %v", err)
+						continue
+					}
+
+					bidLevels, err1 := parseStringLevels(update.Bids)
+					askLevels, err2 := parseStringLevels(update.Asks)
+					if err1 != nil || err2 != nil {
+						continue
+					}
+					book.ApplyDelta(bidLevels, askLevels)
+				}
+
+				// The indexer does not always remove filled levels promptly, so
+				// a crossed book is rebuilt rather than published
+				if book.Crossed() {
+					resync(book, message.ID, "crossed book")
+					continue
+				}
+
+				instrument, ok := Instruments.Lookup("dydx_futures", message.ID)
+				if !ok {
+					continue
+				}
+
+				// Book messages carry no exchange timestamp, so no latency is
+				// recorded for them
+				orderbookData, ok := book.OrderbookData(instrument, depth, 0, receivedAt)
+				if !ok {
+					continue
+				}
+
+				orderbookChan <- orderbookData
+
+			case "v4_trades":
+				// The subscribe response replays recent history, which would
+				// double count trades after a reconnect
+				if message.Type == "subscribed" {
+					continue
+				}
+
+				var trades DydxTrades
+				if err := json.Unmarshal(message.Contents, &trades); err != nil {
+					log.Printf("dYdX trades unmarshal error: %v", err)
+					continue
+				}
+
+				instrument, ok := Instruments.Lookup("dydx_futures", message.ID)
+				if !ok {
+					continue
+				}
+
+				for _, trade := range trades.Trades {
+					price, err := ParseDecimal(trade.Price)
+					if err != nil {
+						continue
+					}
+
+					side := "sell"
+					if trade.Side == "BUY" {
+						side = "buy"
+					}
+
+					tradeChan <- newTradeData(instrument, price, trade.Size, side, trade.CreatedAt.UnixMilli(), receivedAt)
+				}
+			}
+		}
+
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
+	}
+}
@@ -1,11 +1,10 @@
 package exchanges
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -37,9 +36,9 @@ type GateWebSocketMessage struct {
 type GateBookTickerResult struct {
 	Symbol   string `json:"s"`            // Contract symbol
 	BestBid  string `json:"b"`            // Best bid price
-	BestBidSize int64 `json:"B"`          // Best bid size
+	BestBidSize json.Number `json:"B"`    // Best bid size; contracts on futures, a base amount string on spot
 	BestAsk  string `json:"a"`            // Best ask price 
-	BestAskSize int64 `json:"A"`          // Best ask size
+	BestAskSize json.Number `json:"A"`    // Best ask size
 	Timestamp int64 `json:"t"`            // Timestamp in milliseconds
 }
 
@@ -57,6 +56,24 @@ type GateTradeMessage struct {
 	Result  []GateFuturesTrade  `json:"result"`
 }
 
+// GateSpotTrade is a spot.trades push; unlike futures it is one trade with
+// an explicit taker side
+type GateSpotTrade struct {
+	ID           int64  `json:"id"`
+	CreateTimeMs string `json:"create_time_ms"` // fractional milliseconds
+	Side         string `json:"side"`
+	CurrencyPair string `json:"currency_pair"`
+	Amount       string `json:"amount"`
+	Price        string `json:"price"`
+}
+
+type GateSpotTradeMessage struct {
+	Time    int64         `json:"time"`
+	Channel string        `json:"channel"`
+	Event   string        `json:"event"`
+	Result  GateSpotTrade `json:"result"`
+}
+
 type GateFuturesOrderbook struct {
 	Contract       string     `json:"contract"`
 	Ask            [][]string `json:"asks"`
@@ -80,38 +97,178 @@ type GateSubscribeMessage struct {
 	Payload []string `json:"payload"`
 }
 
-func ConnectGateFutures(symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
-	wsURL := "wss://fx-ws.gateio.ws/v4/ws/usdt"
+func init() {
+	Register(NewConnector("gate_futures", MarketFutures, nil, ConnectGateFutures))
+	Register(NewConnector("gate_spot", MarketSpot, nil, ConnectGateSpot))
+
+	Instruments.RegisterVenue("gate_futures", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractPerpetual,
+		Format: func(base, quote string) string {
+			return base + "_" + quote
+		},
+		// Sizes are contract counts, see gateMetadata
+		Contracts: true,
+	})
+
+	Instruments.RegisterVenue("gate_spot", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractSpot,
+		Format: func(base, quote string) string {
+			return base + "_" + quote
+		},
+	})
+
+	RegisterMetadata("gate_futures", gateMetadata)
+	RegisterMetadata("gate_spot", gateSpotMetadata)
+}
+
+type gateContract struct {
+	Name             string `json:"name"`
+	QuantoMultiplier string `json:"quanto_multiplier"`
+	OrderPriceRound  string `json:"order_price_round"`
+	OrderSizeMin     int64  `json:"order_size_min"`
+}
+
+// gateMetadata loads USDT futures contracts. Gate sizes are whole contracts
+// worth quanto_multiplier of the base asset each.
+func gateMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var contracts []gateContract
+	if err := getJSON(ctx, "https://api.gateio.ws/api/v4/futures/usdt/contracts", &contracts); err != nil {
+		return nil, err
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, contract := range contracts {
+		if !wanted[contract.Name] {
+			continue
+		}
+		specs = append(specs, InstrumentSpec{
+			NativeID:     contract.Name,
+			TickSize:     parseFloatOrZero(contract.OrderPriceRound),
+			LotSize:      1,
+			MinSize:      float64(contract.OrderSizeMin),
+			ContractSize: parseFloatOrZero(contract.QuantoMultiplier),
+		})
+	}
+	return specs, nil
+}
+
+type gateCurrencyPair struct {
+	ID              string `json:"id"`
+	Precision       int    `json:"precision"`
+	AmountPrecision int    `json:"amount_precision"`
+	MinBaseAmount   string `json:"min_base_amount"`
+}
+
+// gateSpotMetadata loads spot pairs; spot sizes are in the base asset
+func gateSpotMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var pairs []gateCurrencyPair
+	if err := getJSON(ctx, "https://api.gateio.ws/api/v4/spot/currency_pairs", &pairs); err != nil {
+		return nil, err
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, pair := range pairs {
+		if !wanted[pair.ID] {
+			continue
+		}
+		lotSize := decimalStep(pair.AmountPrecision)
+		specs = append(specs, InstrumentSpec{
+			NativeID:     pair.ID,
+			TickSize:     decimalStep(pair.Precision),
+			LotSize:      lotSize,
+			MinSize:      max(parseFloatOrZero(pair.MinBaseAmount), lotSize),
+			ContractSize: 1,
+		})
+	}
+	return specs, nil
+}
+
+func ConnectGateFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectGate(ctx, "gate_futures", "futures", "wss://fx-ws.gateio.ws/v4/ws/usdt", symbols, orderbookChan, tradeChan)
+}
+
+// ConnectGateSpot streams spot.book_ticker and spot.trades. The book ticker
+// is the same message as on futures; trades differ and are parsed apart.
+func ConnectGateSpot(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectGate(ctx, "gate_spot", "spot", "wss://api.gateio.ws/ws/v4/", symbols, orderbookChan, tradeChan)
+}
+
+// connectGate runs one Gate websocket feed. market is the channel prefix
+// (futures or spot) that every channel name starts with.
+func connectGate(ctx context.Context, source, market, defaultURL string, symbols []string, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint(source, defaultURL)
+	instruments := resolveSymbols(source, symbols)
+
+	sup := NewSupervisor(source)
+	defer sup.Stopped()
 
 	for {
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
 		if err != nil {
-			log.Printf("Gate.io connection error: %v", err)
-			time.Sleep(5 * time.Second)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Gate.io %s connection error: %v", market, err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
-		log.Printf("Connected to Gate.io futures WebSocket")
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor(source).StaleTimeout)
+
+		log.Printf("Connected to Gate.io %s WebSocket", market)
 
 		// Convert symbols to Gate.io format
-		gateSymbols := make([]string, len(symbols))
-		for i, symbol := range symbols {
-			gateSymbols[i] = convertToGateSymbol(symbol)
+		gateSymbols := make([]string, len(instruments))
+		for i, instrument := range instruments {
+			gateSymbols[i] = instrument.NativeID
 		}
 		
 		// Subscribe to book ticker for all symbols - this provides best bid/ask
 		bookTickerSubscribeMsg := GateSubscribeMessage{
 			Time:    time.Now().Unix(),
-			Channel: "futures.book_ticker",
+			Channel: market + ".book_ticker",
 			Event:   "subscribe",
 			Payload: gateSymbols,
 		}
 
-		err = conn.WriteJSON(bookTickerSubscribeMsg)
+		err = sess.WriteJSON(bookTickerSubscribeMsg)
 		if err != nil {
-			log.Printf("Gate.io book ticker subscription error: %v", err)
+			log.Printf("Gate.io %s book ticker subscription error: %v", market, err)
+			sup.Disconnected(err)
+			stopClose()
 			conn.Close()
-			time.Sleep(5 * time.Second)
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		// Futures trades carry signed contract sizes, positive for taker buys
+		err = sess.WriteJSON(GateSubscribeMessage{
+			Time:    time.Now().Unix(),
+			Channel: market + ".trades",
+			Event:   "subscribe",
+			Payload: gateSymbols,
+		})
+		if err != nil {
+			log.Printf("Gate.io %s trades subscription error: %v", market, err)
+			sup.Disconnected(err)
+			stopClose()
+			conn.Close()
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
@@ -120,15 +277,27 @@ func ConnectGateFutures(symbols []string, priceChan chan<- PriceData, orderbookC
 			continue
 		}
 
+		sup.Subscribed()
+
+		// Gate expects futures.ping or spot.ping application heartbeats
+		stopPing := sess.KeepAlive(15*time.Second, func() error {
+			return sess.WriteJSON(map[string]interface{}{"time": time.Now().Unix(), "channel": market + ".ping"})
+		})
+
 		for {
 			var message json.RawMessage
-			err := conn.ReadJSON(&message)
+			err := sess.ReadJSON(&message)
 			if err != nil {
-				log.Printf("Gate.io read error: %v", err)
+				if ctx.Err() == nil {
+					log.Printf("Gate.io %s read error: %v", market, err)
+				}
+				sup.Disconnected(err)
 				conn.Close()
 				break
 			}
 
+			receivedAt := time.Now()
+
 			// First, try to parse as a general WebSocket message to check for errors
 			var wsMsg GateWebSocketMessage
 			if err := json.Unmarshal(message, &wsMsg); err == nil {
@@ -141,112 +310,121 @@ func ConnectGateFutures(symbols []string, priceChan chan<- PriceData, orderbookC
 				if wsMsg.Event == "subscribe" {
 					continue
 				}
+
+				// Heartbeat replies don't count as data for the stale watchdog
+				if wsMsg.Channel == market+".pong" {
+					continue
+				}
 			}
+			sess.Message()
 
 			// Try to parse as book ticker message
 			var bookTickerMsg GateBookTickerMessage
 			if err := json.Unmarshal(message, &bookTickerMsg); err == nil &&
-			   bookTickerMsg.Channel == "futures.book_ticker" &&
+			   bookTickerMsg.Channel == market+".book_ticker" &&
 			   bookTickerMsg.Event == "update" {
 				
 				// Parse best bid and ask
-				bestBid, err1 := strconv.ParseFloat(bookTickerMsg.Result.BestBid, 64)
-				bestAsk, err2 := strconv.ParseFloat(bookTickerMsg.Result.BestAsk, 64)
+				bestBid, err1 := ParseDecimal(bookTickerMsg.Result.BestBid)
+				bestAsk, err2 := ParseDecimal(bookTickerMsg.Result.BestAsk)
 				if err1 != nil || err2 != nil {
 					log.Printf("Gate.io: Error parsing prices - bid: %v, ask: %v", err1, err2)
 					continue
 				}
 
 				// Convert Gate.io symbol back to standard format
-				standardSymbol := convertFromGateSymbol(bookTickerMsg.Result.Symbol)
-
-				// Use timestamp from message
-				var timestamp int64
-				if bookTickerMsg.Result.Timestamp > 0 {
-					timestamp = bookTickerMsg.Result.Timestamp
-				} else {
-					timestamp = time.Now().UnixMilli()
+				instrument, ok := Instruments.Lookup(source, bookTickerMsg.Result.Symbol)
+				if !ok {
+					continue
 				}
 
+				// Venue timestamp; zero if Gate omitted it
+				timestamp := bookTickerMsg.Result.Timestamp
+
 
 
+				// Futures sizes are in contracts; NormalizeSize converts them
+				bestBidSize, _ := bookTickerMsg.Result.BestBidSize.Float64()
+				bestAskSize, _ := bookTickerMsg.Result.BestAskSize.Float64()
 				orderbookData := OrderbookData{
-					Symbol:    standardSymbol,
-					Source:    "gate_futures",
-					BestBid:   bestBid,
-					BestAsk:   bestAsk,
-					Timestamp: timestamp,
+					Symbol:      instrument.Symbol,
+					Source:      source,
+					BestBid:     instrument.NormalizePrice(bestBid),
+					BestAsk:     instrument.NormalizePrice(bestAsk),
+					BestBidSize: instrument.NormalizeSize(bestBidSize, bestBid),
+					BestAskSize: instrument.NormalizeSize(bestAskSize, bestAsk),
+					Timestamp:   timestamp,
+					ReceivedAt:  receivedAt,
 				}
 
 				orderbookChan <- orderbookData
 				continue
 			}
 
-			// Silently ignore unhandled message types
-		}
+			// Spot trades come one per message with an explicit side
+			if market == "spot" {
+				var spotTradeMsg GateSpotTradeMessage
+				if err := json.Unmarshal(message, &spotTradeMsg); err == nil &&
+					spotTradeMsg.Channel == "spot.trades" &&
+					spotTradeMsg.Event == "update" {
 
-		time.Sleep(2 * time.Second)
-	}
-}
+					trade := spotTradeMsg.Result
+					price, err := ParseDecimal(trade.Price)
+					if err != nil {
+						continue
+					}
 
-// convertToGateSymbol converts standard symbol format to Gate.io format
-// BTCUSDT -> BTC_USDT (for USDT perpetual futures)
-func convertToGateSymbol(symbol string) string {
-	// Handle common symbols for USDT perpetual futures
-	switch symbol {
-	case "BTCUSDT":
-		return "BTC_USDT"
-	case "ETHUSDT":
-		return "ETH_USDT"
-	case "ADAUSDT":
-		return "ADA_USDT"
-	case "SOLUSDT":
-		return "SOL_USDT"
-	case "DOTUSDT":
-		return "DOT_USDT"
-	case "LINKUSDT":
-		return "LINK_USDT"
-	case "AVAXUSDT":
-		return "AVAX_USDT"
-	case "MATICUSDT":
-		return "MATIC_USDT"
-	case "UNIUSDT":
-		return "UNI_USDT"
-	case "LTCUSDT":
-		return "LTC_USDT"
-	case "BCHUSDT":
-		return "BCH_USDT"
-	case "XRPUSDT":
-		return "XRP_USDT"
-	default:
-		// Generic conversion for USDT perpetual futures
-		if strings.HasSuffix(symbol, "USDT") {
-			base := strings.TrimSuffix(symbol, "USDT")
-			return fmt.Sprintf("%s_USDT", base)
-		}
-		// For other quote currencies, insert underscore before the last part
-		if len(symbol) >= 6 {
-			// Assume last 3-4 characters are quote currency
-			if strings.HasSuffix(symbol, "USDC") {
-				base := strings.TrimSuffix(symbol, "USDC")
-				return fmt.Sprintf("%s_USDC", base)
+					instrument, ok := Instruments.Lookup(source, trade.CurrencyPair)
+					if !ok {
+						continue
+					}
+
+					side := "sell"
+					if trade.Side == "buy" {
+						side = "buy"
+					}
+
+					timestamp, _ := strconv.ParseFloat(trade.CreateTimeMs, 64)
+
+					tradeChan <- newTradeData(instrument, price, trade.Amount, side, int64(timestamp), receivedAt)
+				}
+				continue
 			}
-			if strings.HasSuffix(symbol, "USD") {
-				base := strings.TrimSuffix(symbol, "USD")
-				return fmt.Sprintf("%s_USD", base)
+
+			// Try to parse as trades message
+			var tradeMsg GateTradeMessage
+			if err := json.Unmarshal(message, &tradeMsg); err == nil &&
+				tradeMsg.Channel == market+".trades" &&
+				tradeMsg.Event == "update" {
+
+				for _, trade := range tradeMsg.Result {
+					price, err := ParseDecimal(trade.Price)
+					if err != nil {
+						continue
+					}
+
+					instrument, ok := Instruments.Lookup(source, trade.Contract)
+					if !ok {
+						continue
+					}
+
+					side := "buy"
+					if trade.Size < 0 {
+						side = "sell"
+					}
+
+					tradeChan <- newTradeData(instrument, price, strconv.FormatInt(trade.Size, 10), side, trade.CreateTimeMs, receivedAt)
+				}
+				continue
 			}
+
+			// Silently ignore unhandled message types
 		}
-		return symbol
-	}
-}
 
-// convertFromGateSymbol converts Gate.io symbol format back to standard format
-// BTC_USDT -> BTCUSDT
-func convertFromGateSymbol(gateSymbol string) string {
-	// Remove underscore and convert to standard format
-	parts := strings.Split(gateSymbol, "_")
-	if len(parts) == 2 {
-		return parts[0] + parts[1]
+		stopPing()
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
 	}
-	return gateSymbol
-}
\ No newline at end of file
+}
@@ -0,0 +1,273 @@
+package exchanges
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// HTXMessage is a decompressed frame from the linear swap market websocket.
+// Heartbeats only carry ping, subscription acks carry status and subbed.
+type HTXMessage struct {
+	Ping    int64           `json:"ping"`
+	Status  string          `json:"status"`
+	ErrCode string          `json:"err-code"`
+	ErrMsg  string          `json:"err-msg"`
+	Channel string          `json:"ch"`
+	Tick    json.RawMessage `json:"tick"`
+}
+
+// HTXBBO is the best bid and offer; each side is [price, contracts]
+type HTXBBO struct {
+	Bid       []json.Number `json:"bid"`
+	Ask       []json.Number `json:"ask"`
+	Timestamp int64         `json:"ts"`
+}
+
+type HTXTradeDetail struct {
+	Data []struct {
+		Price     json.Number `json:"price"`
+		Amount    json.Number `json:"amount"` // contracts
+		Direction string      `json:"direction"`
+		Timestamp int64       `json:"ts"`
+	} `json:"data"`
+}
+
+func init() {
+	Register(NewConnector("htx_futures", MarketFutures, nil, ConnectHTXFutures))
+
+	// HTX USDT-margined swaps are sized in contracts (BTC-USDT)
+	Instruments.RegisterVenue("htx_futures", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractPerpetual,
+		Format: func(base, quote string) string {
+			return base + "-" + quote
+		},
+		Contracts: true,
+	})
+
+	RegisterMetadata("htx_futures", htxMetadata)
+}
+
+type htxContracts struct {
+	Status string `json:"status"`
+	ErrMsg string `json:"err_msg"`
+	Data   []struct {
+		ContractCode string  `json:"contract_code"`
+		ContractSize float64 `json:"contract_size"`
+		PriceTick    float64 `json:"price_tick"`
+	} `json:"data"`
+}
+
+// htxMetadata loads linear swap contracts; contract_size is the base amount
+// of one contract and orders are in whole contracts
+func htxMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var contracts htxContracts
+	if err := getJSON(ctx, "https://api.hbdm.com/linear-swap-api/v1/swap_contract_info?business_type=swap", &contracts); err != nil {
+		return nil, err
+	}
+	if contracts.Status != "ok" {
+		return nil, fmt.Errorf("swap_contract_info: %s", contracts.ErrMsg)
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, contract := range contracts.Data {
+		if !wanted[contract.ContractCode] {
+			continue
+		}
+		specs = append(specs, InstrumentSpec{
+			NativeID:     contract.ContractCode,
+			TickSize:     contract.PriceTick,
+			LotSize:      1,
+			MinSize:      1,
+			ContractSize: contract.ContractSize,
+		})
+	}
+	return specs, nil
+}
+
+// gunzip decompresses one gzip compressed websocket frame
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// ConnectHTXFutures streams HTX linear swap BBO and trades. Every frame is a
+// gzip compressed binary message, and the server's {"ping": n} heartbeats
+// must be echoed back as {"pong": n} or the connection is dropped.
+func ConnectHTXFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint("htx_futures", "wss://api.hbdm.com/linear-swap-ws")
+	instruments := resolveSymbols("htx_futures", symbols)
+
+	sup := NewSupervisor("htx_futures")
+	defer sup.Stopped()
+
+	for {
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("HTX connection error: %v", err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("htx_futures").StaleTimeout)
+
+		log.Printf("Connected to HTX futures WebSocket")
+
+		for _, instrument := range instruments {
+			for _, topic := range []string{"bbo", "trade.detail"} {
+				if err == nil {
+					channel := "market." + instrument.NativeID + "." + topic
+					err = sess.WriteJSON(map[string]string{
+						"sub": channel,
+						"id":  channel,
+					})
+				}
+			}
+		}
+		if err != nil {
+			log.Printf("HTX subscription error: %v", err)
+			sup.Disconnected(err)
+			stopClose()
+			conn.Close()
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		sup.Subscribed()
+
+		for {
+			compressed, err := sess.ReadMessage()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("HTX read error: %v", err)
+				}
+				sup.Disconnected(err)
+				conn.Close()
+				break
+			}
+
+			receivedAt := time.Now()
+
+			data, err := gunzip(compressed)
+			if err != nil {
+				log.Printf("HTX decompress error: %v", err)
+				continue
+			}
+
+			var message HTXMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				log.Printf("HTX unmarshal error: %v", err)
+				continue
+			}
+
+			// The server's pings don't count as data for the stale watchdog
+			if message.Ping != 0 {
+				if err := sess.WriteJSON(map[string]int64{"pong": message.Ping}); err != nil {
+					log.Printf("HTX pong error: %v", err)
+				}
+				continue
+			}
+			if message.Status == "error" {
+				log.Printf("HTX error %s: %s", message.ErrCode, message.ErrMsg)
+				continue
+			}
+			if message.Channel == "" {
+				continue
+			}
+			sess.Message()
+
+			// Channels are market.<contract>.bbo and market.<contract>.trade.detail
+			contract, topic, ok := strings.Cut(strings.TrimPrefix(message.Channel, "market."), ".")
+			if !ok {
+				continue
+			}
+
+			instrument, ok := Instruments.Lookup("htx_futures", contract)
+			if !ok {
+				continue
+			}
+
+			switch topic {
+			case "bbo":
+				var bbo HTXBBO
+				if err := json.Unmarshal(message.Tick, &bbo); err != nil {
+					continue
+				}
+				if len(bbo.Bid) < 2 || len(bbo.Ask) < 2 {
+					continue
+				}
+
+				bidPrice, err1 := ParseDecimal(bbo.Bid[0].String())
+				askPrice, err2 := ParseDecimal(bbo.Ask[0].String())
+				bidSize, err3 := bbo.Bid[1].Float64()
+				askSize, err4 := bbo.Ask[1].Float64()
+				if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+					continue
+				}
+
+				orderbookChan <- OrderbookData{
+					Symbol:      instrument.Symbol,
+					Source:      "htx_futures",
+					BestBid:     instrument.NormalizePrice(bidPrice),
+					BestAsk:     instrument.NormalizePrice(askPrice),
+					BestBidSize: instrument.NormalizeSize(bidSize, bidPrice),
+					BestAskSize: instrument.NormalizeSize(askSize, askPrice),
+					Timestamp:   bbo.Timestamp,
+					ReceivedAt:  receivedAt,
+				}
+
+			case "trade.detail":
+				var detail HTXTradeDetail
+				if err := json.Unmarshal(message.Tick, &detail); err != nil {
+					continue
+				}
+
+				for _, trade := range detail.Data {
+					price, err := ParseDecimal(trade.Price.String())
+					if err != nil {
+						continue
+					}
+
+					// direction is the taker side
+					side := "sell"
+					if trade.Direction == "buy" {
+						side = "buy"
+					}
+
+					tradeChan <- newTradeData(instrument, price, trade.Amount.String(), side, trade.Timestamp, receivedAt)
+				}
+			}
+		}
+
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package exchanges
 
 import (
+	"context"
 	"encoding/json"
 	"log"
-	"strconv"
 	"time"
+	"strconv"
 
 	"github.com/gorilla/websocket"
 )
@@ -39,23 +40,111 @@ type HyperliquidL2BookData struct {
 	Time   int64               `json:"time"`
 }
 
-func ConnectHyperliquidFutures(symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
-	wsURL := "wss://api.hyperliquid.xyz/ws"
+// hyperliquidLevels converts l2Book levels into price levels
+func hyperliquidLevels(raw []HyperliquidLevel) ([]PriceLevel, error) {
+	levels := make([]PriceLevel, 0, len(raw))
+	for _, level := range raw {
+		price, err := ParseDecimal(level.Price)
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseFloat(level.Size, 64)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, PriceLevel{Price: price, Size: size})
+	}
+	return levels, nil
+}
+
+func init() {
+	Register(NewConnector("hyperliquid_futures", MarketFutures, nil, ConnectHyperliquidFutures))
+
+	// Hyperliquid perps are quoted in USD and settled in USDC, keyed by coin
+	Instruments.RegisterVenue("hyperliquid_futures", VenueSpec{
+		Quote:      "USD",
+		Settlement: "USDC",
+		Contract:   ContractPerpetual,
+		Format: func(base, quote string) string {
+			return base
+		},
+		Aliases: kiloAliases,
+	})
+
+	RegisterMetadata("hyperliquid_futures", hyperliquidMetadata)
+}
+
+type hyperliquidMeta struct {
+	Universe []struct {
+		Name       string `json:"name"`
+		SzDecimals int    `json:"szDecimals"`
+	} `json:"universe"`
+}
+
+// hyperliquidMetadata loads perp specs from the info endpoint. Sizes are in
+// the base asset with szDecimals decimals; prices may have at most
+// 6 - szDecimals decimals (and five significant figures), so the tick size
+// is the finest step allowed rather than a fixed increment.
+func hyperliquidMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var meta hyperliquidMeta
+	if err := postJSON(ctx, "https://api.hyperliquid.xyz/info", map[string]string{"type": "meta"}, &meta); err != nil {
+		return nil, err
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, asset := range meta.Universe {
+		if !wanted[asset.Name] {
+			continue
+		}
+		lotSize := decimalStep(asset.SzDecimals)
+		specs = append(specs, InstrumentSpec{
+			NativeID:     asset.Name,
+			TickSize:     decimalStep(6 - asset.SzDecimals),
+			LotSize:      lotSize,
+			MinSize:      lotSize,
+			ContractSize: 1,
+		})
+	}
+	return specs, nil
+}
+
+func ConnectHyperliquidFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint("hyperliquid_futures", "wss://api.hyperliquid.xyz/ws")
+	instruments := resolveSymbols("hyperliquid_futures", symbols)
+	depth := optionsFor("hyperliquid_futures").Depth
+
+	books := make(map[string]*OrderBook)
+
+	sup := NewSupervisor("hyperliquid_futures")
+	defer sup.Stopped()
 
 	for {
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			log.Printf("Hyperliquid connection error: %v", err)
-			time.Sleep(5 * time.Second)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("hyperliquid_futures").StaleTimeout)
+
 		log.Printf("Connected to Hyperliquid futures WebSocket")
 
 		// Subscribe to trades and l2Book for each symbol
-		for _, symbol := range symbols {
-			// Convert BTCUSDT to BTC for Hyperliquid
-			coin := symbol[:3] // Extract first 3 characters (BTC from BTCUSDT)
+		for _, instrument := range instruments {
+			// Hyperliquid identifies perps by coin (BTCUSDT -> BTC, PEPEUSDT -> kPEPE)
+			coin := instrument.NativeID
 
 			// Subscribe to trades
 			tradeSubscribeMsg := map[string]interface{}{
@@ -66,7 +155,7 @@ func ConnectHyperliquidFutures(symbols []string, priceChan chan<- PriceData, ord
 				},
 			}
 
-			err = conn.WriteJSON(tradeSubscribeMsg)
+			err = sess.WriteJSON(tradeSubscribeMsg)
 			if err != nil {
 				log.Printf("Hyperliquid trade subscription error for %s: %v", coin, err)
 				continue
@@ -81,22 +170,43 @@ func ConnectHyperliquidFutures(symbols []string, priceChan chan<- PriceData, ord
 				},
 			}
 
-			err = conn.WriteJSON(l2BookSubscribeMsg)
+			err = sess.WriteJSON(l2BookSubscribeMsg)
 			if err != nil {
 				log.Printf("Hyperliquid l2Book subscription error for %s: %v", coin, err)
 				continue
 			}
 		}
 
+		sup.Subscribed()
+
+		// Hyperliquid closes connections with no traffic for 60s
+		stopPing := sess.KeepAlive(30*time.Second, func() error {
+			return sess.WriteJSON(map[string]string{"method": "ping"})
+		})
+
 		for {
 			var message json.RawMessage
-			err := conn.ReadJSON(&message)
+			err := sess.ReadJSON(&message)
 			if err != nil {
-				log.Printf("Hyperliquid read error: %v", err)
+				if ctx.Err() == nil {
+					log.Printf("Hyperliquid read error: %v", err)
+				}
+				sup.Disconnected(err)
 				conn.Close()
 				break
 			}
 
+			receivedAt := time.Now()
+
+			// Ping replies don't count as data for the stale watchdog
+			var reply struct {
+				Channel string `json:"channel"`
+			}
+			if json.Unmarshal(message, &reply) == nil && reply.Channel == "pong" {
+				continue
+			}
+			sess.Message()
+
 			// Try to parse as trade message first
 			var tradeMessage HyperliquidTrade
 			if err := json.Unmarshal(message, &tradeMessage); err == nil && tradeMessage.Channel == "trades" && len(tradeMessage.Data) > 0 {
@@ -116,13 +226,16 @@ func ConnectHyperliquidFutures(symbols []string, priceChan chan<- PriceData, ord
 
 				for _, trade := range trades {
 					// Parse price from string
-					price, err := strconv.ParseFloat(trade.Price, 64)
+					price, err := ParseDecimal(trade.Price)
 					if err != nil {
 						continue
 					}
 
 					// Convert coin back to symbol format (BTC -> BTCUSDT)
-					symbol := trade.Coin + "USDT"
+					instrument, ok := Instruments.Lookup("hyperliquid_futures", trade.Coin)
+					if !ok {
+						continue
+					}
 
 					// Normalize trade side (Hyperliquid uses "A" for ask/sell, "B" for bid/buy)
 					var side string
@@ -132,14 +245,7 @@ func ConnectHyperliquidFutures(symbols []string, priceChan chan<- PriceData, ord
 						side = "sell"
 					}
 
-					tradeData := TradeData{
-						Symbol:    symbol,
-						Source:    "hyperliquid_futures",
-						Price:     price,
-						Quantity:  trade.Size,
-						Side:      side,
-						Timestamp: trade.Timestamp,
-					}
+					tradeData := newTradeData(instrument, price, trade.Size, side, trade.Timestamp, receivedAt)
 
 					tradeChan <- tradeData
 				}
@@ -155,31 +261,43 @@ func ConnectHyperliquidFutures(symbols []string, priceChan chan<- PriceData, ord
 					continue
 				}
 
-				if len(l2BookData.Levels) >= 2 && len(l2BookData.Levels[0]) > 0 && len(l2BookData.Levels[1]) > 0 {
-					// Hyperliquid l2Book format: levels[0] is bids, levels[1] is asks
-					// Each level has px (price), sz (size), n (count)
-					bestBid, err1 := strconv.ParseFloat(l2BookData.Levels[0][0].Price, 64)
-					bestAsk, err2 := strconv.ParseFloat(l2BookData.Levels[1][0].Price, 64)
-					if err1 != nil || err2 != nil {
-						continue
-					}
+				// Hyperliquid l2Book format: levels[0] is bids, levels[1] is asks.
+				// Every push is a full snapshot of the top of the book.
+				if len(l2BookData.Levels) < 2 {
+					continue
+				}
+				bids, err1 := hyperliquidLevels(l2BookData.Levels[0])
+				asks, err2 := hyperliquidLevels(l2BookData.Levels[1])
+				if err1 != nil || err2 != nil {
+					continue
+				}
 
-					// Convert coin back to symbol format (BTC -> BTCUSDT)
-					symbol := l2BookData.Coin + "USDT"
-
-					orderbookData := OrderbookData{
-						Symbol:    symbol,
-						Source:    "hyperliquid_futures",
-						BestBid:   bestBid,
-						BestAsk:   bestAsk,
-						Timestamp: l2BookData.Time,
-					}
+				book, exists := books[l2BookData.Coin]
+				if !exists {
+					book = NewOrderBook()
+					books[l2BookData.Coin] = book
+				}
+				book.ApplySnapshot(bids, asks)
 
-					orderbookChan <- orderbookData
+				// Convert coin back to symbol format (BTC -> BTCUSDT)
+				instrument, ok := Instruments.Lookup("hyperliquid_futures", l2BookData.Coin)
+				if !ok {
+					continue
+				}
+
+				orderbookData, ok := book.OrderbookData(instrument, depth, l2BookData.Time, receivedAt)
+				if !ok {
+					continue
 				}
+
+				orderbookChan <- orderbookData
 			}
 		}
 
-		time.Sleep(2 * time.Second)
+		stopPing()
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
 	}
 }
@@ -0,0 +1,384 @@
+package exchanges
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ContractType is the kind of market an instrument trades on
+type ContractType string
+
+const (
+	ContractSpot      ContractType = "spot"
+	ContractPerpetual ContractType = "perpetual"
+	ContractFuture    ContractType = "future"
+)
+
+// Instrument is a venue independent description of a market
+type Instrument struct {
+	Base       string       `json:"base"`
+	Quote      string       `json:"quote"`
+	Settlement string       `json:"settlement"`
+	Contract   ContractType `json:"contract"`
+	// Inverse contracts are quoted in the quote currency but sized and
+	// settled in it too (Deribit, BitMEX XBTUSD), so a quantity is worth
+	// size / price base units
+	Inverse bool `json:"inverse,omitempty"`
+	// Quanto contracts are quoted per base unit but pay a fixed amount of
+	// the settlement currency per point (BitMEX ETHUSD), so their prices
+	// compare directly while a contract has no fixed size in the base asset
+	Quanto bool `json:"quanto,omitempty"`
+	// Expiry is the delivery time of dated futures in Unix ms; 0 for spot
+	// and perpetuals
+	Expiry int64 `json:"expiry,omitempty"`
+}
+
+// Kind names how a contract differs from a linear perpetual: "dated" for
+// futures with a delivery date, whose price includes the basis to expiry,
+// "inverse" or "quanto" for how its size relates to the base asset, and
+// empty for linear perpetuals and spot
+func (i Instrument) Kind() string {
+	switch {
+	case i.Contract == ContractFuture || i.Expiry != 0:
+		return "dated"
+	case i.Quanto:
+		return "quanto"
+	case i.Inverse:
+		return "inverse"
+	}
+	return ""
+}
+
+// VenueInstrument links a canonical instrument to one venue's native ID.
+// Symbol is the watched symbol (e.g. BTCUSDT) that data is reported under, so
+// a USD quoted Kraken perp and a USDT Binance perp land in the same matrix.
+type VenueInstrument struct {
+	Venue      string     `json:"venue"`
+	Symbol     string     `json:"symbol"`
+	NativeID   string     `json:"native_id"`
+	Instrument Instrument `json:"instrument"`
+	// Multiplier is the number of base units one quoted unit represents,
+	// e.g. 1000 for 1000PEPEUSDT or Hyperliquid's kPEPE
+	Multiplier float64 `json:"multiplier"`
+	// Spec holds the venue's trading rules once metadata has been loaded.
+	// ContractSize is 1 on venues that quote quantities in the base asset and
+	// 0 (unknown) on contract venues until their metadata arrives.
+	Spec InstrumentSpec `json:"spec"`
+}
+
+// NormalizePrice converts a venue price into a price per single base unit
+func (vi VenueInstrument) NormalizePrice(price Decimal) Decimal {
+	if vi.Multiplier == 0 || vi.Multiplier == 1 {
+		return price
+	}
+	if vi.Multiplier == math.Trunc(vi.Multiplier) {
+		return price.DivInt(int64(vi.Multiplier))
+	}
+	normalized, err := DecimalFromFloat(price.Float64() / vi.Multiplier)
+	if err != nil {
+		return price
+	}
+	return normalized
+}
+
+// NormalizeSize converts a venue quantity traded or quoted at a venue price
+// into single base units. It returns 0 when the venue counts contracts and
+// the contract size isn't known, since a wrong size is worse than none, and
+// for quanto contracts for the same reason. The price only matters for
+// inverse contracts.
+func (vi VenueInstrument) NormalizeSize(size float64, price Decimal) float64 {
+	if vi.Instrument.Quanto {
+		return 0
+	}
+	size *= vi.Spec.ContractSize
+	if vi.Instrument.Inverse {
+		if price.Sign() <= 0 {
+			return 0
+		}
+		size /= price.Float64()
+	}
+	if vi.Multiplier == 0 || vi.Multiplier == 1 {
+		return size
+	}
+	return size * vi.Multiplier
+}
+
+// NormalizeLevels converts venue price levels into per base unit prices and sizes
+func (vi VenueInstrument) NormalizeLevels(levels []PriceLevel) []PriceLevel {
+	normalized := make([]PriceLevel, len(levels))
+	for i, level := range levels {
+		normalized[i] = PriceLevel{
+			Price: vi.NormalizePrice(level.Price),
+			Size:  vi.NormalizeSize(level.Size, level.Price),
+		}
+	}
+	return normalized
+}
+
+// BaseAlias is a venue specific name for a canonical base asset
+type BaseAlias struct {
+	Name       string
+	Multiplier float64
+}
+
+// VenueSpec describes how a venue names its instruments
+type VenueSpec struct {
+	Quote      string
+	Settlement string
+	Contract   ContractType
+	// Format builds the native ID from the venue's base name and quote
+	Format func(base, quote string) string
+	// Aliases maps canonical bases to the venue's own names (BTC -> XBT)
+	Aliases map[string]BaseAlias
+	// Contracts is set when the venue counts quantities in contracts whose
+	// size comes from instrument metadata (OKX, Gate)
+	Contracts bool
+	// Inverse marks coin margined contracts; their settlement defaults to
+	// the base asset
+	Inverse bool
+}
+
+// InstrumentRegistry maps watched symbols to venue native IDs and back
+type InstrumentRegistry struct {
+	mu       sync.RWMutex
+	venues   map[string]VenueSpec
+	byNative map[string]map[string]VenueInstrument // venue -> native ID
+	bySymbol map[string]map[string]VenueInstrument // venue -> watched symbol
+	specs    map[string]map[string]InstrumentSpec  // venue -> native ID
+}
+
+// Instruments is the registry shared by all connectors
+var Instruments = NewInstrumentRegistry()
+
+func NewInstrumentRegistry() *InstrumentRegistry {
+	return &InstrumentRegistry{
+		venues:   make(map[string]VenueSpec),
+		byNative: make(map[string]map[string]VenueInstrument),
+		bySymbol: make(map[string]map[string]VenueInstrument),
+		specs:    make(map[string]map[string]InstrumentSpec),
+	}
+}
+
+// Known quote currencies, longest first so FDUSD wins over USD
+var quoteCurrencies = []string{"FDUSD", "USDT", "USDC", "BUSD", "USD", "EUR", "BTC", "ETH"}
+
+// Common aliases for assets listed in thousands on derivative venues
+var (
+	thousandAliases = map[string]BaseAlias{
+		"PEPE": {Name: "1000PEPE", Multiplier: 1000},
+		"SHIB": {Name: "1000SHIB", Multiplier: 1000},
+		"BONK": {Name: "1000BONK", Multiplier: 1000},
+	}
+	kiloAliases = map[string]BaseAlias{
+		"PEPE": {Name: "kPEPE", Multiplier: 1000},
+		"SHIB": {Name: "kSHIB", Multiplier: 1000},
+		"BONK": {Name: "kBONK", Multiplier: 1000},
+	}
+)
+
+// ParseSymbol splits a watched symbol like BTCUSDT into base and quote
+func ParseSymbol(symbol string) (base, quote string, ok bool) {
+	symbol = strings.ToUpper(symbol)
+	for _, q := range quoteCurrencies {
+		if strings.HasSuffix(symbol, q) && len(symbol) > len(q) {
+			return strings.TrimSuffix(symbol, q), q, true
+		}
+	}
+	return "", "", false
+}
+
+// RegisterVenue adds the naming rules for a venue
+func (r *InstrumentRegistry) RegisterVenue(venue string, spec VenueSpec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.venues[venue] = spec
+}
+
+// Add registers an explicit mapping, overriding the venue naming rules
+func (r *InstrumentRegistry) Add(vi VenueInstrument) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.addLocked(vi)
+}
+
+// addLocked stores vi with its spec and defaults filled in and returns the
+// stored instrument; the caller must hold r.mu
+func (r *InstrumentRegistry) addLocked(vi VenueInstrument) VenueInstrument {
+	if vi.Multiplier == 0 {
+		vi.Multiplier = 1
+	}
+	if spec, ok := r.specs[vi.Venue][vi.NativeID]; ok {
+		vi.Spec = spec
+	}
+	if vi.Spec.NativeID == "" {
+		vi.Spec.NativeID = vi.NativeID
+	}
+	// Venues listing several contract kinds report them per instrument
+	if vi.Spec.Inverse {
+		vi.Instrument.Inverse = true
+	}
+	if vi.Spec.Quanto {
+		vi.Instrument.Quanto = true
+	}
+	if vi.Spec.Expiry != 0 {
+		vi.Instrument.Expiry = vi.Spec.Expiry
+	}
+	if vi.Spec.Settlement != "" {
+		vi.Instrument.Settlement = vi.Spec.Settlement
+	}
+	if vi.Spec.ContractSize == 0 && !r.venues[vi.Venue].Contracts {
+		vi.Spec.ContractSize = 1
+	}
+	if r.byNative[vi.Venue] == nil {
+		r.byNative[vi.Venue] = make(map[string]VenueInstrument)
+		r.bySymbol[vi.Venue] = make(map[string]VenueInstrument)
+	}
+	r.byNative[vi.Venue][vi.NativeID] = vi
+	r.bySymbol[vi.Venue][vi.Symbol] = vi
+	return vi
+}
+
+// Resolve maps a watched symbol to the venue's instrument and remembers the
+// reverse mapping so Lookup can translate incoming native IDs
+func (r *InstrumentRegistry) Resolve(venue, symbol string) (VenueInstrument, error) {
+	symbol = strings.ToUpper(symbol)
+
+	r.mu.RLock()
+	vi, exists := r.bySymbol[venue][symbol]
+	spec, hasSpec := r.venues[venue]
+	r.mu.RUnlock()
+
+	if exists {
+		return vi, nil
+	}
+	if !hasSpec {
+		return VenueInstrument{}, fmt.Errorf("no instrument rules for venue %s", venue)
+	}
+
+	base, quote, ok := ParseSymbol(symbol)
+	if !ok {
+		return VenueInstrument{}, fmt.Errorf("cannot parse symbol %s", symbol)
+	}
+
+	venueQuote := spec.Quote
+	if venueQuote == "" {
+		venueQuote = quote
+	}
+	settlement := spec.Settlement
+	if settlement == "" {
+		settlement = venueQuote
+		if spec.Inverse {
+			settlement = base
+		}
+	}
+
+	venueBase := base
+	multiplier := 1.0
+	if alias, ok := spec.Aliases[base]; ok {
+		venueBase = alias.Name
+		multiplier = alias.Multiplier
+	}
+
+	vi = VenueInstrument{
+		Venue:    venue,
+		Symbol:   symbol,
+		NativeID: spec.Format(venueBase, venueQuote),
+		Instrument: Instrument{
+			Base:       base,
+			Quote:      venueQuote,
+			Settlement: settlement,
+			Contract:   spec.Contract,
+			Inverse:    spec.Inverse,
+		},
+		Multiplier: multiplier,
+	}
+
+	r.mu.Lock()
+	vi = r.addLocked(vi)
+	r.mu.Unlock()
+
+	return vi, nil
+}
+
+// SetSpec installs the trading rules for a venue instrument, updating it if
+// it was already resolved
+func (r *InstrumentRegistry) SetSpec(venue string, spec InstrumentSpec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.specs[venue] == nil {
+		r.specs[venue] = make(map[string]InstrumentSpec)
+	}
+	r.specs[venue][spec.NativeID] = spec
+
+	if vi, ok := r.byNative[venue][spec.NativeID]; ok {
+		vi.Spec = InstrumentSpec{}
+		r.addLocked(vi)
+	}
+}
+
+// Forget drops every resolved instrument of a venue so the next Resolve
+// applies the naming rules again, e.g. once a dated contract has expired.
+// Loaded specs are kept.
+func (r *InstrumentRegistry) Forget(venue string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.byNative, venue)
+	delete(r.bySymbol, venue)
+}
+
+// Lookup returns the instrument for a venue native ID
+func (r *InstrumentRegistry) Lookup(venue, nativeID string) (VenueInstrument, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	vi, ok := r.byNative[venue][nativeID]
+	return vi, ok
+}
+
+// All returns every resolved instrument sorted by venue and symbol
+func (r *InstrumentRegistry) All() []VenueInstrument {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var all []VenueInstrument
+	for _, instruments := range r.byNative {
+		for _, vi := range instruments {
+			all = append(all, vi)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Venue != all[j].Venue {
+			return all[i].Venue < all[j].Venue
+		}
+		return all[i].Symbol < all[j].Symbol
+	})
+	return all
+}
+
+// resolveSymbols resolves every symbol for the venue, logging and skipping
+// the ones the venue cannot map
+func resolveSymbols(venue string, symbols []string) []VenueInstrument {
+	instruments := make([]VenueInstrument, 0, len(symbols))
+	for _, symbol := range symbols {
+		vi, err := Instruments.Resolve(venue, symbol)
+		if err != nil {
+			log.Printf("%s: skipping %s: %v", venue, symbol, err)
+			continue
+		}
+		instruments = append(instruments, vi)
+	}
+	return instruments
+}
+
+// concatFormat joins base and quote without a separator (BTCUSDT)
+func concatFormat(base, quote string) string {
+	return base + quote
+}
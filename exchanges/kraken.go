@@ -1,15 +1,18 @@
 package exchanges
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 type KrakenOrderBookEntry struct {
-	Price float64 `json:"price"`
+	Price Decimal `json:"price"`
 	Qty   float64 `json:"qty"`
 }
 
@@ -18,7 +21,7 @@ type KrakenOrderBookData struct {
 	ProductID string                 `json:"product_id"`
 	Side      string                 `json:"side,omitempty"`
 	Seq       int64                  `json:"seq"`
-	Price     float64                `json:"price,omitempty"`
+	Price     Decimal                `json:"price,omitempty"`
 	Qty       float64                `json:"qty,omitempty"`
 	Bids      []KrakenOrderBookEntry `json:"bids,omitempty"`
 	Asks      []KrakenOrderBookEntry `json:"asks,omitempty"`
@@ -26,55 +29,204 @@ type KrakenOrderBookData struct {
 }
 
 type KrakenOrderBook struct {
-	Bids []KrakenOrderBookEntry
-	Asks []KrakenOrderBookEntry
+	*OrderBook
+	// Seq is the sequence number of the last applied message
+	Seq int64
+	// Synced is false until a snapshot arrives and after a resync is requested
+	Synced bool
 }
 
-func processKrakenOrderbook(productID string, orderBook *KrakenOrderBook, orderbookChan chan<- OrderbookData) {
-	if len(orderBook.Bids) == 0 || len(orderBook.Asks) == 0 {
-		return
+// reset clears the book and waits for a fresh snapshot
+func (b *KrakenOrderBook) reset() {
+	b.Reset()
+	b.Seq = 0
+	b.Synced = false
+}
+
+// krakenLevels converts Kraken book entries into price levels
+func krakenLevels(entries []KrakenOrderBookEntry) []PriceLevel {
+	levels := make([]PriceLevel, len(entries))
+	for i, entry := range entries {
+		levels[i] = PriceLevel{Price: entry.Price, Size: entry.Qty}
 	}
+	return levels
+}
 
-	// Convert symbol back to standard format (PF_XBTUSD -> BTCUSDT)
-	symbol := convertFromKrakenSymbol(productID)
+// apply applies a snapshot or delta to the book. It reports whether the book
+// changed and should be published, or why it can no longer be trusted and
+// must be resynced.
+func (b *KrakenOrderBook) apply(data KrakenOrderBookData) (publish bool, resync string) {
+	switch data.Feed {
+	case "book_snapshot":
+		b.ApplySnapshot(krakenLevels(data.Bids), krakenLevels(data.Asks))
+		b.Seq = data.Seq
+		b.Synced = true
+	case "book":
+		// Deltas before the snapshot (or while resyncing) can't be applied
+		if !b.Synced {
+			return false, ""
+		}
+
+		// Every delta must follow the previous one; a gap means a lost
+		// message and a book we can no longer trust
+		if data.Seq != b.Seq+1 {
+			return false, fmt.Sprintf("sequence gap: expected %d, got %d", b.Seq+1, data.Seq)
+		}
+
+		// Incremental update; a zero quantity removes the level
+		switch data.Side {
+		case "buy":
+			b.Update(Bid, data.Price, data.Qty)
+		case "sell":
+			b.Update(Ask, data.Price, data.Qty)
+		}
+		b.Seq = data.Seq
+	default:
+		return false, ""
+	}
+
+	if b.Crossed() {
+		bestBid, _ := b.BestBid()
+		bestAsk, _ := b.BestAsk()
+		return false, fmt.Sprintf("crossed book: bid %v >= ask %v", bestBid.Price, bestAsk.Price)
+	}
+	return true, ""
+}
+
+// resyncKrakenBook drops the local book and resubscribes so Kraken sends a
+// new snapshot
+func resyncKrakenBook(sess *wsSession, sup *Supervisor, productID string, orderbook *KrakenOrderBook, reason string) error {
+	log.Printf("Kraken %s book resync: %s", productID, reason)
+	sup.Resync(productID + ": " + reason)
+	orderbook.reset()
+
+	err := sess.WriteJSON(map[string]interface{}{
+		"event":       "unsubscribe",
+		"feed":        "book",
+		"product_ids": []string{productID},
+	})
+	if err != nil {
+		return err
+	}
 
-	// Get best bid (highest price in bids)
-	bestBid := orderBook.Bids[0].Price
+	return sess.WriteJSON(map[string]interface{}{
+		"event":       "subscribe",
+		"feed":        "book",
+		"product_ids": []string{productID},
+	})
+}
 
-	// Get best ask (lowest price in asks)
-	bestAsk := orderBook.Asks[0].Price
+func processKrakenOrderbook(productID string, orderBook *KrakenOrderBook, depth int, timestamp int64, receivedAt time.Time, orderbookChan chan<- OrderbookData) {
+	// Convert symbol back to standard format (PF_XBTUSD -> BTCUSDT)
+	instrument, ok := Instruments.Lookup("kraken_futures", productID)
+	if !ok {
+		return
+	}
 
-	orderbookData := OrderbookData{
-		Symbol:    symbol,
-		Source:    "kraken_futures",
-		BestBid:   bestBid,
-		BestAsk:   bestAsk,
-		Timestamp: time.Now().UnixMilli(),
+	orderbookData, ok := orderBook.OrderbookData(instrument, depth, timestamp, receivedAt)
+	if !ok {
+		return
 	}
 
 	orderbookChan <- orderbookData
 }
 
-func ConnectKrakenFutures(symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
-	wsURL := "wss://futures.kraken.com/ws/v1"
+func init() {
+	Register(NewConnector("kraken_futures", MarketFutures, nil, ConnectKrakenFutures))
+
+	// Kraken multi-collateral perpetuals are USD quoted and settled (PF_XBTUSD)
+	Instruments.RegisterVenue("kraken_futures", VenueSpec{
+		Quote:    "USD",
+		Contract: ContractPerpetual,
+		Format: func(base, quote string) string {
+			return "PF_" + base + quote
+		},
+		Aliases: map[string]BaseAlias{
+			"BTC": {Name: "XBT", Multiplier: 1},
+		},
+	})
+
+	RegisterMetadata("kraken_futures", krakenMetadata)
+}
+
+type krakenInstruments struct {
+	Result      string `json:"result"`
+	Error       string `json:"error"`
+	Instruments []struct {
+		Symbol                      string  `json:"symbol"`
+		TickSize                    float64 `json:"tickSize"`
+		ContractSize                float64 `json:"contractSize"`
+		ContractValueTradePrecision int     `json:"contractValueTradePrecision"`
+	} `json:"instruments"`
+}
+
+// krakenMetadata loads futures instruments. Multi-collateral perps have a
+// contract size of one base unit and trade in steps set by
+// contractValueTradePrecision decimals.
+func krakenMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var instruments krakenInstruments
+	if err := getJSON(ctx, "https://futures.kraken.com/derivatives/api/v3/instruments", &instruments); err != nil {
+		return nil, err
+	}
+	if instruments.Result != "success" {
+		return nil, fmt.Errorf("instruments: %s", instruments.Error)
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, instrument := range instruments.Instruments {
+		if !wanted[instrument.Symbol] {
+			continue
+		}
+		lotSize := decimalStep(instrument.ContractValueTradePrecision)
+		specs = append(specs, InstrumentSpec{
+			NativeID:     instrument.Symbol,
+			TickSize:     instrument.TickSize,
+			LotSize:      lotSize,
+			MinSize:      lotSize,
+			ContractSize: instrument.ContractSize,
+		})
+	}
+	return specs, nil
+}
+
+func ConnectKrakenFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint("kraken_futures", "wss://futures.kraken.com/ws/v1")
+	instruments := resolveSymbols("kraken_futures", symbols)
+	depth := optionsFor("kraken_futures").Depth
 
 	// Maintain orderbooks for each symbol
 	orderbooks := make(map[string]*KrakenOrderBook)
 
+	sup := NewSupervisor("kraken_futures")
+	defer sup.Stopped()
+
 	for {
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			log.Printf("Kraken connection error: %v", err)
-			time.Sleep(5 * time.Second)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("kraken_futures").StaleTimeout)
+
 		log.Printf("Connected to Kraken futures WebSocket")
 
 		// Subscribe to orderbook for each symbol
-		for _, symbol := range symbols {
-			// Convert BTCUSDT to PF_XBTUSD for Kraken
-			krakenSymbol := convertToKrakenSymbol(symbol)
+		for _, instrument := range instruments {
+			// Native product ID (BTCUSDT -> PF_XBTUSD)
+			krakenSymbol := instrument.NativeID
 
 			subscribeMsg := map[string]interface{}{
 				"event":       "subscribe",
@@ -82,30 +234,40 @@ func ConnectKrakenFutures(symbols []string, priceChan chan<- PriceData, orderboo
 				"product_ids": []string{krakenSymbol},
 			}
 
-			err = conn.WriteJSON(subscribeMsg)
+			err = sess.WriteJSON(subscribeMsg)
 			if err != nil {
 				log.Printf("Kraken subscription error for %s: %v", krakenSymbol, err)
 				continue
 			}
 
 			// Initialize orderbook
-			orderbooks[krakenSymbol] = &KrakenOrderBook{
-				Bids: make([]KrakenOrderBookEntry, 0),
-				Asks: make([]KrakenOrderBookEntry, 0),
-			}
+			orderbooks[krakenSymbol] = &KrakenOrderBook{OrderBook: NewOrderBook()}
 		}
 
+		sup.Subscribed()
+
+		// Kraken futures requires a ping at least every 60s
+		stopPing := sess.KeepAlive(30*time.Second, func() error {
+			return sess.pingFrame()
+		})
+
 		for {
 			var rawMessage map[string]interface{}
-			err := conn.ReadJSON(&rawMessage)
+			err := sess.ReadJSON(&rawMessage)
 			if err != nil {
-				log.Printf("Kraken read error: %v", err)
+				if ctx.Err() == nil {
+					log.Printf("Kraken read error: %v", err)
+				}
+				sup.Disconnected(err)
 				conn.Close()
 				break
 			}
+			sess.Message()
+
+			receivedAt := time.Now()
 
 			// Check if it's a book_snapshot or book update
-			if feed, ok := rawMessage["feed"].(string); ok {
+			if _, ok := rawMessage["feed"].(string); ok {
 				var data KrakenOrderBookData
 				messageBytes, _ := json.Marshal(rawMessage)
 				err = json.Unmarshal(messageBytes, &data)
@@ -119,120 +281,331 @@ func ConnectKrakenFutures(symbols []string, priceChan chan<- PriceData, orderboo
 					continue
 				}
 
-				if feed == "book_snapshot" {
-					// Initial snapshot
-					orderbook.Bids = data.Bids
-					orderbook.Asks = data.Asks
-				} else if feed == "book" {
-					// Incremental update
-					updateKrakenOrderbook(orderbook, data)
+				publish, reason := orderbook.apply(data)
+				if reason != "" {
+					if err := resyncKrakenBook(sess, sup, data.ProductID, orderbook, reason); err != nil {
+						log.Printf("Kraken resubscribe error for %s: %v", data.ProductID, err)
+						conn.Close()
+					}
+					continue
+				}
+				if !publish {
+					continue
 				}
 
 				// Send updated orderbook
-				processKrakenOrderbook(data.ProductID, orderbook, orderbookChan)
+				processKrakenOrderbook(data.ProductID, orderbook, depth, int64(data.Timestamp), receivedAt, orderbookChan)
 			}
 		}
 
-		time.Sleep(2 * time.Second)
+		stopPing()
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
 	}
 }
 
-func updateKrakenOrderbook(orderbook *KrakenOrderBook, data KrakenOrderBookData) {
-	if data.Qty == 0 {
-		// Remove price level
-		removePriceLevel(orderbook, data.Side, data.Price)
-	} else {
-		// Update or add price level
-		upsertPriceLevel(orderbook, data.Side, data.Price, data.Qty)
-	}
+// KrakenSpotMessage is a frame on the spot websocket v2. Channel pushes carry
+// channel, type and data; request responses carry method and success.
+type KrakenSpotMessage struct {
+	Channel string          `json:"channel"`
+	Type    string          `json:"type"` // snapshot or update
+	Data    json.RawMessage `json:"data"`
+	Method  string          `json:"method"`
+	Success *bool           `json:"success"`
+	Error   string          `json:"error"`
 }
 
-func removePriceLevel(orderbook *KrakenOrderBook, side string, price float64) {
-	if side == "buy" {
-		for i, entry := range orderbook.Bids {
-			if entry.Price == price {
-				orderbook.Bids = append(orderbook.Bids[:i], orderbook.Bids[i+1:]...)
-				break
-			}
-		}
-	} else if side == "sell" {
-		for i, entry := range orderbook.Asks {
-			if entry.Price == price {
-				orderbook.Asks = append(orderbook.Asks[:i], orderbook.Asks[i+1:]...)
-				break
-			}
+type KrakenSpotTicker struct {
+	Symbol string  `json:"symbol"`
+	Bid    Decimal `json:"bid"`
+	BidQty float64 `json:"bid_qty"`
+	Ask    Decimal `json:"ask"`
+	AskQty float64 `json:"ask_qty"`
+}
+
+// KrakenSpotBook uses the same price/qty entries as the futures book feed
+type KrakenSpotBook struct {
+	Symbol    string                 `json:"symbol"`
+	Bids      []KrakenOrderBookEntry `json:"bids"`
+	Asks      []KrakenOrderBookEntry `json:"asks"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// krakenSpotDepths are the book depths the v2 book channel accepts
+var krakenSpotDepths = []int{10, 25, 100, 500, 1000}
+
+func init() {
+	Register(NewConnector("kraken_spot", MarketSpot, nil, ConnectKrakenSpot))
+
+	// Kraken spot v2 names pairs with BTC rather than XBT (BTC/USD)
+	Instruments.RegisterVenue("kraken_spot", VenueSpec{
+		Quote:    "USD",
+		Contract: ContractSpot,
+		Format: func(base, quote string) string {
+			return base + "/" + quote
+		},
+	})
+
+	RegisterMetadata("kraken_spot", krakenSpotMetadata)
+}
+
+type krakenAssetPairs struct {
+	Error  []string `json:"error"`
+	Result map[string]struct {
+		WSName      string `json:"wsname"`
+		TickSize    string `json:"tick_size"`
+		LotDecimals int    `json:"lot_decimals"`
+		OrderMin    string `json:"ordermin"`
+	} `json:"result"`
+}
+
+// krakenSpotMetadata loads spot pairs. The REST API still uses the v1 names
+// (XBT/USD), which are translated to the v2 ones.
+func krakenSpotMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var pairs krakenAssetPairs
+	if err := getJSON(ctx, "https://api.kraken.com/0/public/AssetPairs", &pairs); err != nil {
+		return nil, err
+	}
+	if len(pairs.Error) > 0 {
+		return nil, fmt.Errorf("AssetPairs: %s", strings.Join(pairs.Error, ", "))
+	}
+
+	v2Names := strings.NewReplacer("XBT/", "BTC/", "XDG/", "DOGE/")
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, pair := range pairs.Result {
+		name := v2Names.Replace(pair.WSName)
+		if !wanted[name] {
+			continue
 		}
+		lotSize := decimalStep(pair.LotDecimals)
+		specs = append(specs, InstrumentSpec{
+			NativeID:     name,
+			TickSize:     parseFloatOrZero(pair.TickSize),
+			LotSize:      lotSize,
+			MinSize:      max(parseFloatOrZero(pair.OrderMin), lotSize),
+			ContractSize: 1,
+		})
 	}
+	return specs, nil
 }
 
-func upsertPriceLevel(orderbook *KrakenOrderBook, side string, price, qty float64) {
-	newEntry := KrakenOrderBookEntry{Price: price, Qty: qty}
+// ConnectKrakenSpot streams Kraken spot over websocket v2. Top of book comes
+// from the ticker channel; when a depth is configured the book channel is
+// maintained locally instead so levels can be sent.
+func ConnectKrakenSpot(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint("kraken_spot", "wss://ws.kraken.com/v2")
+	instruments := resolveSymbols("kraken_spot", symbols)
+	depth := optionsFor("kraken_spot").Depth
+
+	pairs := make([]string, len(instruments))
+	for i, instrument := range instruments {
+		pairs[i] = instrument.NativeID
+	}
+
+	// Subscribe to the smallest book depth Kraken offers that covers ours
+	bookDepth := krakenSpotDepths[len(krakenSpotDepths)-1]
+	for _, d := range krakenSpotDepths {
+		if d >= depth {
+			bookDepth = d
+			break
+		}
+	}
+
+	orderbooks := make(map[string]*KrakenOrderBook)
 
-	if side == "buy" {
-		// Bids are sorted in descending order (highest first)
-		for i, entry := range orderbook.Bids {
-			if entry.Price == price {
-				// Update existing level
-				orderbook.Bids[i].Qty = qty
+	sup := NewSupervisor("kraken_spot")
+	defer sup.Stopped()
+
+	for {
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		if err != nil {
+			if ctx.Err() != nil {
 				return
 			}
-
-			if price > entry.Price {
-				// Insert new level
-				orderbook.Bids = append(orderbook.Bids[:i], append([]KrakenOrderBookEntry{newEntry}, orderbook.Bids[i:]...)...)
+			log.Printf("Kraken spot connection error: %v", err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
 				return
 			}
+			continue
+		}
+
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("kraken_spot").StaleTimeout)
+
+		log.Printf("Connected to Kraken spot WebSocket")
+
+		subscribeBook := func(method string, pairs []string) error {
+			return sess.WriteJSON(map[string]interface{}{
+				"method": method,
+				"params": map[string]interface{}{
+					"channel": "book",
+					"symbol":  pairs,
+					"depth":   bookDepth,
+				},
+			})
+		}
+
+		if depth > 0 {
+			for _, pair := range pairs {
+				orderbooks[pair] = &KrakenOrderBook{OrderBook: NewOrderBook()}
+			}
+			err = subscribeBook("subscribe", pairs)
+		} else {
+			// bbo only pushes the ticker when the best bid or offer changes
+			err = sess.WriteJSON(map[string]interface{}{
+				"method": "subscribe",
+				"params": map[string]interface{}{
+					"channel":       "ticker",
+					"symbol":        pairs,
+					"event_trigger": "bbo",
+				},
+			})
 		}
-		// Append to end if not inserted
-		orderbook.Bids = append(orderbook.Bids, newEntry)
-	} else if side == "sell" {
-		// Asks are sorted in ascending order (lowest first)
-		for i, entry := range orderbook.Asks {
-			if entry.Price == price {
-				// Update existing level
-				orderbook.Asks[i].Qty = qty
+		if err != nil {
+			log.Printf("Kraken spot subscription error: %v", err)
+			sup.Disconnected(err)
+			stopClose()
+			conn.Close()
+			if !sup.Backoff(ctx) {
 				return
 			}
+			continue
+		}
 
-			if price < entry.Price {
-				// Insert new level
-				orderbook.Asks = append(orderbook.Asks[:i], append([]KrakenOrderBookEntry{newEntry}, orderbook.Asks[i:]...)...)
-				return
+		sup.Subscribed()
+
+		// resync drops a book and resubscribes it for a new snapshot
+		resync := func(pair string, orderbook *KrakenOrderBook, reason string) {
+			log.Printf("Kraken spot %s book resync: %s", pair, reason)
+			sup.Resync(pair + ": " + reason)
+			orderbook.reset()
+
+			err := subscribeBook("unsubscribe", []string{pair})
+			if err == nil {
+				err = subscribeBook("subscribe", []string{pair})
+			}
+			if err != nil {
+				log.Printf("Kraken spot resubscribe error for %s: %v", pair, err)
+				conn.Close()
 			}
 		}
-		// Append to end if not inserted
-		orderbook.Asks = append(orderbook.Asks, newEntry)
-	}
-}
 
-func convertToKrakenSymbol(symbol string) string {
-	// Convert BTCUSDT to PF_XBTUSD (Kraken's format for perpetual futures)
-	switch symbol {
-	case "BTCUSDT":
-		return "PF_XBTUSD"
-	case "ETHUSDT":
-		return "PF_ETHUSD"
-	case "XRPUSDT":
-		return "PF_XRPUSD"
-	case "SOLUSDT":
-		return "PF_SOLUSD"
-	default:
-		return symbol
-	}
-}
+		for {
+			var message KrakenSpotMessage
+			err := sess.ReadJSON(&message)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Kraken spot read error: %v", err)
+				}
+				sup.Disconnected(err)
+				conn.Close()
+				break
+			}
 
-func convertFromKrakenSymbol(symbol string) string {
-	// Convert PF_XBTUSD to BTCUSDT (standard format)
-	switch symbol {
-	case "PF_XBTUSD":
-		return "BTCUSDT"
-	case "PF_ETHUSD":
-		return "ETHUSDT"
-	case "PF_XRPUSD":
-		return "XRPUSDT"
-	case "PF_SOLUSD":
-		return "SOLUSDT"
-	default:
-		return symbol
+			receivedAt := time.Now()
+
+			if message.Success != nil && !*message.Success {
+				log.Printf("Kraken spot %s error: %s", message.Method, message.Error)
+				continue
+			}
+			// Heartbeats, status and request replies don't count as data for
+			// the stale watchdog
+			if message.Channel != "ticker" && message.Channel != "book" {
+				continue
+			}
+			sess.Message()
+
+			switch message.Channel {
+			case "ticker":
+				var tickers []KrakenSpotTicker
+				if err := json.Unmarshal(message.Data, &tickers); err != nil {
+					log.Printf("Kraken spot ticker unmarshal error: %v", err)
+					continue
+				}
+
+				for _, ticker := range tickers {
+					if ticker.Bid.Sign() <= 0 || ticker.Ask.Sign() <= 0 {
+						continue
+					}
+
+					instrument, ok := Instruments.Lookup("kraken_spot", ticker.Symbol)
+					if !ok {
+						continue
+					}
+
+					// The ticker carries no exchange timestamp
+					orderbookChan <- OrderbookData{
+						Symbol:      instrument.Symbol,
+						Source:      "kraken_spot",
+						BestBid:     instrument.NormalizePrice(ticker.Bid),
+						BestAsk:     instrument.NormalizePrice(ticker.Ask),
+						BestBidSize: instrument.NormalizeSize(ticker.BidQty, ticker.Bid),
+						BestAskSize: instrument.NormalizeSize(ticker.AskQty, ticker.Ask),
+						ReceivedAt:  receivedAt,
+					}
+				}
+
+			case "book":
+				var books []KrakenSpotBook
+				if err := json.Unmarshal(message.Data, &books); err != nil {
+					log.Printf("Kraken spot book unmarshal error: %v", err)
+					continue
+				}
+
+				for _, data := range books {
+					orderbook, exists := orderbooks[data.Symbol]
+					if !exists {
+						continue
+					}
+
+					if message.Type == "snapshot" {
+						orderbook.ApplySnapshot(krakenLevels(data.Bids), krakenLevels(data.Asks))
+						orderbook.Synced = true
+					} else {
+						if !orderbook.Synced {
+							continue
+						}
+						orderbook.ApplyDelta(krakenLevels(data.Bids), krakenLevels(data.Asks))
+						// Levels pushed out of the subscribed depth get no
+						// further updates, so they must not linger
+						orderbook.Truncate(bookDepth)
+					}
+
+					if orderbook.Crossed() {
+						resync(data.Symbol, orderbook, "crossed book")
+						continue
+					}
+
+					instrument, ok := Instruments.Lookup("kraken_spot", data.Symbol)
+					if !ok {
+						continue
+					}
+
+					var timestamp int64
+					if !data.Timestamp.IsZero() {
+						timestamp = data.Timestamp.UnixMilli()
+					}
+
+					orderbookData, ok := orderbook.OrderbookData(instrument, depth, timestamp, receivedAt)
+					if !ok {
+						continue
+					}
+
+					orderbookChan <- orderbookData
+				}
+			}
+		}
+
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,196 @@
+package exchanges
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func krakenSnapshot(t *testing.T, seq int64, bid, ask string) KrakenOrderBookData {
+	return KrakenOrderBookData{
+		Feed:      "book_snapshot",
+		ProductID: "PF_XBTUSD",
+		Seq:       seq,
+		Bids:      []KrakenOrderBookEntry{{Price: mustDecimal(t, bid), Qty: 1}},
+		Asks:      []KrakenOrderBookEntry{{Price: mustDecimal(t, ask), Qty: 1}},
+	}
+}
+
+func krakenDelta(t *testing.T, seq int64, side, price string, qty float64) KrakenOrderBookData {
+	return KrakenOrderBookData{Feed: "book", ProductID: "PF_XBTUSD", Seq: seq, Side: side, Price: mustDecimal(t, price), Qty: qty}
+}
+
+func TestKrakenOrderBookApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		messages    []KrakenOrderBookData
+		wantPublish bool
+		wantResync  string
+		wantBid     string
+		wantAsk     string
+	}{
+		{
+			name:     "delta before snapshot",
+			messages: []KrakenOrderBookData{krakenDelta(t, 5, "buy", "100", 1)},
+		},
+		{
+			name:        "snapshot",
+			messages:    []KrakenOrderBookData{krakenSnapshot(t, 10, "100", "101")},
+			wantPublish: true,
+			wantBid:     "100",
+			wantAsk:     "101",
+		},
+		{
+			name:        "delta in sequence",
+			messages:    []KrakenOrderBookData{krakenSnapshot(t, 10, "100", "101"), krakenDelta(t, 11, "buy", "100.5", 2)},
+			wantPublish: true,
+			wantBid:     "100.5",
+			wantAsk:     "101",
+		},
+		{
+			name:        "level removed",
+			messages:    []KrakenOrderBookData{krakenSnapshot(t, 10, "100", "101"), krakenDelta(t, 11, "sell", "102", 1), krakenDelta(t, 12, "sell", "101", 0)},
+			wantPublish: true,
+			wantBid:     "100",
+			wantAsk:     "102",
+		},
+		{
+			name:       "sequence gap",
+			messages:   []KrakenOrderBookData{krakenSnapshot(t, 10, "100", "101"), krakenDelta(t, 12, "buy", "100.5", 2)},
+			wantResync: "sequence gap: expected 11, got 12",
+		},
+		{
+			name:       "replayed delta",
+			messages:   []KrakenOrderBookData{krakenSnapshot(t, 10, "100", "101"), krakenDelta(t, 10, "buy", "100.5", 2)},
+			wantResync: "sequence gap",
+		},
+		{
+			name:       "delta crosses the book",
+			messages:   []KrakenOrderBookData{krakenSnapshot(t, 10, "100", "101"), krakenDelta(t, 11, "buy", "101.5", 1)},
+			wantResync: "crossed book: bid 101.5 >= ask 101",
+		},
+		{
+			name:       "crossed snapshot",
+			messages:   []KrakenOrderBookData{krakenSnapshot(t, 10, "101", "101")},
+			wantResync: "crossed book",
+		},
+		{
+			name:     "other feed",
+			messages: []KrakenOrderBookData{krakenSnapshot(t, 10, "100", "101"), {Feed: "ticker", ProductID: "PF_XBTUSD", Seq: 11}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			book := &KrakenOrderBook{OrderBook: NewOrderBook()}
+			var publish bool
+			var resync string
+			for _, message := range test.messages {
+				publish, resync = book.apply(message)
+			}
+
+			if publish != test.wantPublish {
+				t.Errorf("publish = %v, want %v", publish, test.wantPublish)
+			}
+			if test.wantResync == "" && resync != "" {
+				t.Errorf("unexpected resync: %s", resync)
+			}
+			if !strings.Contains(resync, test.wantResync) {
+				t.Errorf("resync = %q, want %q", resync, test.wantResync)
+			}
+			if test.wantPublish {
+				bestBid, _ := book.BestBid()
+				bestAsk, _ := book.BestAsk()
+				if bestBid.Price.String() != test.wantBid || bestAsk.Price.String() != test.wantAsk {
+					t.Errorf("top of book = %v/%v, want %v/%v", bestBid.Price, bestAsk.Price, test.wantBid, test.wantAsk)
+				}
+			}
+		})
+	}
+}
+
+// recordingServer collects the JSON messages a client writes
+func recordingServer(t *testing.T) (string, <-chan map[string]interface{}) {
+	t.Helper()
+	received := make(chan map[string]interface{}, 16)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var message map[string]interface{}
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			received <- message
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http"), received
+}
+
+func TestResyncKrakenBook(t *testing.T) {
+	url, received := recordingServer(t)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sup := NewSupervisor("test_kraken_resync")
+	sess := newSession(conn, sup, time.Minute)
+
+	triggers := map[string][]KrakenOrderBookData{
+		"gap":     {krakenSnapshot(t, 10, "100", "101"), krakenDelta(t, 12, "buy", "100.5", 1)},
+		"crossed": {krakenSnapshot(t, 10, "100", "101"), krakenDelta(t, 11, "sell", "99", 1)},
+	}
+	var resyncs int64
+	for name, messages := range triggers {
+		book := &KrakenOrderBook{OrderBook: NewOrderBook()}
+		var reason string
+		for _, message := range messages {
+			_, reason = book.apply(message)
+		}
+		if reason == "" {
+			t.Fatalf("%s: no resync requested", name)
+		}
+
+		if err := resyncKrakenBook(sess, sup, "PF_XBTUSD", book, reason); err != nil {
+			t.Fatalf("%s: resyncKrakenBook: %v", name, err)
+		}
+		resyncs++
+
+		if status := sup.Status(); status.Resyncs != resyncs || !strings.HasSuffix(status.LastResync, reason) {
+			t.Errorf("%s: status resyncs %d (%q), want %d (%q)", name, status.Resyncs, status.LastResync, resyncs, reason)
+		}
+		_, hasBid := book.BestBid()
+		_, hasAsk := book.BestAsk()
+		if book.Synced || hasBid || hasAsk {
+			t.Errorf("%s: book not reset: %+v", name, book)
+		}
+		// Deltas are ignored until the new snapshot arrives
+		if publish, _ := book.apply(krakenDelta(t, 13, "buy", "100", 1)); publish {
+			t.Errorf("%s: delta applied while resyncing", name)
+		}
+
+		for _, want := range []string{"unsubscribe", "subscribe"} {
+			select {
+			case message := <-received:
+				if message["event"] != want || message["feed"] != "book" {
+					t.Errorf("%s: sent %v, want %s of the book feed", name, message, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: no %s sent", name, want)
+			}
+		}
+	}
+}
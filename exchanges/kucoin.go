@@ -0,0 +1,310 @@
+package exchanges
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// KucoinMessage is the envelope of every KuCoin websocket frame
+type KucoinMessage struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"` // welcome, ack, pong, message, error
+	Topic   string          `json:"topic"`
+	Subject string          `json:"subject"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type KucoinTickerV2 struct {
+	Symbol       string `json:"symbol"`
+	BestBidPrice string `json:"bestBidPrice"`
+	BestBidSize  int64  `json:"bestBidSize"`
+	BestAskPrice string `json:"bestAskPrice"`
+	BestAskSize  int64  `json:"bestAskSize"`
+	Timestamp    int64  `json:"ts"` // nanoseconds
+}
+
+type KucoinExecution struct {
+	Symbol    string `json:"symbol"`
+	Side      string `json:"side"`
+	Size      int64  `json:"size"`
+	Price     string `json:"price"`
+	TradeID   string `json:"tradeId"`
+	Timestamp int64  `json:"ts"` // nanoseconds
+}
+
+// kucoinBullet is the bullet-public response: a one time token and the
+// servers it is valid for
+type kucoinBullet struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Token           string `json:"token"`
+		InstanceServers []struct {
+			Endpoint     string `json:"endpoint"`
+			PingInterval int64  `json:"pingInterval"` // ms
+		} `json:"instanceServers"`
+	} `json:"data"`
+}
+
+func init() {
+	Register(NewConnector("kucoin_futures", MarketFutures, nil, ConnectKucoinFutures))
+
+	// KuCoin USDT-M perps are quoted in contracts of a per symbol multiplier
+	// and call BTC XBT (XBTUSDTM)
+	Instruments.RegisterVenue("kucoin_futures", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractPerpetual,
+		Format: func(base, quote string) string {
+			return base + quote + "M"
+		},
+		Aliases: map[string]BaseAlias{
+			"BTC": {Name: "XBT", Multiplier: 1},
+		},
+		Contracts: true,
+	})
+
+	RegisterMetadata("kucoin_futures", kucoinMetadata)
+}
+
+type kucoinContracts struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		Symbol     string  `json:"symbol"`
+		TickSize   float64 `json:"tickSize"`
+		LotSize    float64 `json:"lotSize"`
+		Multiplier float64 `json:"multiplier"`
+	} `json:"data"`
+}
+
+// kucoinMetadata loads active contracts; multiplier is the base amount of
+// one contract
+func kucoinMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var contracts kucoinContracts
+	if err := getJSON(ctx, "https://api-futures.kucoin.com/api/v1/contracts/active", &contracts); err != nil {
+		return nil, err
+	}
+	if contracts.Code != "200000" {
+		return nil, fmt.Errorf("contracts: %s", contracts.Msg)
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, contract := range contracts.Data {
+		if !wanted[contract.Symbol] {
+			continue
+		}
+		specs = append(specs, InstrumentSpec{
+			NativeID:     contract.Symbol,
+			TickSize:     contract.TickSize,
+			LotSize:      contract.LotSize,
+			MinSize:      contract.LotSize,
+			ContractSize: contract.Multiplier,
+		})
+	}
+	return specs, nil
+}
+
+// kucoinDial negotiates a token through bullet-public and opens the
+// websocket it points to. Tokens are single use, so this runs on every
+// reconnect. It returns the server's required ping interval.
+func kucoinDial(ctx context.Context, bulletURL string) (*websocket.Conn, time.Duration, error) {
+	var bullet kucoinBullet
+	if err := postJSON(ctx, bulletURL, struct{}{}, &bullet); err != nil {
+		return nil, 0, fmt.Errorf("bullet-public: %w", err)
+	}
+	if bullet.Code != "200000" {
+		return nil, 0, fmt.Errorf("bullet-public: %s %s", bullet.Code, bullet.Msg)
+	}
+	if len(bullet.Data.InstanceServers) == 0 {
+		return nil, 0, fmt.Errorf("bullet-public: no instance servers")
+	}
+	server := bullet.Data.InstanceServers[0]
+
+	query := url.Values{}
+	query.Set("token", bullet.Data.Token)
+	query.Set("connectId", strconv.FormatInt(time.Now().UnixNano(), 10))
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, server.Endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pingInterval := time.Duration(server.PingInterval) * time.Millisecond
+	if pingInterval <= 0 {
+		pingInterval = 18 * time.Second
+	}
+	return conn, pingInterval, nil
+}
+
+// ConnectKucoinFutures streams KuCoin USDT-M top of book (tickerV2) and
+// trades (execution). The configured endpoint, if any, replaces the
+// bullet-public URL since the websocket URL itself is handed out per token.
+func ConnectKucoinFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	bulletURL := endpoint("kucoin_futures", "https://api-futures.kucoin.com/api/v1/bullet-public")
+	instruments := resolveSymbols("kucoin_futures", symbols)
+
+	nativeIDs := make([]string, len(instruments))
+	for i, instrument := range instruments {
+		nativeIDs[i] = instrument.NativeID
+	}
+	joined := strings.Join(nativeIDs, ",")
+
+	sup := NewSupervisor("kucoin_futures")
+	defer sup.Stopped()
+
+	for {
+		sup.Connecting()
+		conn, pingInterval, err := kucoinDial(ctx, bulletURL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("KuCoin futures connection error: %v", err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("kucoin_futures").StaleTimeout)
+
+		log.Printf("Connected to KuCoin futures WebSocket")
+
+		// The server greets with a welcome before it accepts subscriptions
+		var welcome KucoinMessage
+		err = sess.ReadJSON(&welcome)
+		if err == nil && welcome.Type != "welcome" {
+			err = fmt.Errorf("expected welcome, got %q", welcome.Type)
+		}
+		for i, topic := range []string{"/contractMarket/tickerV2:", "/contractMarket/execution:"} {
+			if err != nil {
+				break
+			}
+			err = sess.WriteJSON(map[string]interface{}{
+				"id":       strconv.Itoa(i + 1),
+				"type":     "subscribe",
+				"topic":    topic + joined,
+				"response": true,
+			})
+		}
+		if err != nil {
+			log.Printf("KuCoin futures subscription error: %v", err)
+			sup.Disconnected(err)
+			stopClose()
+			conn.Close()
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		sup.Subscribed()
+
+		// KuCoin closes the socket unless pinged within the interval it
+		// handed out with the token
+		stopPing := sess.KeepAlive(pingInterval, func() error {
+			return sess.WriteJSON(map[string]string{
+				"id":   strconv.FormatInt(time.Now().UnixMilli(), 10),
+				"type": "ping",
+			})
+		})
+
+		for {
+			var message KucoinMessage
+			err := sess.ReadJSON(&message)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("KuCoin futures read error: %v", err)
+				}
+				sup.Disconnected(err)
+				conn.Close()
+				break
+			}
+
+			receivedAt := time.Now()
+
+			if message.Type == "error" {
+				log.Printf("KuCoin futures error: %s", message.Data)
+				continue
+			}
+			// Pongs and acks don't count as data for the stale watchdog
+			if message.Type != "message" {
+				continue
+			}
+			sess.Message()
+
+			switch message.Subject {
+			case "tickerV2":
+				var ticker KucoinTickerV2
+				if err := json.Unmarshal(message.Data, &ticker); err != nil {
+					continue
+				}
+
+				bidPrice, err1 := ParseDecimal(ticker.BestBidPrice)
+				askPrice, err2 := ParseDecimal(ticker.BestAskPrice)
+				if err1 != nil || err2 != nil {
+					continue
+				}
+
+				instrument, ok := Instruments.Lookup("kucoin_futures", ticker.Symbol)
+				if !ok {
+					continue
+				}
+
+				orderbookChan <- OrderbookData{
+					Symbol:      instrument.Symbol,
+					Source:      "kucoin_futures",
+					BestBid:     instrument.NormalizePrice(bidPrice),
+					BestAsk:     instrument.NormalizePrice(askPrice),
+					BestBidSize: instrument.NormalizeSize(float64(ticker.BestBidSize), bidPrice),
+					BestAskSize: instrument.NormalizeSize(float64(ticker.BestAskSize), askPrice),
+					Timestamp:   ticker.Timestamp / int64(time.Millisecond),
+					ReceivedAt:  receivedAt,
+				}
+
+			case "match":
+				var trade KucoinExecution
+				if err := json.Unmarshal(message.Data, &trade); err != nil {
+					continue
+				}
+
+				price, err := ParseDecimal(trade.Price)
+				if err != nil {
+					continue
+				}
+
+				instrument, ok := Instruments.Lookup("kucoin_futures", trade.Symbol)
+				if !ok {
+					continue
+				}
+
+				// side is the taker side
+				side := "sell"
+				if trade.Side == "buy" {
+					side = "buy"
+				}
+
+				tradeChan <- newTradeData(instrument, price, strconv.FormatInt(trade.Size, 10), side, trade.Timestamp/int64(time.Millisecond), receivedAt)
+			}
+		}
+
+		stopPing()
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package exchanges
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InstrumentSpec holds the trading rules of one venue instrument. Sizes are
+// in the venue's quantity unit, which is a contract count on venues like OKX
+// and Gate; ContractSize converts that unit into base asset.
+type InstrumentSpec struct {
+	NativeID     string  `json:"native_id"`
+	TickSize     float64 `json:"tick_size,omitempty"`
+	LotSize      float64 `json:"lot_size,omitempty"`
+	MinSize      float64 `json:"min_size,omitempty"`
+	ContractSize float64 `json:"contract_size,omitempty"`
+	// Inverse and Quanto mark the contract kind on venues where it differs
+	// per instrument rather than per venue (BitMEX)
+	Inverse bool `json:"inverse,omitempty"`
+	Quanto  bool `json:"quanto,omitempty"`
+	// Settlement overrides the venue's settlement currency when it differs
+	// per instrument (BitMEX XBt and USDt margined contracts)
+	Settlement string `json:"settlement,omitempty"`
+	// Expiry is the delivery time of dated futures in Unix ms
+	Expiry int64 `json:"expiry,omitempty"`
+}
+
+// MetadataFetcher downloads the specs of the given native IDs from a venue's
+// REST API. It may return specs for more instruments than asked for.
+type MetadataFetcher func(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error)
+
+var (
+	metadataFetchers      = make(map[string]MetadataFetcher)
+	metadataFetchersMutex sync.RWMutex
+)
+
+// RegisterMetadata sets the spec fetcher for a venue. Venues without one
+// keep a contract size of 1 and no tick or lot size.
+func RegisterMetadata(venue string, fetch MetadataFetcher) {
+	metadataFetchersMutex.Lock()
+	defer metadataFetchersMutex.Unlock()
+
+	metadataFetchers[venue] = fetch
+}
+
+// metadataCache is the on-disk format of the spec cache
+type metadataCache struct {
+	Updated int64                                `json:"updated"` // Unix ms
+	Venues  map[string]map[string]InstrumentSpec `json:"venues"`
+}
+
+// LoadInstrumentSpecs fetches the specs of every watched symbol on the given
+// venues and installs them in Instruments. Venues whose API can't be reached
+// fall back to the specs saved in cacheFile by an earlier run, so the scanner
+// still works offline. Successful fetches are written back to cacheFile.
+func LoadInstrumentSpecs(ctx context.Context, venues, symbols []string, cacheFile string) {
+	cache := readMetadataCache(cacheFile)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		fetched int
+	)
+	for _, venue := range venues {
+		metadataFetchersMutex.RLock()
+		fetch, ok := metadataFetchers[venue]
+		metadataFetchersMutex.RUnlock()
+		if !ok {
+			continue
+		}
+
+		var nativeIDs []string
+		for _, symbol := range symbols {
+			if vi, err := Instruments.Resolve(venue, symbol); err == nil {
+				nativeIDs = append(nativeIDs, vi.NativeID)
+			}
+		}
+		if len(nativeIDs) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			specs, err := fetch(ctx, nativeIDs)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				log.Printf("%s: instrument metadata fetch failed: %v", venue, err)
+			} else {
+				if cache.Venues[venue] == nil {
+					cache.Venues[venue] = make(map[string]InstrumentSpec)
+				}
+				for _, spec := range specs {
+					cache.Venues[venue][spec.NativeID] = spec
+				}
+				fetched++
+			}
+
+			var missing []string
+			for _, nativeID := range nativeIDs {
+				spec, ok := cache.Venues[venue][nativeID]
+				if !ok {
+					missing = append(missing, nativeID)
+					continue
+				}
+				Instruments.SetSpec(venue, spec)
+			}
+			if len(missing) > 0 {
+				log.Printf("%s: no instrument metadata for %s", venue, strings.Join(missing, ", "))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if fetched > 0 && cacheFile != "" {
+		cache.Updated = time.Now().UnixMilli()
+		if err := writeMetadataCache(cacheFile, cache); err != nil {
+			log.Printf("Instrument metadata cache write error: %v", err)
+		}
+	}
+}
+
+func readMetadataCache(path string) *metadataCache {
+	cache := &metadataCache{Venues: make(map[string]map[string]InstrumentSpec)}
+	if path == "" {
+		return cache
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Instrument metadata cache read error: %v", err)
+		}
+		return cache
+	}
+	if err := json.Unmarshal(data, cache); err != nil {
+		log.Printf("Instrument metadata cache %s is corrupt, ignoring: %v", path, err)
+		return &metadataCache{Venues: make(map[string]map[string]InstrumentSpec)}
+	}
+	if cache.Venues == nil {
+		cache.Venues = make(map[string]map[string]InstrumentSpec)
+	}
+	return cache
+}
+
+func writeMetadataCache(path string, cache *metadataCache) error {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so a crash never leaves a half written cache
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// metadataClient is used for all metadata requests; the context passed to
+// LoadInstrumentSpecs bounds the total time
+var metadataClient = &http.Client{Timeout: 15 * time.Second}
+
+// getJSON fetches url and decodes the JSON response into v
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(req, v)
+}
+
+// postJSON posts body as JSON to url and decodes the response into v
+func postJSON(ctx context.Context, url string, body, v interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doJSON(req, v)
+}
+
+func doJSON(req *http.Request, v interface{}) error {
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: unexpected status %s: %s", req.URL.Path, resp.Status, bytes.TrimSpace(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// parseFloatOrZero parses a numeric string from a venue API, treating empty or
+// malformed values as unknown (zero)
+func parseFloatOrZero(value string) float64 {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return parsed
+}
+
+// decimalStep returns the step for a number of decimal places (3 -> 0.001)
+func decimalStep(decimals int) float64 {
+	return math.Pow10(-decimals)
+}
+
+// wantedIDs turns the requested native IDs into a set for filtering bulk
+// instrument listings
+func wantedIDs(nativeIDs []string) map[string]bool {
+	wanted := make(map[string]bool, len(nativeIDs))
+	for _, id := range nativeIDs {
+		wanted[id] = true
+	}
+	return wanted
+}
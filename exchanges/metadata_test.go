@@ -0,0 +1,132 @@
+package exchanges
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// registerTestVenue sets up a venue whose metadata comes from fetch
+func registerTestVenue(venue string, fetch MetadataFetcher) {
+	Instruments.RegisterVenue(venue, VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractPerpetual,
+		Format:   concatFormat,
+	})
+	RegisterMetadata(venue, fetch)
+}
+
+func TestLoadInstrumentSpecsFetch(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "instruments.json")
+	spec := InstrumentSpec{NativeID: "BTCUSDT", TickSize: 0.1, LotSize: 0.001, ContractSize: 0.01}
+	registerTestVenue("test_meta_fetch", func(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+		return []InstrumentSpec{spec}, nil
+	})
+
+	LoadInstrumentSpecs(context.Background(), []string{"test_meta_fetch"}, []string{"BTCUSDT"}, cacheFile)
+
+	vi, err := Instruments.Resolve("test_meta_fetch", "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vi.Spec != spec {
+		t.Errorf("spec = %+v, want %+v", vi.Spec, spec)
+	}
+
+	cache := readMetadataCache(cacheFile)
+	if got := cache.Venues["test_meta_fetch"]["BTCUSDT"]; got != spec {
+		t.Errorf("cached spec = %+v, want %+v", got, spec)
+	}
+	if cache.Updated == 0 {
+		t.Error("cache has no update time")
+	}
+}
+
+func TestLoadInstrumentSpecsFallsBackToCache(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "instruments.json")
+	spec := InstrumentSpec{NativeID: "ETHUSDT", TickSize: 0.01, ContractSize: 0.1}
+	cached := &metadataCache{
+		Updated: 1,
+		Venues:  map[string]map[string]InstrumentSpec{"test_meta_offline": {"ETHUSDT": spec}},
+	}
+	if err := writeMetadataCache(cacheFile, cached); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registerTestVenue("test_meta_offline", func(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	LoadInstrumentSpecs(context.Background(), []string{"test_meta_offline"}, []string{"ETHUSDT"}, cacheFile)
+
+	vi, err := Instruments.Resolve("test_meta_offline", "ETHUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vi.Spec != spec {
+		t.Errorf("spec = %+v, want the cached %+v", vi.Spec, spec)
+	}
+
+	// Nothing was fetched, so the cache is left as it was
+	after, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Error("cache rewritten although every fetch failed")
+	}
+}
+
+func TestWriteMetadataCache(t *testing.T) {
+	dir := t.TempDir()
+	cacheFile := filepath.Join(dir, "instruments.json")
+
+	// A temp file left behind by a crashed run is replaced, and the old
+	// cache stays intact until the rename
+	if err := os.WriteFile(cacheFile+".tmp", []byte("{half"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cacheFile, []byte(`{"updated": 1, "venues": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := &metadataCache{
+		Updated: 2,
+		Venues: map[string]map[string]InstrumentSpec{
+			"okx_futures": {"BTC-USDT-SWAP": {NativeID: "BTC-USDT-SWAP", TickSize: 0.1, ContractSize: 0.01}},
+		},
+	}
+	if err := writeMetadataCache(cacheFile, cache); err != nil {
+		t.Fatalf("writeMetadataCache: %v", err)
+	}
+
+	if _, err := os.Stat(cacheFile + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temp file left behind: %v", err)
+	}
+	if got := readMetadataCache(cacheFile); !reflect.DeepEqual(got, cache) {
+		t.Errorf("read back %+v, want %+v", got, cache)
+	}
+}
+
+func TestReadMetadataCacheCorrupt(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "instruments.json")
+	if err := os.WriteFile(cacheFile, []byte("{half"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := readMetadataCache(cacheFile)
+	if cache.Venues == nil || len(cache.Venues) != 0 {
+		t.Errorf("corrupt cache read as %+v, want an empty cache", cache)
+	}
+
+	if cache := readMetadataCache(filepath.Join(t.TempDir(), "missing.json")); cache.Venues == nil {
+		t.Error("missing cache has no venue map")
+	}
+}
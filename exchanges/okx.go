@@ -1,11 +1,11 @@
 package exchanges
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -26,16 +26,22 @@ type OKXFuturesTrade struct {
 	} `json:"data"`
 }
 
+// OKXFuturesOrderbook is a books5 or books push. books5 always carries the
+// full top five levels; books sends a snapshot and then updates chained by
+// prevSeqId.
 type OKXFuturesOrderbook struct {
 	Arg struct {
 		Channel string `json:"channel"`
 		InstID  string `json:"instId"`
 	} `json:"arg"`
-	Data []struct {
+	Action string `json:"action"` // snapshot or update, books only
+	Data   []struct {
 		InstID    string     `json:"instId"`
 		Bids      [][]string `json:"bids"`
 		Asks      [][]string `json:"asks"`
 		Timestamp string     `json:"ts"`
+		SeqID     int64      `json:"seqId"`
+		PrevSeqID int64      `json:"prevSeqId"`
 	} `json:"data"`
 }
 
@@ -47,18 +53,134 @@ type OKXSubscribeMessage struct {
 	} `json:"args"`
 }
 
-func ConnectOKXFutures(symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
-	wsURL := "wss://ws.okx.com:8443/ws/v5/public"
+func init() {
+	Register(NewConnector("okx_futures", MarketFutures, nil, ConnectOKXFutures))
+	Register(NewConnector("okx_spot", MarketSpot, nil, ConnectOKXSpot))
+
+	Instruments.RegisterVenue("okx_futures", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractPerpetual,
+		Format: func(base, quote string) string {
+			return base + "-" + quote + "-SWAP"
+		},
+		// Sizes are contract counts, see okxMetadata
+		Contracts: true,
+	})
+
+	Instruments.RegisterVenue("okx_spot", VenueSpec{
+		Quote:    "USDT",
+		Contract: ContractSpot,
+		Format: func(base, quote string) string {
+			return base + "-" + quote
+		},
+	})
+
+	RegisterMetadata("okx_futures", okxMetadata)
+	RegisterMetadata("okx_spot", okxSpotMetadata)
+}
+
+type okxInstruments struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		InstID string `json:"instId"`
+		CtVal  string `json:"ctVal"`
+		TickSz string `json:"tickSz"`
+		LotSz  string `json:"lotSz"`
+		MinSz  string `json:"minSz"`
+	} `json:"data"`
+}
+
+// okxMetadata loads swap specs. OKX swap sizes are contracts worth ctVal of
+// the base asset each (0.01 BTC for BTC-USDT-SWAP).
+func okxMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	return fetchOKXInstruments(ctx, "SWAP", nativeIDs)
+}
+
+// okxSpotMetadata loads spot specs; spot sizes are in the base asset
+func okxSpotMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	return fetchOKXInstruments(ctx, "SPOT", nativeIDs)
+}
+
+func fetchOKXInstruments(ctx context.Context, instType string, nativeIDs []string) ([]InstrumentSpec, error) {
+	var instruments okxInstruments
+	if err := getJSON(ctx, "https://www.okx.com/api/v5/public/instruments?instType="+instType, &instruments); err != nil {
+		return nil, err
+	}
+	if instruments.Code != "0" {
+		return nil, fmt.Errorf("public/instruments: %s", instruments.Msg)
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, instrument := range instruments.Data {
+		if !wanted[instrument.InstID] {
+			continue
+		}
+		contractSize := parseFloatOrZero(instrument.CtVal)
+		if instType == "SPOT" {
+			contractSize = 1
+		}
+		specs = append(specs, InstrumentSpec{
+			NativeID:     instrument.InstID,
+			TickSize:     parseFloatOrZero(instrument.TickSz),
+			LotSize:      parseFloatOrZero(instrument.LotSz),
+			MinSize:      parseFloatOrZero(instrument.MinSz),
+			ContractSize: contractSize,
+		})
+	}
+	return specs, nil
+}
+
+func ConnectOKXFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectOKX(ctx, "okx_futures", "futures", symbols, orderbookChan, tradeChan)
+}
+
+// ConnectOKXSpot streams SPOT instType books and trades; the messages are
+// the same as on swaps
+func ConnectOKXSpot(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	connectOKX(ctx, "okx_spot", "spot", symbols, orderbookChan, tradeChan)
+}
+
+// connectOKX runs one OKX public feed; market only names it in logs
+func connectOKX(ctx context.Context, source, market string, symbols []string, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint(source, "wss://ws.okx.com:8443/ws/v5/public")
+	instruments := resolveSymbols(source, symbols)
+	depth := optionsFor(source).Depth
+
+	// books5 only has five levels; deeper books need the incremental 400
+	// level channel
+	bookChannel := "books5"
+	if depth > 5 {
+		bookChannel = "books"
+	}
+
+	books := make(map[string]*OrderBook)
+
+	sup := NewSupervisor(source)
+	defer sup.Stopped()
 
 	for {
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
 		if err != nil {
-			log.Printf("OKX connection error: %v", err)
-			time.Sleep(5 * time.Second)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("OKX %s connection error: %v", market, err)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
-		log.Printf("Connected to OKX futures WebSocket")
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor(source).StaleTimeout)
+
+		log.Printf("Connected to OKX %s WebSocket", market)
 
 		// Subscribe to both trades and orderbooks for all symbols
 		var subscribeArgs []struct {
@@ -66,10 +188,10 @@ func ConnectOKXFutures(symbols []string, priceChan chan<- PriceData, orderbookCh
 			InstID  string `json:"instId"`
 		}
 
-		for _, symbol := range symbols {
-			// Convert symbol format (BTCUSDT -> BTC-USDT-SWAP for perpetual futures)
-			okxSymbol := convertToOKXSymbol(symbol)
-			
+		for _, instrument := range instruments {
+			// Native ID (BTCUSDT -> BTC-USDT-SWAP or BTC-USDT)
+			okxSymbol := instrument.NativeID
+
 			// Subscribe to trades
 			subscribeArgs = append(subscribeArgs, struct {
 				Channel string `json:"channel"`
@@ -79,12 +201,12 @@ func ConnectOKXFutures(symbols []string, priceChan chan<- PriceData, orderbookCh
 				InstID:  okxSymbol,
 			})
 			
-			// Subscribe to orderbooks (books5 for top 5 levels)
+			// Subscribe to orderbooks
 			subscribeArgs = append(subscribeArgs, struct {
 				Channel string `json:"channel"`
 				InstID  string `json:"instId"`
 			}{
-				Channel: "books5",
+				Channel: bookChannel,
 				InstID:  okxSymbol,
 			})
 		}
@@ -94,28 +216,76 @@ func ConnectOKXFutures(symbols []string, priceChan chan<- PriceData, orderbookCh
 			Args: subscribeArgs,
 		}
 
-		err = conn.WriteJSON(subscribeMsg)
+		err = sess.WriteJSON(subscribeMsg)
 		if err != nil {
-			log.Printf("OKX subscription error: %v", err)
+			log.Printf("OKX %s subscription error: %v", market, err)
+			sup.Disconnected(err)
+			stopClose()
 			conn.Close()
-			time.Sleep(5 * time.Second)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
+		sup.Subscribed()
+
+		// OKX drops connections idle for 30s; it answers a text "ping" with "pong"
+		stopPing := sess.KeepAlive(25*time.Second, func() error {
+			return sess.WriteMessage(websocket.TextMessage, []byte("ping"))
+		})
+
+		// Last applied seqId per books instrument; missing until the snapshot
+		seqIDs := make(map[string]int64)
+
+		// resync drops the book and resubscribes so OKX sends a new snapshot
+		resync := func(instID, reason string) {
+			log.Printf("OKX %s book resync: %s", instID, reason)
+			sup.Resync(instID + ": " + reason)
+			books[instID].Reset()
+			delete(seqIDs, instID)
+
+			request := OKXSubscribeMessage{Op: "unsubscribe"}
+			request.Args = append(request.Args, struct {
+				Channel string `json:"channel"`
+				InstID  string `json:"instId"`
+			}{Channel: bookChannel, InstID: instID})
+			err := sess.WriteJSON(request)
+			if err == nil {
+				request.Op = "subscribe"
+				err = sess.WriteJSON(request)
+			}
+			if err != nil {
+				log.Printf("OKX resubscribe error: %v", err)
+				conn.Close()
+			}
+		}
+
 		for {
-			var message json.RawMessage
-			err := conn.ReadJSON(&message)
+			// Read raw frames since heartbeat replies are the bare text "pong"
+			message, err := sess.ReadMessage()
 			if err != nil {
-				log.Printf("OKX read error: %v", err)
+				if ctx.Err() == nil {
+					log.Printf("OKX %s read error: %v", market, err)
+				}
+				sup.Disconnected(err)
 				conn.Close()
 				break
 			}
 
+			receivedAt := time.Now()
+
+			// Ping replies don't count as data for the stale watchdog
+			if string(message) == "pong" {
+				continue
+			}
+			sess.Message()
+
 			// Check if it's a trade message
 			var tradeMsg OKXFuturesTrade
 			if err := json.Unmarshal(message, &tradeMsg); err == nil && tradeMsg.Arg.Channel == "trades" && len(tradeMsg.Data) > 0 {
 				for _, trade := range tradeMsg.Data {
-					price, err := strconv.ParseFloat(trade.Price, 64)
+					price, err := ParseDecimal(trade.Price)
 					if err != nil {
 						continue
 					}
@@ -123,21 +293,18 @@ func ConnectOKXFutures(symbols []string, priceChan chan<- PriceData, orderbookCh
 					// Convert timestamp from string to int64
 					timestamp, err := strconv.ParseInt(trade.Timestamp, 10, 64)
 					if err != nil {
-						timestamp = time.Now().UnixMilli()
+						timestamp = 0
 					}
 
 					// Convert OKX symbol back to standard format
-					standardSymbol := convertFromOKXSymbol(trade.InstID)
-
-					tradeData := TradeData{
-						Symbol:    standardSymbol,
-						Source:    "okx_futures",
-						Price:     price,
-						Quantity:  trade.Size,
-						Side:      trade.Side, // OKX already provides "buy" or "sell"
-						Timestamp: timestamp,
+					instrument, ok := Instruments.Lookup(source, trade.InstID)
+					if !ok {
+						continue
 					}
 
+					// OKX already provides "buy" or "sell"
+					tradeData := newTradeData(instrument, price, trade.Size, trade.Side, timestamp, receivedAt)
+
 					tradeChan <- tradeData
 				}
 				continue
@@ -145,34 +312,59 @@ func ConnectOKXFutures(symbols []string, priceChan chan<- PriceData, orderbookCh
 
 			// Check if it's an orderbook message
 			var orderbookMsg OKXFuturesOrderbook
-			if err := json.Unmarshal(message, &orderbookMsg); err == nil && orderbookMsg.Arg.Channel == "books5" && len(orderbookMsg.Data) > 0 {
+			if err := json.Unmarshal(message, &orderbookMsg); err == nil && orderbookMsg.Arg.Channel == bookChannel && len(orderbookMsg.Data) > 0 {
 				for _, book := range orderbookMsg.Data {
-					if len(book.Bids) == 0 || len(book.Asks) == 0 {
+					if book.InstID == "" {
+						book.InstID = orderbookMsg.Arg.InstID
+					}
+					bids, err1 := parseStringLevels(book.Bids)
+					asks, err2 := parseStringLevels(book.Asks)
+					if err1 != nil || err2 != nil {
 						continue
 					}
 
-					// Parse best bid and ask
-					bestBid, err1 := strconv.ParseFloat(book.Bids[0][0], 64)
-					bestAsk, err2 := strconv.ParseFloat(book.Asks[0][0], 64)
-					if err1 != nil || err2 != nil {
+					localBook, exists := books[book.InstID]
+					if !exists {
+						localBook = NewOrderBook()
+						books[book.InstID] = localBook
+					}
+					if bookChannel == "books5" || orderbookMsg.Action == "snapshot" {
+						// books5 pushes the full top five levels every time
+						localBook.ApplySnapshot(bids, asks)
+						seqIDs[book.InstID] = book.SeqID
+					} else {
+						lastSeqID, synced := seqIDs[book.InstID]
+						if !synced {
+							continue
+						}
+						if book.PrevSeqID != lastSeqID {
+							resync(book.InstID, fmt.Sprintf("sequence gap: expected %d, got %d", lastSeqID, book.PrevSeqID))
+							continue
+						}
+						localBook.ApplyDelta(bids, asks)
+						seqIDs[book.InstID] = book.SeqID
+					}
+
+					if localBook.Crossed() {
+						resync(book.InstID, "crossed book")
 						continue
 					}
 
 					// Convert timestamp from string to int64
 					timestamp, err := strconv.ParseInt(book.Timestamp, 10, 64)
 					if err != nil {
-						timestamp = time.Now().UnixMilli()
+						timestamp = 0
 					}
 
 					// Convert OKX symbol back to standard format
-					standardSymbol := convertFromOKXSymbol(book.InstID)
-
-					orderbookData := OrderbookData{
-						Symbol:    standardSymbol,
-						Source:    "okx_futures",
-						BestBid:   bestBid,
-						BestAsk:   bestAsk,
-						Timestamp: timestamp,
+					instrument, ok := Instruments.Lookup(source, book.InstID)
+					if !ok {
+						continue
+					}
+
+					orderbookData, ok := localBook.OrderbookData(instrument, depth, timestamp, receivedAt)
+					if !ok {
+						continue
 					}
 
 					orderbookChan <- orderbookData
@@ -181,59 +373,10 @@ func ConnectOKXFutures(symbols []string, priceChan chan<- PriceData, orderbookCh
 			}
 		}
 
-		time.Sleep(2 * time.Second)
-	}
-}
-
-// convertToOKXSymbol converts standard symbol format to OKX format
-// BTCUSDT -> BTC-USDT-SWAP (for perpetual futures)
-func convertToOKXSymbol(symbol string) string {
-	// Handle common symbols
-	switch symbol {
-	case "BTCUSDT":
-		return "BTC-USDT-SWAP"
-	case "ETHUSDT":
-		return "ETH-USDT-SWAP"
-	case "ADAUSDT":
-		return "ADA-USDT-SWAP"
-	case "SOLUSDT":
-		return "SOL-USDT-SWAP"
-	case "DOTUSDT":
-		return "DOT-USDT-SWAP"
-	case "LINKUSDT":
-		return "LINK-USDT-SWAP"
-	case "AVAXUSDT":
-		return "AVAX-USDT-SWAP"
-	case "MATICUSDT":
-		return "MATIC-USDT-SWAP"
-	case "UNIUSDT":
-		return "UNI-USDT-SWAP"
-	case "LTCUSDT":
-		return "LTC-USDT-SWAP"
-	case "BCHUSDT":
-		return "BCH-USDT-SWAP"
-	case "XRPUSDT":
-		return "XRP-USDT-SWAP"
-	default:
-		// Generic conversion for other symbols
-		if strings.HasSuffix(symbol, "USDT") {
-			base := strings.TrimSuffix(symbol, "USDT")
-			return fmt.Sprintf("%s-USDT-SWAP", base)
+		stopPing()
+		stopClose()
+		if !sup.Backoff(ctx) {
+			return
 		}
-		return symbol + "-SWAP"
 	}
 }
-
-// convertFromOKXSymbol converts OKX symbol format back to standard format
-// BTC-USDT-SWAP -> BTCUSDT
-func convertFromOKXSymbol(okxSymbol string) string {
-	// Remove -SWAP suffix and convert to standard format
-	if strings.HasSuffix(okxSymbol, "-SWAP") {
-		withoutSwap := strings.TrimSuffix(okxSymbol, "-SWAP")
-		parts := strings.Split(withoutSwap, "-")
-		if len(parts) == 2 {
-			return parts[0] + parts[1]
-		}
-	}
-	return okxSymbol
-}
\ No newline at end of file
@@ -0,0 +1,318 @@
+package exchanges
+
+import (
+	"strconv"
+	"time"
+)
+
+// PriceLevel is one aggregated price level of an order book
+type PriceLevel struct {
+	Price Decimal `json:"price"`
+	Size  float64 `json:"size"`
+}
+
+// BookSide selects the bid or ask side of an order book
+type BookSide int
+
+const (
+	Bid BookSide = iota
+	Ask
+)
+
+// ChecksumFunc computes a venue specific checksum over the book
+type ChecksumFunc func(book *OrderBook) uint32
+
+// OrderBook is a local L2 book shared by all depth capable connectors.
+// Each side is kept in a skip list ordered best price first, so updates are
+// O(log n) and top of book and depth reads don't need sorting. Levels are
+// keyed by their fixed-point price, so an update always finds the level it
+// refers to regardless of how the venue formats the number. An OrderBook
+// is not safe for concurrent use; each connector owns its books.
+type OrderBook struct {
+	bids     *levelList
+	asks     *levelList
+	checksum ChecksumFunc
+}
+
+// NewOrderBook returns an empty order book
+func NewOrderBook() *OrderBook {
+	return &OrderBook{
+		bids: newLevelList(func(a, b Decimal) bool { return b.Less(a) }),
+		asks: newLevelList(func(a, b Decimal) bool { return a.Less(b) }),
+	}
+}
+
+// Reset removes every level
+func (b *OrderBook) Reset() {
+	b.bids.clear()
+	b.asks.clear()
+}
+
+// ApplySnapshot replaces the book contents
+func (b *OrderBook) ApplySnapshot(bids, asks []PriceLevel) {
+	b.Reset()
+	b.ApplyDelta(bids, asks)
+}
+
+// ApplyDelta applies changed levels; a zero size removes the level
+func (b *OrderBook) ApplyDelta(bids, asks []PriceLevel) {
+	for _, level := range bids {
+		b.Update(Bid, level.Price, level.Size)
+	}
+	for _, level := range asks {
+		b.Update(Ask, level.Price, level.Size)
+	}
+}
+
+// Update sets the size at a price level, removing it when size is zero
+func (b *OrderBook) Update(side BookSide, price Decimal, size float64) {
+	levels := b.side(side)
+	if size == 0 {
+		levels.remove(price)
+		return
+	}
+	levels.set(price, size)
+}
+
+// BestBid returns the highest bid
+func (b *OrderBook) BestBid() (PriceLevel, bool) {
+	return b.bids.first()
+}
+
+// BestAsk returns the lowest ask
+func (b *OrderBook) BestAsk() (PriceLevel, bool) {
+	return b.asks.first()
+}
+
+// Crossed reports whether the best bid is at or above the best ask, which
+// means the local book missed an update
+func (b *OrderBook) Crossed() bool {
+	bid, okBid := b.BestBid()
+	ask, okAsk := b.BestAsk()
+	return okBid && okAsk && !bid.Price.Less(ask.Price)
+}
+
+// Depth returns up to n levels per side, best price first. n <= 0 returns
+// the whole book.
+func (b *OrderBook) Depth(n int) (bids, asks []PriceLevel) {
+	return b.bids.levels(n), b.asks.levels(n)
+}
+
+// Levels returns up to n levels of one side, best price first
+func (b *OrderBook) Levels(side BookSide, n int) []PriceLevel {
+	return b.side(side).levels(n)
+}
+
+// Len returns the number of levels on a side
+func (b *OrderBook) Len(side BookSide) int {
+	return b.side(side).length
+}
+
+// Truncate drops every level beyond the best n on each side, for venues that
+// stop updating levels once they fall out of the subscribed depth
+func (b *OrderBook) Truncate(n int) {
+	for _, levels := range []*levelList{b.bids, b.asks} {
+		if levels.length <= n {
+			continue
+		}
+		for _, level := range levels.levels(levels.length)[n:] {
+			levels.remove(level.Price)
+		}
+	}
+}
+
+// SetChecksum installs the venue checksum used by VerifyChecksum
+func (b *OrderBook) SetChecksum(fn ChecksumFunc) {
+	b.checksum = fn
+}
+
+// VerifyChecksum compares the book against a venue provided checksum. Books
+// without a checksum function always verify.
+func (b *OrderBook) VerifyChecksum(expected uint32) bool {
+	if b.checksum == nil {
+		return true
+	}
+	return b.checksum(b) == expected
+}
+
+// OrderbookData builds the event for the instrument's book with normalized
+// prices and sizes, including up to depth levels per side when depth > 0.
+// ok is false while either side of the book is empty.
+func (b *OrderBook) OrderbookData(instrument VenueInstrument, depth int, timestamp int64, receivedAt time.Time) (data OrderbookData, ok bool) {
+	bestBid, okBid := b.BestBid()
+	bestAsk, okAsk := b.BestAsk()
+	if !okBid || !okAsk {
+		return OrderbookData{}, false
+	}
+
+	data = OrderbookData{
+		Symbol:      instrument.Symbol,
+		Source:      instrument.Venue,
+		BestBid:     instrument.NormalizePrice(bestBid.Price),
+		BestAsk:     instrument.NormalizePrice(bestAsk.Price),
+		BestBidSize: instrument.NormalizeSize(bestBid.Size, bestBid.Price),
+		BestAskSize: instrument.NormalizeSize(bestAsk.Size, bestAsk.Price),
+		Timestamp:   timestamp,
+		ReceivedAt:  receivedAt,
+	}
+	if depth > 0 {
+		bids, asks := b.Depth(depth)
+		data.Bids = instrument.NormalizeLevels(bids)
+		data.Asks = instrument.NormalizeLevels(asks)
+	}
+	return data, true
+}
+
+func (b *OrderBook) side(side BookSide) *levelList {
+	if side == Bid {
+		return b.bids
+	}
+	return b.asks
+}
+
+// parseStringLevels converts the common [["price","size",...], ...] wire
+// format into price levels
+func parseStringLevels(raw [][]string) ([]PriceLevel, error) {
+	levels := make([]PriceLevel, 0, len(raw))
+	for _, entry := range raw {
+		if len(entry) < 2 {
+			continue
+		}
+		price, err := ParseDecimal(entry[0])
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseFloat(entry[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, PriceLevel{Price: price, Size: size})
+	}
+	return levels, nil
+}
+
+const maxSkipLevel = 24
+
+type levelNode struct {
+	level PriceLevel
+	next  []*levelNode
+}
+
+// levelList is a skip list of price levels ordered by better()
+type levelList struct {
+	head   *levelNode
+	height int
+	length int
+	better func(a, b Decimal) bool
+	seed   uint64
+	update [maxSkipLevel]*levelNode
+}
+
+func newLevelList(better func(a, b Decimal) bool) *levelList {
+	return &levelList{
+		head:   &levelNode{next: make([]*levelNode, maxSkipLevel)},
+		height: 1,
+		better: better,
+		seed:   0x9E3779B97F4A7C15,
+	}
+}
+
+func (l *levelList) clear() {
+	for i := range l.head.next {
+		l.head.next[i] = nil
+	}
+	l.height = 1
+	l.length = 0
+}
+
+// randomHeight draws a node height with p = 1/4 using xorshift, avoiding the
+// global rand lock on the hot path
+func (l *levelList) randomHeight() int {
+	l.seed ^= l.seed << 13
+	l.seed ^= l.seed >> 7
+	l.seed ^= l.seed << 17
+
+	height := 1
+	for r := l.seed; height < maxSkipLevel && r&3 == 0; r >>= 2 {
+		height++
+	}
+	return height
+}
+
+// findPath fills l.update with the last node before price on every level and
+// returns the node at price if present
+func (l *levelList) findPath(price Decimal) *levelNode {
+	node := l.head
+	for i := l.height - 1; i >= 0; i-- {
+		for node.next[i] != nil && l.better(node.next[i].level.Price, price) {
+			node = node.next[i]
+		}
+		l.update[i] = node
+	}
+
+	candidate := node.next[0]
+	if candidate != nil && candidate.level.Price.Equal(price) {
+		return candidate
+	}
+	return nil
+}
+
+func (l *levelList) set(price Decimal, size float64) {
+	if existing := l.findPath(price); existing != nil {
+		existing.level.Size = size
+		return
+	}
+
+	height := l.randomHeight()
+	if height > l.height {
+		for i := l.height; i < height; i++ {
+			l.update[i] = l.head
+		}
+		l.height = height
+	}
+
+	node := &levelNode{
+		level: PriceLevel{Price: price, Size: size},
+		next:  make([]*levelNode, height),
+	}
+	for i := 0; i < height; i++ {
+		node.next[i] = l.update[i].next[i]
+		l.update[i].next[i] = node
+	}
+	l.length++
+}
+
+func (l *levelList) remove(price Decimal) bool {
+	node := l.findPath(price)
+	if node == nil {
+		return false
+	}
+
+	for i := 0; i < len(node.next); i++ {
+		l.update[i].next[i] = node.next[i]
+	}
+	for l.height > 1 && l.head.next[l.height-1] == nil {
+		l.height--
+	}
+	l.length--
+	return true
+}
+
+func (l *levelList) first() (PriceLevel, bool) {
+	node := l.head.next[0]
+	if node == nil {
+		return PriceLevel{}, false
+	}
+	return node.level, true
+}
+
+func (l *levelList) levels(n int) []PriceLevel {
+	if n <= 0 || n > l.length {
+		n = l.length
+	}
+	levels := make([]PriceLevel, 0, n)
+	for node := l.head.next[0]; node != nil && len(levels) < n; node = node.next[0] {
+		levels = append(levels, node.level)
+	}
+	return levels
+}
@@ -0,0 +1,248 @@
+package exchanges
+
+import (
+	"fmt"
+	"testing"
+)
+
+func mustDecimal(tb testing.TB, s string) Decimal {
+	tb.Helper()
+	d, err := ParseDecimal(s)
+	if err != nil {
+		tb.Fatalf("ParseDecimal(%q): %v", s, err)
+	}
+	return d
+}
+
+func testLevels(tb testing.TB, rows ...string) []PriceLevel {
+	tb.Helper()
+	var out []PriceLevel
+	for i := 0; i < len(rows); i += 2 {
+		var size float64
+		if _, err := fmt.Sscan(rows[i+1], &size); err != nil {
+			tb.Fatalf("size %q: %v", rows[i+1], err)
+		}
+		out = append(out, PriceLevel{Price: mustDecimal(tb, rows[i]), Size: size})
+	}
+	return out
+}
+
+func levelPrices(levels []PriceLevel) []string {
+	out := make([]string, len(levels))
+	for i, level := range levels {
+		out[i] = level.Price.String()
+	}
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOrderBookDepthOrdering(t *testing.T) {
+	book := NewOrderBook()
+	book.ApplySnapshot(
+		testLevels(t, "99.5", "1", "100", "2", "98", "3", "99.9", "4"),
+		testLevels(t, "101", "1", "100.5", "2", "103", "3", "100.1", "4"),
+	)
+
+	bids, asks := book.Depth(0)
+	if got, want := levelPrices(bids), []string{"100", "99.9", "99.5", "98"}; !equalStrings(got, want) {
+		t.Errorf("bids = %v, want %v", got, want)
+	}
+	if got, want := levelPrices(asks), []string{"100.1", "100.5", "101", "103"}; !equalStrings(got, want) {
+		t.Errorf("asks = %v, want %v", got, want)
+	}
+
+	bids, asks = book.Depth(2)
+	if got, want := levelPrices(bids), []string{"100", "99.9"}; !equalStrings(got, want) {
+		t.Errorf("depth 2 bids = %v, want %v", got, want)
+	}
+	if got, want := levelPrices(asks), []string{"100.1", "100.5"}; !equalStrings(got, want) {
+		t.Errorf("depth 2 asks = %v, want %v", got, want)
+	}
+
+	if bid, ok := book.BestBid(); !ok || bid.Price.String() != "100" || bid.Size != 2 {
+		t.Errorf("BestBid = %v %v", bid, ok)
+	}
+	if ask, ok := book.BestAsk(); !ok || ask.Price.String() != "100.1" || ask.Size != 4 {
+		t.Errorf("BestAsk = %v %v", ask, ok)
+	}
+}
+
+func TestOrderBookUpdateAndRemove(t *testing.T) {
+	book := NewOrderBook()
+	book.ApplySnapshot(testLevels(t, "100", "1", "99", "2"), testLevels(t, "101", "1", "102", "2"))
+
+	// A differently formatted price must hit the same level
+	book.ApplyDelta(testLevels(t, "100.00", "5"), nil)
+	if book.Len(Bid) != 2 {
+		t.Fatalf("bid levels = %d, want 2", book.Len(Bid))
+	}
+	if bid, _ := book.BestBid(); bid.Size != 5 {
+		t.Errorf("best bid size = %v, want 5", bid.Size)
+	}
+
+	// Zero size removes, including levels that don't exist
+	book.ApplyDelta(testLevels(t, "100", "0", "50", "0"), testLevels(t, "101", "0"))
+	if got, want := levelPrices(book.Levels(Bid, 0)), []string{"99"}; !equalStrings(got, want) {
+		t.Errorf("bids = %v, want %v", got, want)
+	}
+	if got, want := levelPrices(book.Levels(Ask, 0)), []string{"102"}; !equalStrings(got, want) {
+		t.Errorf("asks = %v, want %v", got, want)
+	}
+
+	// A snapshot replaces everything
+	book.ApplySnapshot(testLevels(t, "10", "1"), testLevels(t, "11", "1"))
+	if book.Len(Bid) != 1 || book.Len(Ask) != 1 {
+		t.Errorf("levels after snapshot = %d/%d, want 1/1", book.Len(Bid), book.Len(Ask))
+	}
+
+	book.Reset()
+	if _, ok := book.BestBid(); ok {
+		t.Error("BestBid on an empty book")
+	}
+}
+
+func TestOrderBookCrossed(t *testing.T) {
+	tests := []struct {
+		name     string
+		bid, ask string
+		crossed  bool
+	}{
+		{"normal", "100", "100.5", false},
+		{"locked", "100", "100", true},
+		{"crossed", "101", "100", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			book := NewOrderBook()
+			book.ApplySnapshot(testLevels(t, test.bid, "1"), testLevels(t, test.ask, "1"))
+			if got := book.Crossed(); got != test.crossed {
+				t.Errorf("Crossed = %v, want %v", got, test.crossed)
+			}
+		})
+	}
+
+	book := NewOrderBook()
+	book.ApplyDelta(testLevels(t, "100", "1"), nil)
+	if book.Crossed() {
+		t.Error("one sided book reported crossed")
+	}
+}
+
+func TestOrderBookTruncate(t *testing.T) {
+	book := NewOrderBook()
+	book.ApplySnapshot(
+		testLevels(t, "100", "1", "99", "1", "98", "1", "97", "1"),
+		testLevels(t, "101", "1", "102", "1"),
+	)
+
+	book.Truncate(2)
+	if got, want := levelPrices(book.Levels(Bid, 0)), []string{"100", "99"}; !equalStrings(got, want) {
+		t.Errorf("bids = %v, want %v", got, want)
+	}
+	if got, want := levelPrices(book.Levels(Ask, 0)), []string{"101", "102"}; !equalStrings(got, want) {
+		t.Errorf("asks = %v, want %v", got, want)
+	}
+
+	// The truncated levels are gone, not hidden
+	book.Update(Bid, mustDecimal(t, "100"), 0)
+	book.Update(Bid, mustDecimal(t, "99"), 0)
+	if book.Len(Bid) != 0 {
+		t.Errorf("bid levels = %d, want 0", book.Len(Bid))
+	}
+
+	book.Truncate(0)
+	if book.Len(Ask) != 0 {
+		t.Errorf("ask levels after Truncate(0) = %d, want 0", book.Len(Ask))
+	}
+}
+
+func TestOrderBookChecksum(t *testing.T) {
+	book := NewOrderBook()
+	if !book.VerifyChecksum(42) {
+		t.Error("book without checksum function failed to verify")
+	}
+
+	book.SetChecksum(func(b *OrderBook) uint32 { return uint32(b.Len(Bid) + b.Len(Ask)) })
+	book.ApplySnapshot(testLevels(t, "1", "1"), testLevels(t, "2", "1", "3", "1"))
+	if !book.VerifyChecksum(3) || book.VerifyChecksum(2) {
+		t.Error("VerifyChecksum did not use the installed function")
+	}
+}
+
+// benchmarkLevels builds n levels per side around 30000 with a 0.1 tick, the
+// shape of a BTC perp book
+func benchmarkLevels(n int) (bids, asks []PriceLevel) {
+	for i := 0; i < n; i++ {
+		bid, _ := NewDecimal(300000-int64(i), -1)
+		ask, _ := NewDecimal(300001+int64(i), -1)
+		bids = append(bids, PriceLevel{Price: bid, Size: float64(i%7 + 1)})
+		asks = append(asks, PriceLevel{Price: ask, Size: float64(i%5 + 1)})
+	}
+	return bids, asks
+}
+
+// Local book sizes across venues, from OKX books5 up to the 400 levels of
+// OKX books
+var benchmarkDepths = []int{5, 25, 100, 200, 400}
+
+func BenchmarkOrderBookUpdate(b *testing.B) {
+	for _, depth := range benchmarkDepths {
+		b.Run(fmt.Sprintf("levels=%d", depth), func(b *testing.B) {
+			bids, asks := benchmarkLevels(depth)
+			book := NewOrderBook()
+			book.ApplySnapshot(bids, asks)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Change a level, remove it and put it back, like a busy
+				// incremental feed touching the top of the book
+				level := bids[i%len(bids)]
+				book.Update(Bid, level.Price, level.Size+1)
+				book.Update(Bid, level.Price, 0)
+				book.Update(Bid, level.Price, level.Size)
+				level = asks[i%len(asks)]
+				book.Update(Ask, level.Price, 0)
+				book.Update(Ask, level.Price, level.Size)
+			}
+		})
+	}
+}
+
+func BenchmarkOrderBookSnapshot(b *testing.B) {
+	for _, depth := range benchmarkDepths {
+		b.Run(fmt.Sprintf("levels=%d", depth), func(b *testing.B) {
+			bids, asks := benchmarkLevels(depth)
+			book := NewOrderBook()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				book.ApplySnapshot(bids, asks)
+			}
+		})
+	}
+}
+
+func BenchmarkOrderBookDepth(b *testing.B) {
+	bids, asks := benchmarkLevels(400)
+	book := NewOrderBook()
+	book.ApplySnapshot(bids, asks)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		book.Depth(10)
+	}
+}
@@ -1,10 +1,9 @@
 package exchanges
 
 import (
+	"context"
 	"encoding/json"
 	"log"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -49,24 +48,92 @@ type ParadexMarketSummaryEvent struct {
 	Params  struct {
 		Channel string `json:"channel"`
 		Data    struct {
-			Symbol string `json:"symbol"`
-			Bid    string `json:"bid"`
-			Ask    string `json:"ask"`
+			Symbol    string `json:"symbol"`
+			Bid       string `json:"bid"`
+			Ask       string `json:"ask"`
+			CreatedAt int64  `json:"created_at"`
 		} `json:"data"`
 	} `json:"params"`
 }
 
-func ConnectParadexFutures(symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
-	wsURL := "wss://ws.api.prod.paradex.trade/v1"
+func init() {
+	Register(NewConnector("paradex_futures", MarketFutures, nil, ConnectParadexFutures))
+
+	// Paradex perps are USD quoted and USDC settled (BTC-USD-PERP)
+	Instruments.RegisterVenue("paradex_futures", VenueSpec{
+		Quote:      "USD",
+		Settlement: "USDC",
+		Contract:   ContractPerpetual,
+		Format: func(base, quote string) string {
+			return base + "-" + quote + "-PERP"
+		},
+	})
+
+	RegisterMetadata("paradex_futures", paradexMetadata)
+}
+
+type paradexMarkets struct {
+	Results []struct {
+		Symbol             string `json:"symbol"`
+		PriceTickSize      string `json:"price_tick_size"`
+		OrderSizeIncrement string `json:"order_size_increment"`
+	} `json:"results"`
+}
+
+// paradexMetadata loads market specs; Paradex sizes are in the base asset
+func paradexMetadata(ctx context.Context, nativeIDs []string) ([]InstrumentSpec, error) {
+	var markets paradexMarkets
+	if err := getJSON(ctx, "https://api.prod.paradex.trade/v1/markets", &markets); err != nil {
+		return nil, err
+	}
+
+	wanted := wantedIDs(nativeIDs)
+	var specs []InstrumentSpec
+	for _, market := range markets.Results {
+		if !wanted[market.Symbol] {
+			continue
+		}
+		lotSize := parseFloatOrZero(market.OrderSizeIncrement)
+		specs = append(specs, InstrumentSpec{
+			NativeID:     market.Symbol,
+			TickSize:     parseFloatOrZero(market.PriceTickSize),
+			LotSize:      lotSize,
+			MinSize:      lotSize,
+			ContractSize: 1,
+		})
+	}
+	return specs, nil
+}
+
+func ConnectParadexFutures(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+	wsURL := endpoint("paradex_futures", "wss://ws.api.prod.paradex.trade/v1")
+
+	// markets_summary streams every market, so only resolve the watched ones
+	resolveSymbols("paradex_futures", symbols)
+
+	sup := NewSupervisor("paradex_futures")
+	defer sup.Stopped()
 
 	for {
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		sup.Connecting()
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			log.Printf("Paradex connection error: %v", err)
-			time.Sleep(5 * time.Second)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 
+		// Close the socket on shutdown so the blocking read returns
+		stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+		sup.Connected()
+		sess := newSession(conn, sup, optionsFor("paradex_futures").StaleTimeout)
+
 		log.Printf("Connected to Paradex futures WebSocket")
 
 		// Subscribe to markets_summary channel (provides bid/ask for all markets)
@@ -80,16 +147,24 @@ func ConnectParadexFutures(symbols []string, priceChan chan<- PriceData, orderbo
 			"id": 1,
 		}
 
-		if err := conn.WriteJSON(subscribeReq); err != nil {
+		if err := sess.WriteJSON(subscribeReq); err != nil {
 		}
 
+		sup.Subscribed()
+
 		// Read messages
 		for {
-			_, message, err := conn.ReadMessage()
+			message, err := sess.ReadMessage()
 			if err != nil {
-				log.Printf("Paradex read error: %v", err)
+				if ctx.Err() == nil {
+					log.Printf("Paradex read error: %v", err)
+				}
+				sup.Disconnected(err)
 				break
 			}
+			sess.Message()
+
+			receivedAt := time.Now()
 
 			// Try to parse as subscription response first
 			var subResponse ParadexWSResponse
@@ -102,14 +177,14 @@ func ConnectParadexFutures(symbols []string, priceChan chan<- PriceData, orderbo
 			if err := json.Unmarshal(message, &marketEvent); err == nil && 
 			   marketEvent.Method == "subscription" && marketEvent.Params.Channel == "markets_summary" {
 				
-				symbol := convertFromParadexSymbol(marketEvent.Params.Data.Symbol)
-				if symbol == "" {
-					continue // Skip unsupported symbols
+				instrument, ok := Instruments.Lookup("paradex_futures", marketEvent.Params.Data.Symbol)
+				if !ok {
+					continue // Skip markets we are not watching
 				}
 
 				// Parse bid and ask prices
-				bidPrice, err1 := strconv.ParseFloat(marketEvent.Params.Data.Bid, 64)
-				askPrice, err2 := strconv.ParseFloat(marketEvent.Params.Data.Ask, 64)
+				bidPrice, err1 := ParseDecimal(marketEvent.Params.Data.Bid)
+				askPrice, err2 := ParseDecimal(marketEvent.Params.Data.Ask)
 				
 				if err1 != nil || err2 != nil {
 					continue
@@ -117,56 +192,24 @@ func ConnectParadexFutures(symbols []string, priceChan chan<- PriceData, orderbo
 
 				// Send orderbook data
 				orderbookChan <- OrderbookData{
-					Symbol:    symbol,
-					Source:    "paradex_futures",
-					BestBid:   bidPrice,
-					BestAsk:   askPrice,
-					Timestamp: time.Now().UnixMilli(),
+					Symbol:     instrument.Symbol,
+					Source:     "paradex_futures",
+					BestBid:    instrument.NormalizePrice(bidPrice),
+					BestAsk:    instrument.NormalizePrice(askPrice),
+					Timestamp:  marketEvent.Params.Data.CreatedAt,
+					ReceivedAt: receivedAt,
 				}
 			}
 		}
 
+		stopClose()
 		conn.Close()
-		log.Printf("Paradex connection closed, reconnecting in 5 seconds...")
-		time.Sleep(5 * time.Second)
-	}
-}
-
-// Convert standard symbol format to Paradex format
-// BTCUSDT -> BTC-USD-PERP
-// ETHUSDT -> ETH-USD-PERP
-func convertToParadexSymbol(symbol string) string {
-	symbol = strings.ToUpper(symbol)
-
-	// Map of supported symbols
-	symbolMap := map[string]string{
-		"BTCUSDT": "BTC-USD-PERP",
-		"ETHUSDT": "ETH-USD-PERP",
-		"XRPUSDT": "XRP-USD-PERP",
-		"SOLUSDT": "SOL-USD-PERP",
-	}
-
-	if paradexSymbol, exists := symbolMap[symbol]; exists {
-		return paradexSymbol
-	}
-
-	return ""
-}
-
-// Convert Paradex symbol format back to standard format
-// BTC-USD-PERP -> BTCUSDT
-func convertFromParadexSymbol(paradexSymbol string) string {
-	// Map from Paradex format back to standard
-	symbolMap := map[string]string{
-		"BTC-USD-PERP": "BTCUSDT",
-		"ETH-USD-PERP": "ETHUSDT",
-		"XRP-USD-PERP": "XRPUSDT",
-		"SOL-USD-PERP": "SOLUSDT",
-	}
-
-	if symbol, exists := symbolMap[paradexSymbol]; exists {
-		return symbol
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Paradex connection closed, reconnecting...")
+		if !sup.Backoff(ctx) {
+			return
+		}
 	}
-
-	return ""
 }
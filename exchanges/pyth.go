@@ -2,13 +2,14 @@ package exchanges
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -37,18 +38,31 @@ var pythPriceFeedIDs = map[string]string{
 	"BTCUSDT": "e62df6c8b4a85fe1a67db44dc12de5db330f7ac66b72dc658afedf0f4a415b43", // BTC/USD price feed ID
 }
 
-// ParsePythPrice converts Pyth price string and exponent to float64
-func ParsePythPrice(priceStr string, expo int) (float64, error) {
+// pythSymbols returns the symbols that have a Pyth price feed ID
+func pythSymbols() []string {
+	symbols := make([]string, 0, len(pythPriceFeedIDs))
+	for symbol := range pythPriceFeedIDs {
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// ParsePythPrice converts a Pyth price mantissa and exponent into an exact
+// decimal, e.g. ("6512345678901", -8) -> 65123.45678901
+func ParsePythPrice(priceStr string, expo int) (Decimal, error) {
 	priceInt, err := strconv.ParseInt(priceStr, 10, 64)
 	if err != nil {
-		return 0, err
+		return Decimal{}, err
 	}
-	realPrice := float64(priceInt) * math.Pow10(expo)
-	return realPrice, nil
+	return NewDecimal(priceInt, expo)
+}
+
+func init() {
+	Register(NewConnector("pyth", MarketOracle, pythSymbols(), ConnectPythPrices))
 }
 
 // ConnectPythPrices connects to Pyth Network SSE endpoint for price feeds
-func ConnectPythPrices(symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
+func ConnectPythPrices(ctx context.Context, symbols []string, priceChan chan<- PriceData, orderbookChan chan<- OrderbookData, tradeChan chan<- TradeData) {
 	// Filter symbols to only those we have price feed IDs for
 	var validSymbols []string
 	var priceFeedIDs []string
@@ -71,21 +85,70 @@ func ConnectPythPrices(symbols []string, priceChan chan<- PriceData, orderbookCh
 		idParams = append(idParams, fmt.Sprintf("ids[]=%s", id))
 	}
 	idsParam := strings.Join(idParams, "&")
-	sseURL := fmt.Sprintf("https://hermes.pyth.network/v2/updates/price/stream?%s", idsParam)
+	sseURL := fmt.Sprintf("%s/v2/updates/price/stream?%s", endpoint("pyth", "https://hermes.pyth.network"), idsParam)
 	
-	for {		
-		resp, err := http.Get(sseURL)
+	sup := NewSupervisor("pyth")
+	defer sup.Stopped()
+
+	staleAfter := optionsFor("pyth").StaleTimeout
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleTimeout
+	}
+
+	for {
+		sup.Connecting()
+
+		// The request is bound to streamCtx so cancelling it (on shutdown or
+		// when the stale watchdog fires) also closes the stream body
+		streamCtx, cancelStream := context.WithCancel(ctx)
+		req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, sseURL, nil)
+		if err != nil {
+			cancelStream()
+			log.Printf("Pyth SSE request error: %v", err)
+			return
+		}
+
+		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
+			cancelStream()
+			if ctx.Err() != nil {
+				return
+			}
 			log.Printf("Pyth SSE connection error: %v", err)
-			time.Sleep(5 * time.Second)
+			sup.Failed(err)
+			if !sup.Backoff(ctx) {
+				return
+			}
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			cancelStream()
+			log.Printf("Pyth SSE unexpected status: %s", resp.Status)
+			sup.Failed(fmt.Errorf("unexpected status %s", resp.Status))
+			if !sup.Backoff(ctx) {
+				return
+			}
 			continue
 		}
 		
 		log.Printf("Connected to Pyth SSE")
+		sup.Connected()
+		sup.Subscribed()
+
+		// Hermes streams updates several times a second, so silence means a dead stream
+		var stale atomic.Bool
+		watchdog := time.AfterFunc(staleAfter, func() {
+			stale.Store(true)
+			sup.Stale()
+			cancelStream()
+		})
 		
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
 			line := scanner.Text()
+			receivedAt := time.Now()
 			
 			// SSE format: lines starting with "data:" contain the JSON data
 			if strings.HasPrefix(line, "data:") {
@@ -96,6 +159,10 @@ func ConnectPythPrices(symbols []string, priceChan chan<- PriceData, orderbookCh
 				if data == "" || data == "heartbeat" {
 					continue
 				}
+
+				// Only price updates hold off the watchdog, not heartbeats
+				watchdog.Reset(staleAfter)
+				sup.Message()
 				
 				var response PythSSEResponse
 				if err := json.Unmarshal([]byte(data), &response); err != nil {
@@ -127,10 +194,11 @@ func ConnectPythPrices(symbols []string, priceChan chan<- PriceData, orderbookCh
 										
 					// Create price data
 					priceData := PriceData{
-						Symbol:    symbol,
-						Source:    "pyth",
-						Price:     price,
-						Timestamp: feed.Price.PublishTime * 1000, // Convert to milliseconds
+						Symbol:     symbol,
+						Source:     "pyth",
+						Price:      price,
+						Timestamp:  feed.Price.PublishTime * 1000, // Convert to milliseconds
+						ReceivedAt: receivedAt,
 					}
 					
 					priceChan <- priceData
@@ -138,12 +206,24 @@ func ConnectPythPrices(symbols []string, priceChan chan<- PriceData, orderbookCh
 			}
 		}
 		
-		if err := scanner.Err(); err != nil {
-			log.Printf("Pyth SSE scanner error: %v", err)
+		watchdog.Stop()
+		scanErr := scanner.Err()
+		if stale.Load() {
+			scanErr = fmt.Errorf("%w: no data for %s", errStale, staleAfter)
+		}
+		if scanErr != nil && ctx.Err() == nil {
+			log.Printf("Pyth SSE scanner error: %v", scanErr)
 		}
+		sup.Disconnected(scanErr)
 		
 		resp.Body.Close()
-		log.Printf("Pyth SSE connection closed, reconnecting in 5 seconds...")
-		time.Sleep(5 * time.Second)
+		cancelStream()
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Pyth SSE connection closed, reconnecting...")
+		if !sup.Backoff(ctx) {
+			return
+		}
 	}
 }
\ No newline at end of file
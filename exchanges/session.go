@@ -0,0 +1,140 @@
+package exchanges
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultStaleTimeout is how long a feed may stay silent before it is
+// considered dead and reconnected
+const DefaultStaleTimeout = 30 * time.Second
+
+// errStale is returned by wsSession reads when the watchdog fires
+var errStale = errors.New("feed stale")
+
+// wsSession wraps a websocket connection with serialized writes, a read
+// deadline watchdog and an optional application level keepalive
+type wsSession struct {
+	conn       *websocket.Conn
+	sup        *Supervisor
+	staleAfter time.Duration
+	lastData   time.Time // only touched by the reading goroutine
+	writeMutex sync.Mutex
+}
+
+// newSession wraps conn. The stale timeout counts from the last market data
+// message the connector reported through Message, so a socket that only
+// answers heartbeats still goes stale.
+func newSession(conn *websocket.Conn, sup *Supervisor, staleAfter time.Duration) *wsSession {
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleTimeout
+	}
+
+	s := &wsSession{
+		conn:       conn,
+		sup:        sup,
+		staleAfter: staleAfter,
+		lastData:   time.Now(),
+	}
+
+	conn.SetPingHandler(func(data string) error {
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil
+		}
+		return err
+	})
+
+	return s
+}
+
+// ReadMessage reads the next message. If no market data has been reported
+// through Message within the stale timeout the feed is marked stale and an
+// error wrapping errStale is returned; the connection must then be discarded.
+func (s *wsSession) ReadMessage() ([]byte, error) {
+	s.conn.SetReadDeadline(s.lastData.Add(s.staleAfter))
+
+	_, data, err := s.conn.ReadMessage()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			s.sup.Stale()
+			return nil, fmt.Errorf("%w: no data for %s", errStale, s.staleAfter)
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Message records that the last message read carried data rather than a
+// heartbeat reply. Connectors call it after filtering out pongs so a venue
+// that stops publishing but keeps answering pings is still caught.
+func (s *wsSession) Message() {
+	s.lastData = time.Now()
+	s.sup.Message()
+}
+
+// ReadJSON reads the next data message and decodes it into v
+func (s *wsSession) ReadJSON(v interface{}) error {
+	data, err := s.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteJSON serializes writes so keepalives and subscriptions can share the socket
+func (s *wsSession) WriteJSON(v interface{}) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	return s.conn.WriteJSON(v)
+}
+
+// WriteMessage writes a raw message, serialized with other writes
+func (s *wsSession) WriteMessage(messageType int, data []byte) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	return s.conn.WriteMessage(messageType, data)
+}
+
+// KeepAlive calls ping every interval until the returned stop function is
+// called or ping fails
+func (s *wsSession) KeepAlive(interval time.Duration, ping func() error) (stop func()) {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := ping(); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// pingFrame sends a websocket protocol level ping
+func (s *wsSession) pingFrame() error {
+	return s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second))
+}
@@ -0,0 +1,84 @@
+package exchanges
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// heartbeatServer answers every text "ping" with "pong" and otherwise sends
+// nothing, like a venue that stopped publishing but keeps the socket alive
+func heartbeatServer(t *testing.T) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(message) == "ping" {
+				conn.WriteMessage(websocket.TextMessage, []byte("pong"))
+			}
+			// Protocol pings must not keep the feed alive either
+			conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestSessionStaleOnHeartbeatsOnly(t *testing.T) {
+	conn, _, err := websocket.DefaultDialer.Dial(heartbeatServer(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sup := NewSupervisor("test_heartbeat_only")
+	sup.Subscribed()
+	sess := newSession(conn, sup, 300*time.Millisecond)
+	stopPing := sess.KeepAlive(20*time.Millisecond, func() error {
+		return sess.WriteMessage(websocket.TextMessage, []byte("ping"))
+	})
+	defer stopPing()
+
+	start := time.Now()
+	var pongs int
+	for {
+		message, err := sess.ReadMessage()
+		if err != nil {
+			if !errors.Is(err, errStale) {
+				t.Fatalf("ReadMessage: %v, want errStale", err)
+			}
+			break
+		}
+		if string(message) == "pong" {
+			pongs++
+			continue
+		}
+		sess.Message()
+	}
+
+	if pongs == 0 {
+		t.Error("no pongs were received before the feed went stale")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("feed went stale after %s, want about 300ms", elapsed)
+	}
+	if state := sup.Status().State; state != StateStale {
+		t.Errorf("state = %s, want %s", state, StateStale)
+	}
+}
@@ -0,0 +1,250 @@
+package exchanges
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConnState is the lifecycle state of a connector's feed
+type ConnState string
+
+const (
+	StateConnecting ConnState = "connecting"
+	StateSubscribed ConnState = "subscribed"
+	StateStreaming  ConnState = "streaming"
+	StateStale      ConnState = "stale"
+	StateBackoff    ConnState = "backoff"
+	StateStopped    ConnState = "stopped"
+)
+
+// ConnectionStatus is a snapshot of a feed's health for the UI
+type ConnectionStatus struct {
+	Source      string    `json:"source"`
+	State       ConnState `json:"state"`
+	Since       int64     `json:"since"` // Unix ms of the last state change
+	Connects    int64     `json:"connects"`
+	Disconnects int64     `json:"disconnects"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastMessage int64     `json:"last_message,omitempty"` // Unix ms
+	RetryAt     int64     `json:"retry_at,omitempty"`     // Unix ms, set while in backoff
+	// Resyncs counts local order books rebuilt after an integrity check failed
+	Resyncs    int64  `json:"resyncs"`
+	LastResync string `json:"last_resync,omitempty"`
+}
+
+const (
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 60 * time.Second
+)
+
+// Supervisor tracks the connection state of one feed and computes
+// reconnect delays with exponential backoff and jitter
+type Supervisor struct {
+	mu       sync.Mutex
+	status   ConnectionStatus
+	attempts int
+}
+
+var (
+	supervisors      = make(map[string]*Supervisor)
+	supervisorsMutex sync.RWMutex
+)
+
+// StateListener is told when a feed changes state. It runs on the
+// connector's goroutine after the supervisor's lock is released, so anything
+// the connector sent before the change has already been handed over.
+type StateListener func(source string, state ConnState)
+
+var (
+	stateListeners      []StateListener
+	stateListenersMutex sync.RWMutex
+)
+
+// OnStateChange registers a listener for the state changes of every feed.
+// Register listeners before the connectors are started.
+func OnStateChange(listener StateListener) {
+	stateListenersMutex.Lock()
+	defer stateListenersMutex.Unlock()
+
+	stateListeners = append(stateListeners, listener)
+}
+
+func notifyStateChange(source string, state ConnState) {
+	stateListenersMutex.RLock()
+	defer stateListenersMutex.RUnlock()
+
+	for _, listener := range stateListeners {
+		listener(source, state)
+	}
+}
+
+// NewSupervisor creates the supervisor for a source and makes its status
+// visible through ConnectionStatuses
+func NewSupervisor(source string) *Supervisor {
+	s := &Supervisor{
+		status: ConnectionStatus{
+			Source: source,
+			State:  StateConnecting,
+			Since:  time.Now().UnixMilli(),
+		},
+	}
+
+	supervisorsMutex.Lock()
+	supervisors[source] = s
+	supervisorsMutex.Unlock()
+
+	return s
+}
+
+// ConnectionStatuses returns the status of every supervised feed sorted by source
+func ConnectionStatuses() []ConnectionStatus {
+	supervisorsMutex.RLock()
+	statuses := make([]ConnectionStatus, 0, len(supervisors))
+	for _, s := range supervisors {
+		statuses = append(statuses, s.Status())
+	}
+	supervisorsMutex.RUnlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Source < statuses[j].Source
+	})
+	return statuses
+}
+
+// Status returns a copy of the current status
+func (s *Supervisor) Status() ConnectionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+// setStateLocked changes state and reports whether it changed; the caller
+// must hold s.mu and call notifyStateChange once it is released
+func (s *Supervisor) setStateLocked(state ConnState) bool {
+	if s.status.State == state {
+		return false
+	}
+	s.status.State = state
+	s.status.Since = time.Now().UnixMilli()
+	if state != StateBackoff {
+		s.status.RetryAt = 0
+	}
+	return true
+}
+
+// setState changes state and tells the listeners
+func (s *Supervisor) setState(state ConnState) {
+	s.mu.Lock()
+	changed := s.setStateLocked(state)
+	source := s.status.Source
+	s.mu.Unlock()
+
+	if changed {
+		notifyStateChange(source, state)
+	}
+}
+
+// Connecting marks the start of a dial attempt
+func (s *Supervisor) Connecting() {
+	s.setState(StateConnecting)
+}
+
+// Connected records a successful dial
+func (s *Supervisor) Connected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Connects++
+}
+
+// Subscribed marks that subscriptions were sent and data is expected
+func (s *Supervisor) Subscribed() {
+	s.setState(StateSubscribed)
+}
+
+// Message records received data. The first message after subscribing moves
+// the feed to streaming and resets the backoff.
+func (s *Supervisor) Message() {
+	s.mu.Lock()
+	s.status.LastMessage = time.Now().UnixMilli()
+	changed := s.setStateLocked(StateStreaming)
+	if changed {
+		s.attempts = 0
+	}
+	source := s.status.Source
+	s.mu.Unlock()
+
+	if changed {
+		notifyStateChange(source, StateStreaming)
+	}
+}
+
+// Stale marks the feed as silent; the connector is expected to reconnect
+func (s *Supervisor) Stale() {
+	s.setState(StateStale)
+}
+
+// Failed records a failed dial or subscription
+func (s *Supervisor) Failed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+}
+
+// Disconnected records the loss of an established connection
+func (s *Supervisor) Disconnected(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Disconnects++
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+}
+
+// Resync records that a local order book was discarded and re-requested
+func (s *Supervisor) Resync(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Resyncs++
+	s.status.LastResync = reason
+}
+
+// Stopped marks the feed as shut down
+func (s *Supervisor) Stopped() {
+	s.setState(StateStopped)
+}
+
+// Backoff waits before the next reconnect attempt. The delay doubles with
+// each consecutive failure up to maxBackoff, with jitter so venues sharing a
+// network outage don't reconnect in lockstep. It returns false if ctx is
+// cancelled while waiting.
+func (s *Supervisor) Backoff(ctx context.Context) bool {
+	s.mu.Lock()
+	delay := minBackoff << s.attempts
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	} else {
+		s.attempts++
+	}
+	// Equal jitter: wait between half and the full delay
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	changed := s.setStateLocked(StateBackoff)
+	s.status.RetryAt = time.Now().Add(delay).UnixMilli()
+	source := s.status.Source
+	s.mu.Unlock()
+
+	if changed {
+		notifyStateChange(source, StateBackoff)
+	}
+	return sleepContext(ctx, delay)
+}
@@ -1,25 +1,103 @@
 package exchanges
 
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// Timestamp on every event is the venue's own event time in Unix ms, zero
+// when the venue doesn't timestamp the message. ReceivedAt is the local time
+// the message was read off the socket; it carries a monotonic clock reading,
+// so time.Since(ReceivedAt) measures queueing inside the scanner exactly.
+//
+// Prices are fixed-point Decimals parsed straight from the venue's text, so
+// they compare exactly and encode to JSON as the string the venue sent.
+
 type PriceData struct {
-	Symbol    string
-	Source    string
-	Price     float64
-	Timestamp int64
+	Symbol     string
+	Source     string
+	Price      Decimal
+	Timestamp  int64
+	ReceivedAt time.Time
 }
 
 type OrderbookData struct {
-	Symbol    string
-	Source    string
-	BestBid   float64
-	BestAsk   float64
-	Timestamp int64
+	Symbol  string
+	Source  string
+	BestBid Decimal
+	BestAsk Decimal
+	// BestBidSize and BestAskSize are the base asset quantities resting at
+	// the top of book, zero when the venue doesn't publish them or its
+	// contract size isn't known yet
+	BestBidSize float64
+	BestAskSize float64
+	// Bids and Asks hold up to the connector's configured depth, best price
+	// first; nil when only the top of book is sent
+	Bids       []PriceLevel
+	Asks       []PriceLevel
+	Timestamp  int64
+	ReceivedAt time.Time
 }
 
 type TradeData struct {
-	Symbol    string
-	Source    string
-	Price     float64
-	Quantity  string
-	Side      string // "buy" or "sell" (normalized)
-	Timestamp int64
+	Symbol string
+	Source string
+	Price  Decimal
+	// Quantity is the traded amount in base units and Notional its value in
+	// the quote currency; both are zero when the venue counts contracts and
+	// the contract size isn't known yet
+	Quantity float64
+	Notional float64
+	// RawQuantity is the size exactly as the venue sent it (contracts on OKX
+	// and Gate, coins elsewhere)
+	RawQuantity string
+	Side        string // "buy" or "sell" (normalized)
+	Timestamp   int64
+	ReceivedAt  time.Time
+}
+
+// newTradeData builds a trade from a venue price and raw size, normalizing
+// both through the instrument's multiplier and contract size
+func newTradeData(instrument VenueInstrument, price Decimal, rawQuantity, side string, timestamp int64, receivedAt time.Time) TradeData {
+	trade := TradeData{
+		Symbol:      instrument.Symbol,
+		Source:      instrument.Venue,
+		Price:       instrument.NormalizePrice(price),
+		RawQuantity: rawQuantity,
+		Side:        side,
+		Timestamp:   timestamp,
+		ReceivedAt:  receivedAt,
+	}
+
+	// Some venues sign the size by taker side; the side is already in Side
+	if size, err := strconv.ParseFloat(rawQuantity, 64); err == nil {
+		trade.Quantity = instrument.NormalizeSize(math.Abs(size), price)
+		trade.Notional = trade.Quantity * trade.Price.Float64()
+	}
+	return trade
+}
+
+// Latency returns how long the update took from the venue to this process,
+// and false when the venue sent no timestamp. Clock skew between the venue
+// and the local host is included.
+func (d PriceData) Latency() (time.Duration, bool) {
+	return eventLatency(d.Timestamp, d.ReceivedAt)
+}
+
+// Latency is PriceData.Latency for book updates
+func (d OrderbookData) Latency() (time.Duration, bool) {
+	return eventLatency(d.Timestamp, d.ReceivedAt)
+}
+
+// Latency is PriceData.Latency for trades
+func (d TradeData) Latency() (time.Duration, bool) {
+	return eventLatency(d.Timestamp, d.ReceivedAt)
+}
+
+func eventLatency(timestamp int64, receivedAt time.Time) (time.Duration, bool) {
+	if timestamp <= 0 || receivedAt.IsZero() {
+		return 0, false
+	}
+	return receivedAt.Sub(time.UnixMilli(timestamp)), true
 }
@@ -0,0 +1,207 @@
+package exchanges
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testInstrument resolves symbol with a venue's naming rules in a private
+// registry, so the result doesn't depend on metadata other tests installed.
+// A non-nil spec is installed as the instrument's trading rules.
+func testInstrument(t *testing.T, venue, symbol string, spec *InstrumentSpec) VenueInstrument {
+	t.Helper()
+
+	Instruments.mu.RLock()
+	rules, ok := Instruments.venues[venue]
+	Instruments.mu.RUnlock()
+	if !ok {
+		t.Fatalf("no instrument rules for %s", venue)
+	}
+
+	registry := NewInstrumentRegistry()
+	registry.RegisterVenue(venue, rules)
+	vi, err := registry.Resolve(venue, symbol)
+	if err != nil {
+		t.Fatalf("Resolve(%s, %s): %v", venue, symbol, err)
+	}
+	if spec != nil {
+		withID := *spec
+		withID.NativeID = vi.NativeID
+		registry.SetSpec(venue, withID)
+		vi, _ = registry.Lookup(venue, vi.NativeID)
+	}
+	return vi
+}
+
+// approxEqual compares normalized sizes, which go through float arithmetic
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b))
+}
+
+func TestNewTradeData(t *testing.T) {
+	tests := []struct {
+		name         string
+		venue        string
+		symbol       string
+		spec         *InstrumentSpec
+		price        string
+		raw          string
+		wantNative   string
+		wantPrice    string
+		wantQuantity float64
+		wantNotional float64
+	}{
+		{
+			name: "coin sized perp", venue: "binance_futures", symbol: "BTCUSDT",
+			price: "60000", raw: "0.5",
+			wantNative: "BTCUSDT", wantPrice: "60000", wantQuantity: 0.5, wantNotional: 30000,
+		},
+		{
+			// 1000PEPEUSDT quotes the price of 1000 PEPE and sizes in lots of 1000
+			name: "thousand multiplier", venue: "binance_futures", symbol: "PEPEUSDT",
+			price: "0.012", raw: "2500",
+			wantNative: "1000PEPEUSDT", wantPrice: "0.000012", wantQuantity: 2500000, wantNotional: 30,
+		},
+		{
+			name: "okx contracts", venue: "okx_futures", symbol: "BTCUSDT",
+			spec:  &InstrumentSpec{ContractSize: 0.01},
+			price: "60000", raw: "25",
+			wantNative: "BTC-USDT-SWAP", wantPrice: "60000", wantQuantity: 0.25, wantNotional: 15000,
+		},
+		{
+			name: "okx contract size unknown", venue: "okx_futures", symbol: "ETHUSDT",
+			price: "3000", raw: "10",
+			wantNative: "ETH-USDT-SWAP", wantPrice: "3000",
+		},
+		{
+			// Gate signs trade sizes by taker side
+			name: "gate signed contracts", venue: "gate_futures", symbol: "BTCUSDT",
+			spec:  &InstrumentSpec{ContractSize: 0.0001},
+			price: "60000", raw: "-30",
+			wantNative: "BTC_USDT", wantPrice: "60000", wantQuantity: 0.003, wantNotional: 180,
+		},
+		{
+			// Deribit perps are sized in USD, so notional is the raw size
+			name: "deribit inverse", venue: "deribit_futures", symbol: "BTCUSDT",
+			price: "60000", raw: "30000",
+			wantNative: "BTC-PERPETUAL", wantPrice: "60000", wantQuantity: 0.5, wantNotional: 30000,
+		},
+		{
+			// COIN-M contracts are worth a fixed amount of USD each
+			name: "binance coin-m inverse", venue: "binance_coinm_futures", symbol: "BTCUSDT",
+			spec:  &InstrumentSpec{ContractSize: 100},
+			price: "60000", raw: "3",
+			wantNative: "BTCUSD_PERP", wantPrice: "60000", wantQuantity: 0.005, wantNotional: 300,
+		},
+		{
+			// A quanto contract prices like a linear one but has no base size
+			name: "bitmex quanto", venue: "bitmex_futures", symbol: "ETHUSDT",
+			spec:  &InstrumentSpec{Quanto: true},
+			price: "3000", raw: "10",
+			wantNative: "ETHUSD", wantPrice: "3000",
+		},
+		{
+			name: "unparseable size", venue: "binance_futures", symbol: "BTCUSDT",
+			price: "60000", raw: "n/a",
+			wantNative: "BTCUSDT", wantPrice: "60000",
+		},
+	}
+
+	receivedAt := time.UnixMilli(1700000000100)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vi := testInstrument(t, test.venue, test.symbol, test.spec)
+			if vi.NativeID != test.wantNative {
+				t.Fatalf("native ID = %s, want %s", vi.NativeID, test.wantNative)
+			}
+
+			trade := newTradeData(vi, mustDecimal(t, test.price), test.raw, "sell", 1700000000000, receivedAt)
+
+			if trade.Symbol != test.symbol || trade.Source != test.venue || trade.Side != "sell" {
+				t.Errorf("trade = %s %s %s, want %s %s sell", trade.Source, trade.Symbol, trade.Side, test.venue, test.symbol)
+			}
+			if trade.RawQuantity != test.raw {
+				t.Errorf("RawQuantity = %q, want %q", trade.RawQuantity, test.raw)
+			}
+			if trade.Price.String() != test.wantPrice {
+				t.Errorf("Price = %v, want %v", trade.Price, test.wantPrice)
+			}
+			if !approxEqual(trade.Quantity, test.wantQuantity) {
+				t.Errorf("Quantity = %v, want %v", trade.Quantity, test.wantQuantity)
+			}
+			if !approxEqual(trade.Notional, test.wantNotional) {
+				t.Errorf("Notional = %v, want %v", trade.Notional, test.wantNotional)
+			}
+			if trade.Timestamp != 1700000000000 || !trade.ReceivedAt.Equal(receivedAt) {
+				t.Errorf("times = %d %v", trade.Timestamp, trade.ReceivedAt)
+			}
+		})
+	}
+}
+
+func TestNormalizeSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		venue  string
+		symbol string
+		spec   *InstrumentSpec
+		size   float64
+		price  string
+		want   float64
+	}{
+		{"base units", "binance_futures", "ETHUSDT", nil, 1.5, "3000", 1.5},
+		{"spot", "binance_spot", "ETHUSDT", nil, 2, "3000", 2},
+		{"thousand lots", "binance_futures", "SHIBUSDT", nil, 3, "0.02", 3000},
+		{"okx contracts", "okx_futures", "ETHUSDT", &InstrumentSpec{ContractSize: 0.1}, 12, "3000", 1.2},
+		{"okx unknown contract size", "okx_futures", "SOLUSDT", nil, 12, "150", 0},
+		{"gate contracts", "gate_futures", "ETHUSDT", &InstrumentSpec{ContractSize: 0.01}, 40, "3000", 0.4},
+		{"gate unknown contract size", "gate_futures", "XRPUSDT", nil, 40, "0.5", 0},
+		{"deribit inverse", "deribit_futures", "ETHUSDT", nil, 1500, "3000", 0.5},
+		{"deribit inverse without a price", "deribit_futures", "ETHUSDT", nil, 1500, "0", 0},
+		{"binance coin-m inverse", "binance_coinm_futures", "BTCUSDT", &InstrumentSpec{ContractSize: 100}, 3, "60000", 0.005},
+		{"binance coin-m before metadata", "binance_coinm_futures", "ETHUSDT", nil, 3, "3000", 0},
+		{"bitmex inverse", "bitmex_futures", "BTCUSDT", &InstrumentSpec{ContractSize: 1, Inverse: true}, 6000, "60000", 0.1},
+		{"bitmex quanto", "bitmex_futures", "ETHUSDT", &InstrumentSpec{Quanto: true}, 10, "3000", 0},
+		{"bitmex before metadata", "bitmex_futures", "SOLUSDT", nil, 10, "150", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vi := testInstrument(t, test.venue, test.symbol, test.spec)
+			if got := vi.NormalizeSize(test.size, mustDecimal(t, test.price)); !approxEqual(got, test.want) {
+				t.Errorf("NormalizeSize(%v, %s) = %v, want %v", test.size, test.price, got, test.want)
+			}
+		})
+	}
+}
+
+func TestInstrumentKind(t *testing.T) {
+	tests := []struct {
+		name           string
+		venue          string
+		symbol         string
+		spec           *InstrumentSpec
+		wantKind       string
+		wantSettlement string
+	}{
+		{"linear", "binance_futures", "BTCUSDT", nil, "", "USDT"},
+		{"venue inverse", "deribit_futures", "BTCUSDT", nil, "inverse", "BTC"},
+		{"metadata inverse", "bitmex_futures", "BTCUSDT", &InstrumentSpec{ContractSize: 1, Inverse: true, Settlement: "BTC"}, "inverse", "BTC"},
+		{"metadata quanto", "bitmex_futures", "ETHUSDT", &InstrumentSpec{Quanto: true, Settlement: "BTC"}, "quanto", "BTC"},
+		{"metadata linear", "bitmex_futures", "SOLUSDT", &InstrumentSpec{ContractSize: 1, Settlement: "USDT"}, "", "USDT"},
+		{"dated linear", "binance_quarterly_futures", "BTCUSDT", nil, "dated", "USDT"},
+		{"dated inverse", "binance_coinm_quarterly_futures", "BTCUSDT", &InstrumentSpec{ContractSize: 100, Expiry: 1798185600000}, "dated", "BTC"},
+		{"metadata expiry", "bitmex_futures", "BTCUSDT", &InstrumentSpec{ContractSize: 1, Expiry: 1798185600000, Settlement: "USDT"}, "dated", "USDT"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vi := testInstrument(t, test.venue, test.symbol, test.spec)
+			if kind := vi.Instrument.Kind(); kind != test.wantKind {
+				t.Errorf("Kind() = %q, want %q", kind, test.wantKind)
+			}
+			if vi.Instrument.Settlement != test.wantSettlement {
+				t.Errorf("Settlement = %s, want %s", vi.Instrument.Settlement, test.wantSettlement)
+			}
+		})
+	}
+}
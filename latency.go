@@ -0,0 +1,178 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Latency buckets grow by 25% from 100µs, which covers up to about a minute
+// with under 12% error on any quantile
+const (
+	latencyBucketCount  = 64
+	latencyBucketBase   = 100 * time.Microsecond
+	latencyBucketGrowth = 1.25
+)
+
+// latencyWindow is how long observations count towards the quantiles. Two
+// half windows are kept so old samples age out without a reset cliff.
+const latencyWindow = time.Minute
+
+// LatencyStats is the per-source summary sent to the UI
+type LatencyStats struct {
+	Source string  `json:"source"`
+	P50    float64 `json:"p50_ms"`
+	P99    float64 `json:"p99_ms"`
+	Max    float64 `json:"max_ms"`
+	Count  uint64  `json:"count"`
+	// Skewed counts events stamped later than they were received, which
+	// means the venue's clock runs ahead of ours
+	Skewed uint64 `json:"skewed,omitempty"`
+}
+
+type latencyHistogram struct {
+	buckets [latencyBucketCount]uint64
+	count   uint64
+	skewed  uint64
+	max     time.Duration
+}
+
+func (h *latencyHistogram) observe(latency time.Duration) {
+	if latency < 0 {
+		h.skewed++
+		latency = 0
+	}
+	h.buckets[latencyBucket(latency)]++
+	h.count++
+	if latency > h.max {
+		h.max = latency
+	}
+}
+
+func (h *latencyHistogram) merge(other *latencyHistogram) {
+	for i, n := range other.buckets {
+		h.buckets[i] += n
+	}
+	h.count += other.count
+	h.skewed += other.skewed
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// quantile estimates quantile q as the geometric middle of its bucket
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	var seen uint64
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= rank {
+			middle := time.Duration(float64(latencyBucketBound(i)) / math.Sqrt(latencyBucketGrowth))
+			return min(middle, h.max)
+		}
+	}
+	return h.max
+}
+
+func latencyBucket(latency time.Duration) int {
+	if latency <= latencyBucketBase {
+		return 0
+	}
+	bucket := int(math.Ceil(math.Log(float64(latency)/float64(latencyBucketBase)) / math.Log(latencyBucketGrowth)))
+	return min(bucket, latencyBucketCount-1)
+}
+
+func latencyBucketBound(bucket int) time.Duration {
+	return time.Duration(float64(latencyBucketBase) * math.Pow(latencyBucketGrowth, float64(bucket)))
+}
+
+type sourceLatency struct {
+	current  latencyHistogram
+	previous latencyHistogram
+	started  time.Time
+}
+
+// LatencyTracker keeps a sliding latency histogram per source
+type LatencyTracker struct {
+	mu      sync.Mutex
+	sources map[string]*sourceLatency
+}
+
+func NewLatencyTracker() *LatencyTracker {
+	return &LatencyTracker{
+		sources: make(map[string]*sourceLatency),
+	}
+}
+
+// Observe records the venue to scanner latency of one event
+func (t *LatencyTracker) Observe(source string, latency time.Duration) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sl := t.sources[source]
+	if sl == nil {
+		sl = &sourceLatency{started: now}
+		t.sources[source] = sl
+	}
+	sl.rotate(now)
+	sl.current.observe(latency)
+}
+
+// rotate ages out the previous half window
+func (sl *sourceLatency) rotate(now time.Time) {
+	elapsed := now.Sub(sl.started)
+	if elapsed < latencyWindow/2 {
+		return
+	}
+	if elapsed < latencyWindow {
+		sl.previous = sl.current
+	} else {
+		// Nothing was observed for a whole window
+		sl.previous = latencyHistogram{}
+	}
+	sl.current = latencyHistogram{}
+	sl.started = now
+}
+
+// Snapshot returns the quantiles of every source sorted by source
+func (t *LatencyTracker) Snapshot() []LatencyStats {
+	now := time.Now()
+
+	t.mu.Lock()
+	stats := make([]LatencyStats, 0, len(t.sources))
+	for source, sl := range t.sources {
+		sl.rotate(now)
+
+		var h latencyHistogram
+		h.merge(&sl.previous)
+		h.merge(&sl.current)
+		if h.count == 0 {
+			continue
+		}
+
+		stats = append(stats, LatencyStats{
+			Source: source,
+			P50:    milliseconds(h.quantile(0.50)),
+			P99:    milliseconds(h.quantile(0.99)),
+			Max:    milliseconds(h.max),
+			Count:  h.count,
+			Skewed: h.skewed,
+		})
+	}
+	t.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Source < stats[j].Source
+	})
+	return stats
+}
+
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*10) / 10
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"futures-arbitrage-scanner/exchanges"
@@ -15,37 +21,78 @@ import (
 )
 
 type ArbitrageOpportunity struct {
-	Symbol     string  `json:"symbol"`
-	BuySource  string  `json:"buy_source"`
-	SellSource string  `json:"sell_source"`
-	BuyPrice   float64 `json:"buy_price"`
-	SellPrice  float64 `json:"sell_price"`
-	ProfitPct  float64 `json:"profit_pct"`
-	Timestamp  int64   `json:"timestamp"`
+	Symbol     string            `json:"symbol"`
+	BuySource  string            `json:"buy_source"`
+	SellSource string            `json:"sell_source"`
+	BuyPrice   exchanges.Decimal `json:"buy_price"`
+	SellPrice  exchanges.Decimal `json:"sell_price"`
+	ProfitPct  float64           `json:"profit_pct"`
+	// BuySize is the ask size at the buy venue and SellSize the bid size at
+	// the sell venue; Size is the smaller of the two, i.e. how much of the
+	// gap is actually there to take. Zero when a venue has no book sizes.
+	BuySize  float64 `json:"buy_size,omitempty"`
+	SellSize float64 `json:"sell_size,omitempty"`
+	Size     float64 `json:"size,omitempty"`
+	// BuyKind and SellKind flag inverse, quanto and dated contracts
+	// (Deribit, BitMEX, Binance COIN-M and quarterlies). Inverse and quanto
+	// prices compare directly with linear ones, but a quanto contract has no
+	// size in the base asset, so Size stays zero when either side is quanto.
+	BuyKind  string `json:"buy_kind,omitempty"`
+	SellKind string `json:"sell_kind,omitempty"`
+	// Expiry is the delivery time in Unix ms both sides share when the gap
+	// is between dated futures, which are never compared with perpetuals
+	Expiry    int64 `json:"expiry,omitempty"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// clientSendBuffer is how many messages may wait for a browser client before
+// it is considered too slow and disconnected
+const clientSendBuffer = 256
+
+// wsClient is a browser connection with its own send queue. Its writer
+// goroutine is the only one writing to the socket, so a slow client never
+// holds up the shard workers or the other clients.
+type wsClient struct {
+	conn *websocket.Conn
+	send chan []byte // closed when the client is removed
 }
 
 type FuturesScanner struct {
-	prices           map[string]map[string]float64
-	pricesMutex      sync.RWMutex
-	wsClients        map[*websocket.Conn]bool
-	clientsMutex     sync.RWMutex
-	wsWriteMutex     sync.Mutex // Protects WebSocket writes
-	upgrader         websocket.Upgrader
-	priceChan        chan exchanges.PriceData
-	orderbookChan    chan exchanges.OrderbookData
-	tradeChan        chan exchanges.TradeData
-	lastOpportunity  map[string]time.Time // Track last alert per symbol
-	opportunityMutex sync.RWMutex
+	shards         map[string]*symbolShard // Per symbol state, each owned by one worker
+	shardsMutex    sync.RWMutex
+	shardWorkers   sync.WaitGroup
+	downSources    map[string]bool // feeds that are not streaming
+	downMutex      sync.RWMutex
+	wsClients      map[*wsClient]bool
+	clientsMutex   sync.RWMutex
+	upgrader       websocket.Upgrader
+	priceChan      chan exchanges.PriceData
+	orderbookChan  chan exchanges.OrderbookData
+	tradeChan      chan exchanges.TradeData
+	tradeQueue     *TradeQueue
+	minProfitPct   float64
+	alertCooldown  time.Duration
+	pricesInterval time.Duration
+	statusInterval time.Duration
+	latency        *LatencyTracker
+	tradeFlow      *TradeFlowTracker
 }
 
-func NewFuturesScanner() *FuturesScanner {
+func NewFuturesScanner(cfg *Config) *FuturesScanner {
 	return &FuturesScanner{
-		prices:          make(map[string]map[string]float64),
-		wsClients:       make(map[*websocket.Conn]bool),
-		priceChan:       make(chan exchanges.PriceData, 1000),
-		orderbookChan:   make(chan exchanges.OrderbookData, 1000),
-		tradeChan:       make(chan exchanges.TradeData, 1000),
-		lastOpportunity: make(map[string]time.Time),
+		minProfitPct:   cfg.Thresholds.MinProfitPct,
+		alertCooldown:  time.Duration(cfg.Thresholds.AlertCooldown),
+		pricesInterval: time.Duration(cfg.Broadcast.PricesInterval),
+		statusInterval: time.Duration(cfg.Broadcast.StatusInterval),
+		shards:         make(map[string]*symbolShard),
+		downSources:    make(map[string]bool),
+		wsClients:      make(map[*wsClient]bool),
+		priceChan:      make(chan exchanges.PriceData, 1000),
+		orderbookChan:  make(chan exchanges.OrderbookData, 1000),
+		tradeChan:      make(chan exchanges.TradeData, 1000),
+		tradeQueue:     NewTradeQueue(tradeQueueLimit),
+		latency:        NewLatencyTracker(),
+		tradeFlow:      NewTradeFlowTracker(),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true
@@ -54,66 +101,72 @@ func NewFuturesScanner() *FuturesScanner {
 	}
 }
 
-func (s *FuturesScanner) processPrices() {
-	for priceData := range s.priceChan {
-		s.updatePrice(priceData)
-	}
-}
-
-func (s *FuturesScanner) processOrderbooks() {
-	for orderbookData := range s.orderbookChan {
-		// Calculate mid price from best bid and best ask
-		midPrice := (orderbookData.BestBid + orderbookData.BestAsk) / 2
-		
-		priceData := exchanges.PriceData{
-			Symbol:    orderbookData.Symbol,
-			Source:    orderbookData.Source,
-			Price:     midPrice,
-			Timestamp: orderbookData.Timestamp,
-		}
-		
-		s.updatePrice(priceData)
-	}
-}
-
 func (s *FuturesScanner) processTrades() {
-	for range s.tradeChan {
-		// Keep trade data for future use but don't use for pricing
+	for {
+		tradeData, ok := s.tradeQueue.Next()
+		if !ok {
+			return
+		}
+		// Trades don't move prices, but they still tell us how far behind a feed is
+		if latency, ok := tradeData.Latency(); ok {
+			s.latency.Observe(tradeData.Source, latency)
+		}
+		s.tradeFlow.Add(tradeData)
 	}
 }
 
 
-func (s *FuturesScanner) updatePrice(data exchanges.PriceData) {
-	s.pricesMutex.Lock()
-	if s.prices[data.Symbol] == nil {
-		s.prices[data.Symbol] = make(map[string]float64)
+// checkArbitrage looks for the widest gap between the shard's sources and
+// broadcasts it as an opportunity. The spread matrix is sent periodically by
+// broadcastPrices rather than on every update.
+func (sh *symbolShard) checkArbitrage(prices map[string]exchanges.Decimal) {
+	if len(prices) < 2 {
+		return
 	}
-	s.prices[data.Symbol][data.Source] = data.Price
-	s.pricesMutex.Unlock()
-
-	s.checkArbitrage(data.Symbol)
-}
 
-func (s *FuturesScanner) checkArbitrage(symbol string) {
-	s.pricesMutex.RLock()
-	sourcePrices, exists := s.prices[symbol]
-	if !exists || len(sourcePrices) < 2 {
-		s.pricesMutex.RUnlock()
+	dated := false
+	for source := range prices {
+		if sh.contract(source).Kind == "dated" {
+			dated = true
+			break
+		}
+	}
+	if !dated {
+		sh.checkGap(prices)
 		return
 	}
 
-	// Create a copy of the prices map to avoid race conditions
-	pricesCopy := make(map[string]float64)
-	for source, price := range sourcePrices {
-		pricesCopy[source] = price
+	// A dated future's price includes the basis to its delivery, so it is
+	// only compared with futures delivering at the same time, never with
+	// perpetuals or spot (expiry 0)
+	byExpiry := make(map[int64]map[string]exchanges.Decimal)
+	for source, price := range prices {
+		contract := sh.contract(source)
+		if contract.Kind == "dated" && contract.Expiry == 0 {
+			// Unknown delivery, nothing to compare it with
+			continue
+		}
+		if byExpiry[contract.Expiry] == nil {
+			byExpiry[contract.Expiry] = make(map[string]exchanges.Decimal)
+		}
+		byExpiry[contract.Expiry][source] = price
+	}
+	for _, group := range byExpiry {
+		if len(group) >= 2 {
+			sh.checkGap(group)
+		}
 	}
-	s.pricesMutex.RUnlock()
+}
+
+// checkGap alerts on the widest gap between prices that are comparable
+func (sh *symbolShard) checkGap(prices map[string]exchanges.Decimal) {
+	s := sh.scanner
 
-	var minPrice, maxPrice float64
+	var minPrice, maxPrice exchanges.Decimal
 	var minSource, maxSource string
 	first := true
 
-	for source, price := range pricesCopy {
+	for source, price := range prices {
 		if first {
 			minPrice = price
 			maxPrice = price
@@ -123,96 +176,92 @@ func (s *FuturesScanner) checkArbitrage(symbol string) {
 			continue
 		}
 
-		if price < minPrice {
+		if price.Less(minPrice) {
 			minPrice = price
 			minSource = source
 		}
-		if price > maxPrice {
+		if maxPrice.Less(price) {
 			maxPrice = price
 			maxSource = source
 		}
 	}
 
-	profitPct := ((maxPrice - minPrice) / minPrice) * 100
+	profitPct := spreadPct(minPrice, maxPrice)
+
+	// Only alert if profit is significant (above the configured threshold) and we haven't alerted recently
+	if profitPct > s.minProfitPct {
+		opportunityKey := fmt.Sprintf("%s_%s", minSource, maxSource)
+		lastAlert, exists := sh.lastOpportunity[opportunityKey]
 
-	// Only alert if profit is significant (>0.05%) and we haven't alerted recently
-	if profitPct > 0.05 {
-		opportunityKey := fmt.Sprintf("%s_%s_%s", symbol, minSource, maxSource)
-		
-		s.opportunityMutex.RLock()
-		lastAlert, exists := s.lastOpportunity[opportunityKey]
-		s.opportunityMutex.RUnlock()
-		
 		now := time.Now()
-		// Only send alert if it's been more than 10 seconds since last alert for this pair
+		// Only send alert if the cooldown has passed since last alert for this pair
 		// This prevents spam while still allowing frequent updates for crypto markets
-		if !exists || now.Sub(lastAlert) > 10*time.Second {
-			s.opportunityMutex.Lock()
-			s.lastOpportunity[opportunityKey] = now
-			s.opportunityMutex.Unlock()
+		if !exists || now.Sub(lastAlert) > s.alertCooldown {
+			sh.lastOpportunity[opportunityKey] = now
 
+			buyContract, sellContract := sh.contract(minSource), sh.contract(maxSource)
 			opportunity := ArbitrageOpportunity{
-				Symbol:     symbol,
+				Symbol:     sh.symbol,
 				BuySource:  minSource,
 				SellSource: maxSource,
 				BuyPrice:   minPrice,
 				SellPrice:  maxPrice,
 				ProfitPct:  profitPct,
+				BuySize:    sh.books[minSource].BestAskSize,
+				SellSize:   sh.books[maxSource].BestBidSize,
+				BuyKind:    buyContract.Kind,
+				SellKind:   sellContract.Kind,
+				Expiry:     buyContract.Expiry,
 				Timestamp:  now.UnixMilli(),
 			}
+			quanto := opportunity.BuyKind == "quanto" || opportunity.SellKind == "quanto"
+			if !quanto && opportunity.BuySize > 0 && opportunity.SellSize > 0 {
+				opportunity.Size = min(opportunity.BuySize, opportunity.SellSize)
+			}
 
 			s.broadcastOpportunity(opportunity)
 		}
 	}
-	
-	// Always broadcast current spreads for the spread matrix using the copy
-	s.broadcastSpreads(symbol, pricesCopy)
 }
 
-func (s *FuturesScanner) broadcastOpportunity(opportunity ArbitrageOpportunity) {
-	s.clientsMutex.RLock()
-	clients := make([]*websocket.Conn, 0, len(s.wsClients))
-	for client := range s.wsClients {
-		clients = append(clients, client)
+// contractInfo describes how a source's contract differs from a linear
+// perpetual; the zero value is a linear perpetual or spot
+type contractInfo struct {
+	Kind string `json:"kind"`
+	// Expiry is the delivery time of a dated future in Unix ms
+	Expiry int64 `json:"expiry,omitempty"`
+}
+
+// lookupContract returns the contract of a source for a symbol, the zero
+// value for linear contracts, spot and sources without instruments (Pyth)
+func lookupContract(source, symbol string) contractInfo {
+	instrument, err := exchanges.Instruments.Resolve(source, symbol)
+	if err != nil {
+		return contractInfo{}
 	}
-	s.clientsMutex.RUnlock()
+	return contractInfo{Kind: instrument.Instrument.Kind(), Expiry: instrument.Instrument.Expiry}
+}
+
+// spreadPct returns how far sell is above buy in percent. The difference is
+// taken on the exact decimals so tiny gaps on low priced assets aren't lost
+// to float rounding; only the final ratio is a float.
+func spreadPct(buy, sell exchanges.Decimal) float64 {
+	if buy.Sign() <= 0 {
+		return 0
+	}
+	return sell.Sub(buy).Float64() / buy.Float64() * 100
+}
 
+func (s *FuturesScanner) broadcastOpportunity(opportunity ArbitrageOpportunity) {
 	message := map[string]interface{}{
 		"type":        "arbitrage",
 		"opportunity": opportunity,
 	}
 
-	s.wsWriteMutex.Lock()
-	defer s.wsWriteMutex.Unlock()
-
-	var toRemove []*websocket.Conn
-	for _, client := range clients {
-		err := client.WriteJSON(message)
-		if err != nil {
-			log.Printf("WebSocket write error: %v", err)
-			client.Close()
-			toRemove = append(toRemove, client)
-		}
-	}
-
-	// Remove failed clients
-	if len(toRemove) > 0 {
-		s.clientsMutex.Lock()
-		for _, client := range toRemove {
-			delete(s.wsClients, client)
-		}
-		s.clientsMutex.Unlock()
-	}
+	s.broadcast(message)
 }
 
-func (s *FuturesScanner) broadcastSpreads(symbol string, sourcePrices map[string]float64) {
-	s.clientsMutex.RLock()
-	clients := make([]*websocket.Conn, 0, len(s.wsClients))
-	for client := range s.wsClients {
-		clients = append(clients, client)
-	}
-	s.clientsMutex.RUnlock()
-
+func (s *FuturesScanner) broadcastSpreads(symbol string, sourcePrices map[string]exchanges.Decimal) {
 	// Calculate all pairwise spreads
 	spreads := make(map[string]map[string]float64)
 	
@@ -220,92 +269,183 @@ func (s *FuturesScanner) broadcastSpreads(symbol string, sourcePrices map[string
 		spreads[buySource] = make(map[string]float64)
 		for sellSource, sellPrice := range sourcePrices {
 			if buySource != sellSource {
-				spreadPct := ((sellPrice - buyPrice) / buyPrice) * 100
-				spreads[buySource][sellSource] = spreadPct
+				spreads[buySource][sellSource] = spreadPct(buyPrice, sellPrice)
 			}
 		}
 	}
 
-	message := map[string]interface{}{
-		"type":    "spreads",
-		"symbol":  symbol,
-		"spreads": spreads,
-		"prices":  sourcePrices,
-	}
-
-	s.wsWriteMutex.Lock()
-	defer s.wsWriteMutex.Unlock()
-
-	var toRemove []*websocket.Conn
-	for _, client := range clients {
-		err := client.WriteJSON(message)
-		if err != nil {
-			log.Printf("WebSocket write error: %v", err)
-			client.Close()
-			toRemove = append(toRemove, client)
+	// Tell the UI which columns aren't linear contracts
+	contracts := make(map[string]contractInfo)
+	for source := range sourcePrices {
+		if contract := lookupContract(source, symbol); contract.Kind != "" {
+			contracts[source] = contract
 		}
 	}
 
-	// Remove failed clients
-	if len(toRemove) > 0 {
-		s.clientsMutex.Lock()
-		for _, client := range toRemove {
-			delete(s.wsClients, client)
-		}
-		s.clientsMutex.Unlock()
+	message := map[string]interface{}{
+		"type":      "spreads",
+		"symbol":    symbol,
+		"spreads":   spreads,
+		"prices":    sourcePrices,
+		"contracts": contracts,
 	}
+
+	s.broadcast(message)
 }
 
 
-func (s *FuturesScanner) broadcastPrices() {
-	ticker := time.NewTicker(200 * time.Millisecond)
+func (s *FuturesScanner) broadcastPrices(ctx context.Context) {
+	ticker := time.NewTicker(s.pricesInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		s.pricesMutex.RLock()
-		pricesCopy := make(map[string]map[string]float64)
-		for symbol, prices := range s.prices {
-			pricesCopy[symbol] = make(map[string]float64)
-			for exchange, price := range prices {
-				pricesCopy[symbol][exchange] = price
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Each shard's prices are copied once per tick, and the spread
+		// matrix is only rebuilt for symbols whose prices moved
+		pricesCopy := make(map[string]map[string]exchanges.Decimal)
+		changedPrices := make(map[string]map[string]exchanges.Decimal)
+		for _, shard := range s.allShards() {
+			prices, changed := shard.Snapshot()
+			if len(prices) > 0 {
+				pricesCopy[shard.symbol] = prices
+			}
+			// A symbol that lost a down feed is redrawn even when fewer than
+			// two sources are left, so the feed leaves its matrix
+			if changed {
+				changedPrices[shard.symbol] = prices
 			}
 		}
-		s.pricesMutex.RUnlock()
 
 		if len(pricesCopy) > 0 {
-			message := map[string]interface{}{
+			s.broadcast(map[string]interface{}{
 				"type":   "prices",
 				"prices": pricesCopy,
-			}
+			})
+		}
+		for symbol, prices := range changedPrices {
+			s.broadcastSpreads(symbol, prices)
+		}
+	}
+}
 
-			s.clientsMutex.RLock()
-			clients := make([]*websocket.Conn, 0, len(s.wsClients))
-			for client := range s.wsClients {
-				clients = append(clients, client)
-			}
-			s.clientsMutex.RUnlock()
-
-			s.wsWriteMutex.Lock()
-			var toRemove []*websocket.Conn
-			for _, client := range clients {
-				err := client.WriteJSON(message)
-				if err != nil {
-					log.Printf("WebSocket write error: %v", err)
-					client.Close()
-					toRemove = append(toRemove, client)
-				}
-			}
-			s.wsWriteMutex.Unlock()
-
-			// Remove failed clients
-			if len(toRemove) > 0 {
-				s.clientsMutex.Lock()
-				for _, client := range toRemove {
-					delete(s.wsClients, client)
-				}
-				s.clientsMutex.Unlock()
-			}
+// broadcastStatus periodically pushes the connection state, latency
+// quantiles and pipeline counters of every feed
+func (s *FuturesScanner) broadcastStatus(ctx context.Context) {
+	ticker := time.NewTicker(s.statusInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
+
+		s.broadcast(map[string]interface{}{
+			"type":     "status",
+			"statuses": exchanges.ConnectionStatuses(),
+			"latency":  s.latency.Snapshot(),
+			"pipeline": s.pipelineStats(),
+		})
+	}
+}
+
+// broadcastTradeFlow periodically pushes the rolling trade flow per symbol and source
+func (s *FuturesScanner) broadcastTradeFlow(ctx context.Context) {
+	ticker := time.NewTicker(s.statusInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		flow := s.tradeFlow.Snapshot()
+		if len(flow) > 0 {
+			s.broadcast(map[string]interface{}{
+				"type": "trade_flow",
+				"flow": flow,
+			})
+		}
+	}
+}
+
+// broadcast encodes a message once and queues it for every connected browser
+// client without blocking. Clients whose queue is full are disconnected.
+func (s *FuturesScanner) broadcast(message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("WebSocket encode error: %v", err)
+		return
+	}
+
+	var slow []*wsClient
+	s.clientsMutex.RLock()
+	for client := range s.wsClients {
+		select {
+		case client.send <- data:
+		default:
+			slow = append(slow, client)
+		}
+	}
+	s.clientsMutex.RUnlock()
+
+	for _, client := range slow {
+		log.Printf("WebSocket client %s too slow, disconnecting", client.conn.RemoteAddr())
+		s.removeClient(client)
+		client.conn.Close()
+	}
+}
+
+// removeClient unregisters a client and closes its send queue, which stops
+// its writer. Sends happen under the read lock, so none can race the close.
+func (s *FuturesScanner) removeClient(client *wsClient) bool {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if !s.wsClients[client] {
+		return false
+	}
+	delete(s.wsClients, client)
+	close(client.send)
+	return true
+}
+
+// writeClient sends a client's queued messages until its queue is closed
+func (s *FuturesScanner) writeClient(client *wsClient) {
+	for data := range client.send {
+		if err := client.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			log.Printf("WebSocket write error: %v", err)
+			client.conn.Close()
+			s.removeClient(client)
+			return
+		}
+	}
+}
+
+func (s *FuturesScanner) closeClients() {
+	s.clientsMutex.Lock()
+	clients := make([]*wsClient, 0, len(s.wsClients))
+	for client := range s.wsClients {
+		clients = append(clients, client)
+		close(client.send)
+	}
+	s.wsClients = make(map[*wsClient]bool)
+	s.clientsMutex.Unlock()
+
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+
+	// WriteControl may run concurrently with the writers finishing up
+	for _, client := range clients {
+		client.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+		client.conn.Close()
 	}
 }
 
@@ -319,18 +459,22 @@ func (s *FuturesScanner) handleWebSocket(w http.ResponseWriter, r *http.Request)
 	}
 	defer conn.Close()
 
+	client := &wsClient{conn: conn, send: make(chan []byte, clientSendBuffer)}
+
 	s.clientsMutex.Lock()
-	s.wsClients[conn] = true
+	s.wsClients[client] = true
 	clientCount := len(s.wsClients)
 	s.clientsMutex.Unlock()
 
 	log.Printf("WebSocket client connected from %s. Total clients: %d", r.RemoteAddr, clientCount)
 
+	go s.writeClient(client)
+
 	defer func() {
-		s.clientsMutex.Lock()
-		delete(s.wsClients, conn)
+		s.removeClient(client)
+		s.clientsMutex.RLock()
 		log.Printf("WebSocket client disconnected. Total clients: %d", len(s.wsClients))
-		s.clientsMutex.Unlock()
+		s.clientsMutex.RUnlock()
 	}()
 
 	for {
@@ -341,47 +485,162 @@ func (s *FuturesScanner) handleWebSocket(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// handleInstruments serves the resolved venue instruments with their specs
+func handleInstruments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(exchanges.Instruments.All()); err != nil {
+		log.Printf("Instruments encode error: %v", err)
+	}
+}
+
+// listConnectors prints the registered connectors for tooling
+func listConnectors() {
+	for _, connector := range exchanges.Connectors() {
+		symbols := "any"
+		if supported := connector.SupportedSymbols(); supported != nil {
+			symbols = strings.Join(supported, ",")
+		}
+		fmt.Printf("%-22s %-8s %s\n", connector.Name(), connector.MarketType(), symbols)
+	}
+}
+
+// resolveConfigPath picks the config file from the flag, the CONFIG_FILE
+// environment variable or ./config.json, in that order. An empty result means
+// the built-in defaults are used.
+func resolveConfigPath(flagPath string) string {
+	if flagPath != "" {
+		return flagPath
+	}
+	if envPath := os.Getenv("CONFIG_FILE"); envPath != "" {
+		return envPath
+	}
+	if _, err := os.Stat("config.json"); err == nil {
+		return "config.json"
+	}
+	return ""
+}
+
 func main() {
+	listOnly := flag.Bool("list-connectors", false, "print the available exchange connectors and exit")
+	configPath := flag.String("config", "", "path to the JSON config file (default $CONFIG_FILE or ./config.json if present)")
+	flag.Parse()
+
+	if *listOnly {
+		listConnectors()
+		return
+	}
+
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("No .env file found, using system environment variables")
 	}
 
-	scanner := NewFuturesScanner()
+	cfg, err := LoadConfig(resolveConfigPath(*configPath))
+	if err != nil {
+		log.Fatalf("Config error: %v", err)
+	}
+
+	for _, name := range exchanges.Names() {
+		exchanges.Configure(name, cfg.ConnectorOptions(name))
+	}
+
+	scanner := NewFuturesScanner(cfg)
+	symbols := cfg.Symbols
+	exchanges.OnStateChange(scanner.feedStateChanged)
 
-	symbols := []string{"BTCUSDT", "ETHUSDT", "XRPUSDT", "SOLUSDT"}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Connectors write to the channels, which are routed into non-blocking
+	// queues so a slow scanner coalesces updates instead of stalling sockets.
+	// Prices and books go to one worker per symbol, started on first update.
+	var routers sync.WaitGroup
+	routers.Add(2)
+	go func() { defer routers.Done(); scanner.routePrices() }()
+	go func() { defer routers.Done(); scanner.routeOrderbooks() }()
+	go pump(scanner.tradeChan, scanner.tradeQueue)
 
 	// Start processing goroutines
-	go scanner.processPrices()
-	go scanner.processOrderbooks()
-	go scanner.processTrades()
-
-	// Start exchange connections with orderbook feeds
-	go exchanges.ConnectBinanceFutures(symbols, scanner.priceChan, scanner.orderbookChan, scanner.tradeChan)
-	go exchanges.ConnectBybitFutures(symbols, scanner.priceChan, scanner.orderbookChan, scanner.tradeChan)
-	go exchanges.ConnectHyperliquidFutures(symbols, scanner.priceChan, scanner.orderbookChan, scanner.tradeChan)
-	go exchanges.ConnectKrakenFutures(symbols, scanner.priceChan, scanner.orderbookChan, scanner.tradeChan)
-	go exchanges.ConnectOKXFutures(symbols, scanner.priceChan, scanner.orderbookChan, scanner.tradeChan)
-	go exchanges.ConnectGateFutures(symbols, scanner.priceChan, scanner.orderbookChan, scanner.tradeChan)
-	go exchanges.ConnectParadexFutures(symbols, scanner.priceChan, scanner.orderbookChan, scanner.tradeChan)
-	
-	// Start spot exchange connections with orderbook feeds
-	go exchanges.ConnectBinanceSpot(symbols, scanner.priceChan, scanner.orderbookChan, scanner.tradeChan)
-	go exchanges.ConnectBybitSpot(symbols, scanner.priceChan, scanner.orderbookChan, scanner.tradeChan)
-	
-	// Start Pyth price feed connection
-	go exchanges.ConnectPythPrices(symbols, scanner.priceChan, scanner.orderbookChan, scanner.tradeChan)
+	var processors sync.WaitGroup
+	processors.Add(1)
+	go func() { defer processors.Done(); scanner.processTrades() }()
+
+	// Load tick, lot and contract sizes before any data flows so sizes are
+	// normalized from the first update
+	metadataCtx, cancelMetadata := context.WithTimeout(ctx, time.Duration(cfg.Metadata.Timeout))
+	exchanges.LoadInstrumentSpecs(metadataCtx, cfg.EnabledConnectors(), symbols, cfg.Metadata.CacheFile)
+	cancelMetadata()
+
+	// Start the enabled exchange connectors (all registered connectors by default)
+	var connectors sync.WaitGroup
+	for _, name := range cfg.EnabledConnectors() {
+		connector, ok := exchanges.Lookup(name)
+		if !ok {
+			log.Fatalf("Unknown connector %q (available: %s)", name, strings.Join(exchanges.Names(), ", "))
+		}
+
+		connectorSymbols := exchanges.FilterSymbols(connector, symbols)
+		if len(connectorSymbols) == 0 {
+			log.Printf("Skipping %s: none of %v supported", name, symbols)
+			continue
+		}
+
+		log.Printf("Starting %s connector for %v", name, connectorSymbols)
+		connectors.Add(1)
+		go func() {
+			defer connectors.Done()
+			connector.Run(ctx, connectorSymbols, scanner.priceChan, scanner.orderbookChan, scanner.tradeChan)
+		}()
+	}
+
+	go scanner.broadcastPrices(ctx)
+	go scanner.broadcastStatus(ctx)
+	go scanner.broadcastTradeFlow(ctx)
 
-	go scanner.broadcastPrices()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", scanner.handleWebSocket)
+	mux.HandleFunc("/api/instruments", handleInstruments)
+	mux.Handle("/", http.FileServer(http.Dir("./static/")))
 
-	http.HandleFunc("/ws", scanner.handleWebSocket)
-	http.Handle("/", http.FileServer(http.Dir("./static/")))
+	port := cfg.Port
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8082"
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: mux,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on http://localhost:%s", port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("Server error: %v", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Printf("Shutting down...")
+
+	// Connectors stop on cancellation; the processors keep draining the
+	// queues until every connector has returned and the pumps have closed them
+	connectors.Wait()
+	close(scanner.priceChan)
+	close(scanner.orderbookChan)
+	close(scanner.tradeChan)
+	routers.Wait()
+	scanner.closeShards()
+	processors.Wait()
+
+	scanner.closeClients()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
 	}
 
-	log.Printf("Server starting on http://localhost:%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	log.Printf("Shutdown complete")
 }
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"futures-arbitrage-scanner/exchanges"
+)
+
+// tradeQueueLimit bounds the trades waiting for the scanner. Trades can't be
+// conflated without losing volume, so past this the oldest are dropped.
+const tradeQueueLimit = 10000
+
+// PipelineStats counts how the buffers between a source's connector and the
+// scanner absorbed a slow consumer
+type PipelineStats struct {
+	Source   string `json:"source"`
+	Received uint64 `json:"received"`
+	// Coalesced counts price and book updates replaced by a newer one for
+	// the same symbol before the scanner got to them
+	Coalesced uint64 `json:"coalesced,omitempty"`
+	// Dropped counts trades discarded because the trade queue was full
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+type eventKey struct {
+	symbol string
+	source string
+}
+
+// eventQueue is the consumer side shared by both buffers. Put never blocks,
+// so connectors keep reading their sockets however far behind the scanner is.
+type eventQueue struct {
+	mu     sync.Mutex
+	ready  chan struct{} // signalled after every Put and on close
+	closed bool
+	stats  map[string]*PipelineStats
+}
+
+func newEventQueue() eventQueue {
+	return eventQueue{
+		ready: make(chan struct{}, 1),
+		stats: make(map[string]*PipelineStats),
+	}
+}
+
+// sourceStats returns the counters of a source; the caller holds mu
+func (q *eventQueue) sourceStats(source string) *PipelineStats {
+	stats := q.stats[source]
+	if stats == nil {
+		stats = &PipelineStats{Source: source}
+		q.stats[source] = stats
+	}
+	return stats
+}
+
+func (q *eventQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Close wakes the consumer, which drains what is left and then stops
+func (q *eventQueue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+// ConflatingQueue hands the scanner the latest event per symbol and source.
+// An event that arrives while an older one for the same key is still waiting
+// replaces it and keeps its place in line, so a busy feed can't starve the
+// others and the scanner never works on stale prices.
+type ConflatingQueue[T any] struct {
+	eventQueue
+	key     func(T) eventKey
+	pending map[eventKey]T
+	order   []eventKey
+}
+
+func NewConflatingQueue[T any](key func(T) eventKey) *ConflatingQueue[T] {
+	return &ConflatingQueue[T]{
+		eventQueue: newEventQueue(),
+		key:        key,
+		pending:    make(map[eventKey]T),
+	}
+}
+
+// Put queues an event, replacing any waiting event with the same key
+func (q *ConflatingQueue[T]) Put(event T) {
+	key := q.key(event)
+
+	q.mu.Lock()
+	stats := q.sourceStats(key.source)
+	stats.Received++
+	if _, waiting := q.pending[key]; waiting {
+		stats.Coalesced++
+	} else {
+		q.order = append(q.order, key)
+	}
+	q.pending[key] = event
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// Next blocks until an event is available; ok is false once the queue is
+// closed and drained
+func (q *ConflatingQueue[T]) Next() (event T, ok bool) {
+	for {
+		q.mu.Lock()
+		if len(q.order) > 0 {
+			key := q.order[0]
+			q.order = q.order[1:]
+			event = q.pending[key]
+			delete(q.pending, key)
+			q.mu.Unlock()
+			return event, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+
+		if closed {
+			return event, false
+		}
+		<-q.ready
+	}
+}
+
+// TradeQueue is a bounded FIFO of trades that drops the oldest when full
+type TradeQueue struct {
+	eventQueue
+	trades []exchanges.TradeData
+	limit  int
+}
+
+func NewTradeQueue(limit int) *TradeQueue {
+	return &TradeQueue{
+		eventQueue: newEventQueue(),
+		limit:      limit,
+	}
+}
+
+// Put queues a trade, dropping the oldest waiting trade if the queue is full
+func (q *TradeQueue) Put(trade exchanges.TradeData) {
+	q.mu.Lock()
+	q.sourceStats(trade.Source).Received++
+	if len(q.trades) >= q.limit {
+		q.sourceStats(q.trades[0].Source).Dropped++
+		q.trades = q.trades[1:]
+	}
+	q.trades = append(q.trades, trade)
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// Next blocks until a trade is available; ok is false once the queue is
+// closed and drained
+func (q *TradeQueue) Next() (trade exchanges.TradeData, ok bool) {
+	for {
+		q.mu.Lock()
+		if len(q.trades) > 0 {
+			trade = q.trades[0]
+			q.trades = q.trades[1:]
+			q.mu.Unlock()
+			return trade, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+
+		if closed {
+			return trade, false
+		}
+		<-q.ready
+	}
+}
+
+// mergePipelineStats sums the counters of several queues per source, sorted
+// by source
+func mergePipelineStats(queues ...*eventQueue) []PipelineStats {
+	merged := make(map[string]*PipelineStats)
+	for _, q := range queues {
+		q.mu.Lock()
+		for source, stats := range q.stats {
+			total := merged[source]
+			if total == nil {
+				total = &PipelineStats{Source: source}
+				merged[source] = total
+			}
+			total.Received += stats.Received
+			total.Coalesced += stats.Coalesced
+			total.Dropped += stats.Dropped
+		}
+		q.mu.Unlock()
+	}
+
+	stats := make([]PipelineStats, 0, len(merged))
+	for _, total := range merged {
+		stats = append(stats, *total)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Source < stats[j].Source
+	})
+	return stats
+}
+
+func priceKey(data exchanges.PriceData) eventKey {
+	return eventKey{symbol: data.Symbol, source: data.Source}
+}
+
+func orderbookKey(data exchanges.OrderbookData) eventKey {
+	return eventKey{symbol: data.Symbol, source: data.Source}
+}
+
+// pump moves events from a connector channel into a queue, closing the queue
+// once the channel is closed. Put never blocks, so neither does the channel.
+func pump[T any](events <-chan T, queue interface {
+	Put(T)
+	Close()
+}) {
+	for event := range events {
+		queue.Put(event)
+	}
+	queue.Close()
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"futures-arbitrage-scanner/exchanges"
+)
+
+type testEvent struct {
+	symbol, source, price string
+}
+
+func drainPrices(q *ConflatingQueue[exchanges.PriceData]) []testEvent {
+	q.Close()
+	var events []testEvent
+	for {
+		data, ok := q.Next()
+		if !ok {
+			return events
+		}
+		events = append(events, testEvent{data.Symbol, data.Source, data.Price.String()})
+	}
+}
+
+func TestConflatingQueue(t *testing.T) {
+	tests := []struct {
+		name      string
+		put       []testEvent
+		want      []testEvent
+		wantStats []PipelineStats
+	}{
+		{
+			name: "distinct keys keep arrival order",
+			put: []testEvent{
+				{"BTCUSDT", "okx_futures", "100"},
+				{"ETHUSDT", "okx_futures", "10"},
+				{"BTCUSDT", "binance_futures", "101"},
+			},
+			want: []testEvent{
+				{"BTCUSDT", "okx_futures", "100"},
+				{"ETHUSDT", "okx_futures", "10"},
+				{"BTCUSDT", "binance_futures", "101"},
+			},
+			wantStats: []PipelineStats{
+				{Source: "binance_futures", Received: 1},
+				{Source: "okx_futures", Received: 2},
+			},
+		},
+		{
+			name: "latest value keeps the first place in line",
+			put: []testEvent{
+				{"BTCUSDT", "okx_futures", "100"},
+				{"BTCUSDT", "binance_futures", "101"},
+				{"BTCUSDT", "okx_futures", "102"},
+				{"BTCUSDT", "okx_futures", "103"},
+			},
+			want: []testEvent{
+				{"BTCUSDT", "okx_futures", "103"},
+				{"BTCUSDT", "binance_futures", "101"},
+			},
+			wantStats: []PipelineStats{
+				{Source: "binance_futures", Received: 1},
+				{Source: "okx_futures", Received: 3, Coalesced: 2},
+			},
+		},
+		{
+			name: "same source different symbols",
+			put: []testEvent{
+				{"BTCUSDT", "okx_futures", "100"},
+				{"ETHUSDT", "okx_futures", "10"},
+				{"ETHUSDT", "okx_futures", "11"},
+			},
+			want: []testEvent{
+				{"BTCUSDT", "okx_futures", "100"},
+				{"ETHUSDT", "okx_futures", "11"},
+			},
+			wantStats: []PipelineStats{
+				{Source: "okx_futures", Received: 3, Coalesced: 1},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := NewConflatingQueue(priceKey)
+			for _, event := range test.put {
+				q.Put(exchanges.PriceData{Symbol: event.symbol, Source: event.source, Price: testPrice(t, event.price)})
+			}
+
+			if got := drainPrices(q); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("drained %v, want %v", got, test.want)
+			}
+			if got := mergePipelineStats(&q.eventQueue); !reflect.DeepEqual(got, test.wantStats) {
+				t.Errorf("stats = %+v, want %+v", got, test.wantStats)
+			}
+		})
+	}
+}
+
+func TestConflatingQueueRequeuesAfterNext(t *testing.T) {
+	q := NewConflatingQueue(priceKey)
+	q.Put(exchanges.PriceData{Symbol: "BTCUSDT", Source: "okx_futures", Price: testPrice(t, "100")})
+	if _, ok := q.Next(); !ok {
+		t.Fatal("Next returned nothing")
+	}
+
+	// Once taken, a new event for the key is queued again, not coalesced
+	q.Put(exchanges.PriceData{Symbol: "BTCUSDT", Source: "okx_futures", Price: testPrice(t, "101")})
+	if got := drainPrices(q); len(got) != 1 || got[0].price != "101" {
+		t.Errorf("drained %v, want the second update", got)
+	}
+	if stats := mergePipelineStats(&q.eventQueue); stats[0].Coalesced != 0 {
+		t.Errorf("coalesced = %d, want 0", stats[0].Coalesced)
+	}
+}
+
+func TestTradeQueue(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		put       []string // sources, one trade each
+		want      []string
+		wantStats []PipelineStats
+	}{
+		{
+			name:  "under the limit",
+			limit: 3,
+			put:   []string{"okx_futures", "gate_futures", "okx_futures"},
+			want:  []string{"okx_futures", "gate_futures", "okx_futures"},
+			wantStats: []PipelineStats{
+				{Source: "gate_futures", Received: 1},
+				{Source: "okx_futures", Received: 2},
+			},
+		},
+		{
+			// The dropped trade is counted against the source it came from
+			name:  "full queue drops the oldest",
+			limit: 2,
+			put:   []string{"gate_futures", "okx_futures", "okx_futures", "okx_futures"},
+			want:  []string{"okx_futures", "okx_futures"},
+			wantStats: []PipelineStats{
+				{Source: "gate_futures", Received: 1, Dropped: 1},
+				{Source: "okx_futures", Received: 3, Dropped: 1},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := NewTradeQueue(test.limit)
+			for i, source := range test.put {
+				q.Put(exchanges.TradeData{Symbol: "BTCUSDT", Source: source, Timestamp: int64(i)})
+			}
+			q.Close()
+
+			var got []string
+			for {
+				trade, ok := q.Next()
+				if !ok {
+					break
+				}
+				got = append(got, trade.Source)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("drained %v, want %v", got, test.want)
+			}
+			if stats := mergePipelineStats(&q.eventQueue); !reflect.DeepEqual(stats, test.wantStats) {
+				t.Errorf("stats = %+v, want %+v", stats, test.wantStats)
+			}
+		})
+	}
+}
+
+func TestQueueCloseDrains(t *testing.T) {
+	prices := make(chan exchanges.PriceData, 3)
+	q := NewConflatingQueue(priceKey)
+
+	// A consumer blocked on an empty queue gets everything that was pumped
+	// in and then stops once the channel is closed
+	received := make(chan []testEvent)
+	go func() {
+		var events []testEvent
+		for {
+			data, ok := q.Next()
+			if !ok {
+				received <- events
+				return
+			}
+			events = append(events, testEvent{data.Symbol, data.Source, data.Price.String()})
+		}
+	}()
+
+	prices <- exchanges.PriceData{Symbol: "BTCUSDT", Source: "okx_futures", Price: testPrice(t, "100")}
+	prices <- exchanges.PriceData{Symbol: "ETHUSDT", Source: "okx_futures", Price: testPrice(t, "10")}
+	close(prices)
+	go pump(prices, q)
+
+	select {
+	case events := <-received:
+		if len(events) != 2 {
+			t.Errorf("drained %v, want both updates", events)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("consumer did not stop after the queue was closed")
+	}
+
+	// Next keeps reporting a closed, empty queue
+	if _, ok := q.Next(); ok {
+		t.Error("Next returned an event after draining")
+	}
+}
+
+func TestMergePipelineStats(t *testing.T) {
+	prices := NewConflatingQueue(priceKey)
+	books := NewConflatingQueue(orderbookKey)
+	trades := NewTradeQueue(1)
+
+	prices.Put(exchanges.PriceData{Symbol: "BTCUSDT", Source: "pyth"})
+	books.Put(exchanges.OrderbookData{Symbol: "BTCUSDT", Source: "okx_futures"})
+	books.Put(exchanges.OrderbookData{Symbol: "BTCUSDT", Source: "okx_futures"})
+	trades.Put(exchanges.TradeData{Symbol: "BTCUSDT", Source: "okx_futures"})
+	trades.Put(exchanges.TradeData{Symbol: "BTCUSDT", Source: "okx_futures"})
+
+	want := []PipelineStats{
+		{Source: "okx_futures", Received: 4, Coalesced: 1, Dropped: 1},
+		{Source: "pyth", Received: 1},
+	}
+	if got := mergePipelineStats(&prices.eventQueue, &books.eventQueue, &trades.eventQueue); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"futures-arbitrage-scanner/exchanges"
+
+	"github.com/gorilla/websocket"
+)
+
+// testClient connects a browser client to the scanner's websocket handler
+// and returns the messages it receives
+func testClient(t *testing.T, scanner *FuturesScanner) <-chan testMessage {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(scanner.handleWebSocket))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	messages := make(chan testMessage, 100)
+	go func() {
+		defer close(messages)
+		for {
+			var message testMessage
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			messages <- message
+		}
+	}()
+
+	// The handler registers the client after the upgrade returns
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		scanner.clientsMutex.RLock()
+		registered := len(scanner.wsClients) > 0
+		scanner.clientsMutex.RUnlock()
+		if registered {
+			return messages
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("websocket client was not registered")
+		}
+	}
+}
+
+type testMessage struct {
+	Type   string `json:"type"`
+	Symbol string `json:"symbol"`
+	// Prices is per source in spreads messages and per symbol and source in
+	// prices messages
+	Prices      json.RawMessage         `json:"prices"`
+	Contracts   map[string]contractInfo `json:"contracts"`
+	Opportunity *ArbitrageOpportunity   `json:"opportunity"`
+}
+
+// spreadSources returns the sources in a spreads message's matrix
+func spreadSources(t *testing.T, message testMessage) map[string]exchanges.Decimal {
+	t.Helper()
+	var prices map[string]exchanges.Decimal
+	if err := json.Unmarshal(message.Prices, &prices); err != nil {
+		t.Fatalf("spreads prices: %v", err)
+	}
+	return prices
+}
+
+func hasSource(prices map[string]exchanges.Decimal, source string) bool {
+	_, ok := prices[source]
+	return ok
+}
+
+// receive collects the messages the client gets within wait
+func receive(messages <-chan testMessage, wait time.Duration) []testMessage {
+	timeout := time.After(wait)
+	var received []testMessage
+	for {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				return received
+			}
+			received = append(received, message)
+		case <-timeout:
+			return received
+		}
+	}
+}
+
+func testPrice(t *testing.T, s string) exchanges.Decimal {
+	t.Helper()
+	price, err := exchanges.ParseDecimal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return price
+}
+
+func opportunities(messages []testMessage) []ArbitrageOpportunity {
+	var found []ArbitrageOpportunity
+	for _, message := range messages {
+		if message.Type == "arbitrage" {
+			found = append(found, *message.Opportunity)
+		}
+	}
+	return found
+}
+
+// newTestScanner returns a scanner that alerts on every gap and broadcasts
+// prices every few milliseconds, with its feed state listener registered
+func newTestScanner(t *testing.T) *FuturesScanner {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Thresholds.AlertCooldown = 0
+	cfg.Broadcast.PricesInterval = Duration(10 * time.Millisecond)
+	scanner := NewFuturesScanner(cfg)
+	exchanges.OnStateChange(scanner.feedStateChanged)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go scanner.broadcastPrices(ctx)
+	t.Cleanup(func() {
+		cancel()
+		scanner.closeShards()
+	})
+	return scanner
+}
+
+// sendPrice routes a price the way the connectors' updates are routed
+func sendPrice(t *testing.T, scanner *FuturesScanner, symbol, source, price string) {
+	t.Helper()
+	scanner.route(marketEvent{price: exchanges.PriceData{Symbol: symbol, Source: source, Price: testPrice(t, price)}})
+}
+
+func TestFeedDownDropsPrices(t *testing.T) {
+	scanner := newTestScanner(t)
+	client := testClient(t, scanner)
+
+	sup := exchanges.NewSupervisor("test_down_feed")
+	sup.Subscribed()
+	sup.Message()
+
+	sendPrice(t, scanner, "BTCUSDT", "test_down_feed", "101")
+	sendPrice(t, scanner, "BTCUSDT", "test_other_feed", "100")
+	if found := opportunities(receive(client, 100*time.Millisecond)); len(found) != 1 {
+		t.Fatalf("got %d opportunities with both feeds up, want 1", len(found))
+	}
+
+	// The connection drops and the connector backs off
+	sup.Disconnected(errors.New("connection reset"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sup.Backoff(ctx)
+
+	messages := receive(client, 100*time.Millisecond)
+	var redrawn bool
+	for _, message := range messages {
+		if message.Type == "spreads" && message.Symbol == "BTCUSDT" {
+			redrawn = !hasSource(spreadSources(t, message), "test_down_feed")
+		}
+	}
+	if !redrawn {
+		t.Error("spread matrix was not redrawn without the down feed")
+	}
+
+	// An update still in flight from before the disconnect and fresh
+	// prices on the other feed must not produce alerts
+	sendPrice(t, scanner, "BTCUSDT", "test_down_feed", "105")
+	sendPrice(t, scanner, "BTCUSDT", "test_other_feed", "100")
+	if found := opportunities(receive(client, 100*time.Millisecond)); len(found) != 0 {
+		t.Errorf("down feed produced opportunities: %+v", found)
+	}
+	if prices, _ := scanner.shardFor("BTCUSDT").Snapshot(); hasSource(prices, "test_down_feed") {
+		t.Errorf("down feed's price was kept: %v", prices)
+	}
+
+	// Once the feed streams again its prices count
+	sup.Message()
+	sendPrice(t, scanner, "BTCUSDT", "test_down_feed", "102")
+	if found := opportunities(receive(client, 100*time.Millisecond)); len(found) != 1 {
+		t.Errorf("got %d opportunities after the feed recovered, want 1", len(found))
+	}
+}
+
+func TestStaleFeedStopsOpportunities(t *testing.T) {
+	scanner := newTestScanner(t)
+	client := testClient(t, scanner)
+
+	sup := exchanges.NewSupervisor("test_stale_feed")
+	sup.Subscribed()
+	sup.Message()
+
+	sendPrice(t, scanner, "ETHUSDT", "test_stale_feed", "2020")
+	sendPrice(t, scanner, "ETHUSDT", "test_fresh_feed", "2000")
+	if found := opportunities(receive(client, 100*time.Millisecond)); len(found) != 1 {
+		t.Fatalf("got %d opportunities before the feed went stale, want 1", len(found))
+	}
+
+	// The watchdog fires; the other feed keeps ticking against the
+	// stale feed's last price
+	sup.Stale()
+	for _, price := range []string{"2000", "1990", "2010"} {
+		sendPrice(t, scanner, "ETHUSDT", "test_fresh_feed", price)
+		time.Sleep(5 * time.Millisecond)
+	}
+	messages := receive(client, 100*time.Millisecond)
+	if found := opportunities(messages); len(found) != 0 {
+		t.Errorf("stale feed produced opportunities: %+v", found)
+	}
+	for _, message := range messages {
+		if message.Type == "spreads" && hasSource(spreadSources(t, message), "test_stale_feed") {
+			t.Errorf("stale feed still in the spread matrix: %s", message.Prices)
+		}
+	}
+	if prices, _ := scanner.shardFor("ETHUSDT").Snapshot(); hasSource(prices, "test_stale_feed") {
+		t.Errorf("stale feed's price was kept: %v", prices)
+	}
+}
+
+func TestDatedFuturesOnlyCompareSameExpiry(t *testing.T) {
+	scanner := newTestScanner(t)
+	client := testClient(t, scanner)
+
+	const expiry = 1798185600000 // 2026-12-25 08:00 UTC
+	for _, venue := range []string{"test_dated_a", "test_dated_b"} {
+		exchanges.Instruments.RegisterVenue(venue, exchanges.VenueSpec{
+			Quote:    "USDT",
+			Contract: exchanges.ContractFuture,
+			Format: func(base, quote string) string {
+				return base + quote + "_261225"
+			},
+		})
+		exchanges.Instruments.SetSpec(venue, exchanges.InstrumentSpec{NativeID: "SOLUSDT_261225", Expiry: expiry})
+	}
+
+	// The quarterly trades at a premium to the perp, which is basis rather
+	// than an opportunity
+	sendPrice(t, scanner, "SOLUSDT", "test_dated_a", "155")
+	sendPrice(t, scanner, "SOLUSDT", "test_perp", "150")
+	messages := receive(client, 100*time.Millisecond)
+	if found := opportunities(messages); len(found) != 0 {
+		t.Errorf("dated future compared with a perpetual: %+v", found)
+	}
+
+	var contracts map[string]contractInfo
+	for _, message := range messages {
+		if message.Type == "spreads" && message.Symbol == "SOLUSDT" {
+			contracts = message.Contracts
+		}
+	}
+	if want := (contractInfo{Kind: "dated", Expiry: expiry}); contracts["test_dated_a"] != want {
+		t.Errorf("spreads contract = %+v, want %+v", contracts["test_dated_a"], want)
+	}
+	if _, ok := contracts["test_perp"]; ok {
+		t.Errorf("linear perpetual listed in contracts: %+v", contracts)
+	}
+
+	// Another future delivering at the same time is comparable
+	sendPrice(t, scanner, "SOLUSDT", "test_dated_b", "157")
+	found := opportunities(receive(client, 100*time.Millisecond))
+	if len(found) != 1 {
+		t.Fatalf("got %d opportunities between same expiry futures, want 1", len(found))
+	}
+	opportunity := found[0]
+	if opportunity.BuySource != "test_dated_a" || opportunity.SellSource != "test_dated_b" {
+		t.Errorf("opportunity = buy %s sell %s, want buy test_dated_a sell test_dated_b", opportunity.BuySource, opportunity.SellSource)
+	}
+	if opportunity.BuyKind != "dated" || opportunity.SellKind != "dated" || opportunity.Expiry != expiry {
+		t.Errorf("opportunity contract = %s/%s expiry %d, want dated/dated expiry %d", opportunity.BuyKind, opportunity.SellKind, opportunity.Expiry, expiry)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"futures-arbitrage-scanner/exchanges"
+)
+
+// marketEvent is a price or book update queued for a symbol shard. An evict
+// event carries only the symbol and source in price and tells the shard to
+// forget that source.
+type marketEvent struct {
+	price  exchanges.PriceData
+	book   exchanges.OrderbookData
+	isBook bool
+	evict  bool
+}
+
+func marketEventKey(event marketEvent) eventKey {
+	if event.isBook {
+		return orderbookKey(event.book)
+	}
+	return priceKey(event.price)
+}
+
+// symbolShard owns the state of one symbol. Its worker is the only goroutine
+// that writes prices, books and lastOpportunity, so symbols are processed in
+// parallel without sharing a lock.
+type symbolShard struct {
+	symbol          string
+	scanner         *FuturesScanner
+	queue           *ConflatingQueue[marketEvent]
+	prices          map[string]exchanges.Decimal
+	books           map[string]exchanges.OrderbookData // Latest top of book per source
+	lastOpportunity map[string]time.Time               // Last alert per buy/sell pair
+	// contracts caches each source's contract until its feed goes down, so
+	// a dated future picks up its new expiry when it rolls and reconnects
+	contracts map[string]contractInfo
+	// pricesMutex guards prices against Snapshot. Only this shard's worker
+	// and the periodic broadcaster take it, so it is never contended
+	// across symbols.
+	pricesMutex sync.Mutex
+	changed     bool // prices moved since the last Snapshot
+}
+
+func newSymbolShard(scanner *FuturesScanner, symbol string) *symbolShard {
+	return &symbolShard{
+		symbol:          symbol,
+		scanner:         scanner,
+		queue:           NewConflatingQueue(marketEventKey),
+		prices:          make(map[string]exchanges.Decimal),
+		books:           make(map[string]exchanges.OrderbookData),
+		lastOpportunity: make(map[string]time.Time),
+		contracts:       make(map[string]contractInfo),
+	}
+}
+
+// run processes the shard's updates until its queue is closed and drained
+func (sh *symbolShard) run() {
+	for {
+		event, ok := sh.queue.Next()
+		if !ok {
+			return
+		}
+		sh.process(event)
+	}
+}
+
+// process applies one price or book update; only the worker calls it
+func (sh *symbolShard) process(event marketEvent) {
+	if event.evict {
+		sh.evict(event.price.Source)
+		return
+	}
+	if !event.isBook {
+		if latency, ok := event.price.Latency(); ok {
+			sh.scanner.latency.Observe(event.price.Source, latency)
+		}
+		sh.updatePrice(event.price)
+		return
+	}
+
+	book := event.book
+	if latency, ok := book.Latency(); ok {
+		sh.scanner.latency.Observe(book.Source, latency)
+	}
+
+	// Keep the book so opportunities can report the size behind a gap
+	sh.books[book.Source] = book
+
+	// Calculate mid price from best bid and best ask
+	sh.updatePrice(exchanges.PriceData{
+		Symbol:     book.Symbol,
+		Source:     book.Source,
+		Price:      book.BestBid.Mid(book.BestAsk),
+		Timestamp:  book.Timestamp,
+		ReceivedAt: book.ReceivedAt,
+	})
+}
+
+// updatePrice records a source's price and checks the symbol for a gap. The
+// map isn't copied here; readers outside the worker copy it in Snapshot.
+func (sh *symbolShard) updatePrice(data exchanges.PriceData) {
+	sh.pricesMutex.Lock()
+	sh.prices[data.Source] = data.Price
+	sh.changed = true
+	sh.pricesMutex.Unlock()
+
+	// The worker is the only writer, so it can read the map unlocked
+	sh.checkArbitrage(sh.prices)
+}
+
+// contract returns a source's contract; only the worker calls it
+func (sh *symbolShard) contract(source string) contractInfo {
+	contract, ok := sh.contracts[source]
+	if !ok {
+		contract = lookupContract(source, sh.symbol)
+		sh.contracts[source] = contract
+	}
+	return contract
+}
+
+// evict drops the price and book of a source whose feed went down. The
+// symbol counts as changed so its spread matrix is redrawn without it.
+func (sh *symbolShard) evict(source string) {
+	delete(sh.books, source)
+	delete(sh.contracts, source)
+
+	sh.pricesMutex.Lock()
+	if _, ok := sh.prices[source]; ok {
+		delete(sh.prices, source)
+		sh.changed = true
+	}
+	sh.pricesMutex.Unlock()
+}
+
+// Snapshot returns a copy of the latest price per source and whether any
+// moved since the previous call; safe from any goroutine
+func (sh *symbolShard) Snapshot() (prices map[string]exchanges.Decimal, changed bool) {
+	sh.pricesMutex.Lock()
+	defer sh.pricesMutex.Unlock()
+
+	prices = make(map[string]exchanges.Decimal, len(sh.prices))
+	for source, price := range sh.prices {
+		prices[source] = price
+	}
+	changed = sh.changed
+	sh.changed = false
+	return prices, changed
+}
+
+// shardFor returns the shard of a symbol, starting its worker on first use
+func (s *FuturesScanner) shardFor(symbol string) *symbolShard {
+	s.shardsMutex.RLock()
+	shard, ok := s.shards[symbol]
+	s.shardsMutex.RUnlock()
+	if ok {
+		return shard
+	}
+
+	s.shardsMutex.Lock()
+	defer s.shardsMutex.Unlock()
+	if shard, ok := s.shards[symbol]; ok {
+		return shard
+	}
+	shard = newSymbolShard(s, symbol)
+	s.shards[symbol] = shard
+	s.shardWorkers.Add(1)
+	go func() {
+		defer s.shardWorkers.Done()
+		shard.run()
+	}()
+	return shard
+}
+
+// allShards returns every shard started so far
+func (s *FuturesScanner) allShards() []*symbolShard {
+	s.shardsMutex.RLock()
+	defer s.shardsMutex.RUnlock()
+
+	shards := make([]*symbolShard, 0, len(s.shards))
+	for _, shard := range s.shards {
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+// routePrices hands oracle prices from the connectors to their symbol's shard
+func (s *FuturesScanner) routePrices() {
+	for data := range s.priceChan {
+		s.route(marketEvent{price: data})
+	}
+}
+
+// routeOrderbooks hands book updates from the connectors to their symbol's shard
+func (s *FuturesScanner) routeOrderbooks() {
+	for data := range s.orderbookChan {
+		s.route(marketEvent{book: data, isBook: true})
+	}
+}
+
+// route queues an update on its symbol's shard. Updates still in flight from
+// a feed that has gone down are stale and dropped; the check and the Put
+// share the read lock so none can slip in behind an eviction.
+func (s *FuturesScanner) route(event marketEvent) {
+	key := marketEventKey(event)
+
+	s.downMutex.RLock()
+	defer s.downMutex.RUnlock()
+	if s.downSources[key.source] {
+		return
+	}
+	s.shardFor(key.symbol).queue.Put(event)
+}
+
+// feedStateChanged has every shard drop a feed's price and book as soon as
+// its supervisor leaves the streaming state, so a venue shown as down can't
+// take part in spreads or alerts, and ignores its updates until it streams
+// again
+func (s *FuturesScanner) feedStateChanged(source string, state exchanges.ConnState) {
+	s.downMutex.Lock()
+	defer s.downMutex.Unlock()
+
+	if state == exchanges.StateStreaming {
+		delete(s.downSources, source)
+		return
+	}
+	if s.downSources[source] {
+		return
+	}
+	s.downSources[source] = true
+
+	// An eviction replaces any update from the feed still waiting in the
+	// queue and is processed after the ones already taken
+	for _, shard := range s.allShards() {
+		shard.queue.Put(marketEvent{
+			price: exchanges.PriceData{Symbol: shard.symbol, Source: source},
+			evict: true,
+		})
+	}
+}
+
+// closeShards stops every shard once its queue is drained and waits for the
+// workers to return. The routers must have stopped first.
+func (s *FuturesScanner) closeShards() {
+	for _, shard := range s.allShards() {
+		shard.queue.Close()
+	}
+	s.shardWorkers.Wait()
+}
+
+// pipelineStats sums the queue counters of every shard and the trade queue
+func (s *FuturesScanner) pipelineStats() []PipelineStats {
+	queues := []*eventQueue{&s.tradeQueue.eventQueue}
+	for _, shard := range s.allShards() {
+		queues = append(queues, &shard.queue.eventQueue)
+	}
+	return mergePipelineStats(queues...)
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"futures-arbitrage-scanner/exchanges"
+
+	"github.com/gorilla/websocket"
+)
+
+var benchmarkSources = []string{
+	"binance_futures", "bybit_futures", "okx_futures", "gate_futures",
+	"bitget_futures", "kraken_futures", "hyperliquid_futures", "paradex_futures",
+	"binance_spot", "bybit_spot", "okx_spot", "coinbase_spot",
+}
+
+// benchmarkBooks returns a cycle of book updates for one symbol across the
+// benchmark sources with slightly different prices. Every run of
+// len(benchmarkSources) updates has one update per source.
+func benchmarkBooks(symbol string) []exchanges.OrderbookData {
+	now := time.Now()
+	var books []exchanges.OrderbookData
+	for i := 0; i < 5*len(benchmarkSources); i++ {
+		source := benchmarkSources[i%len(benchmarkSources)]
+		bid, _ := exchanges.NewDecimal(int64(6_700_000+i%13), -2)
+		ask, _ := exchanges.NewDecimal(int64(6_700_001+i%13), -2)
+		books = append(books, exchanges.OrderbookData{
+			Symbol:      symbol,
+			Source:      source,
+			BestBid:     bid,
+			BestAsk:     ask,
+			BestBidSize: 1,
+			BestAskSize: 1,
+			Timestamp:   now.UnixMilli(),
+			ReceivedAt:  now,
+		})
+	}
+	return books
+}
+
+func newBenchmarkScanner() *FuturesScanner {
+	cfg := DefaultConfig()
+	// Keep opportunity alerts out of the measurement
+	cfg.Thresholds.MinProfitPct = 100
+	return NewFuturesScanner(cfg)
+}
+
+// singleLockScanner reproduces the processing before sharding: every update
+// takes one mutex over all symbols, copies the symbol's prices and rebuilds
+// and encodes the full spread matrix under the client write lock.
+type singleLockScanner struct {
+	scanner     *FuturesScanner
+	pricesMutex sync.Mutex
+	prices      map[string]map[string]exchanges.Decimal
+	writeMutex  sync.Mutex
+	client      io.Writer
+}
+
+func newSingleLockScanner() *singleLockScanner {
+	return &singleLockScanner{
+		scanner: newBenchmarkScanner(),
+		prices:  make(map[string]map[string]exchanges.Decimal),
+		client:  io.Discard,
+	}
+}
+
+func (s *singleLockScanner) process(book exchanges.OrderbookData) {
+	if latency, ok := book.Latency(); ok {
+		s.scanner.latency.Observe(book.Source, latency)
+	}
+
+	s.pricesMutex.Lock()
+	symbolPrices := s.prices[book.Symbol]
+	if symbolPrices == nil {
+		symbolPrices = make(map[string]exchanges.Decimal)
+		s.prices[book.Symbol] = symbolPrices
+	}
+	symbolPrices[book.Source] = book.BestBid.Mid(book.BestAsk)
+	prices := make(map[string]exchanges.Decimal, len(symbolPrices))
+	for source, price := range symbolPrices {
+		prices[source] = price
+	}
+	s.pricesMutex.Unlock()
+
+	if len(prices) < 2 {
+		return
+	}
+	spreads := make(map[string]map[string]float64)
+	for buySource, buyPrice := range prices {
+		spreads[buySource] = make(map[string]float64)
+		for sellSource, sellPrice := range prices {
+			if buySource != sellSource {
+				spreads[buySource][sellSource] = spreadPct(buyPrice, sellPrice)
+			}
+		}
+	}
+
+	s.writeMutex.Lock()
+	json.NewEncoder(s.client).Encode(map[string]interface{}{
+		"type":    "spreads",
+		"symbol":  book.Symbol,
+		"spreads": spreads,
+		"prices":  prices,
+	})
+	s.writeMutex.Unlock()
+}
+
+// benchmarkDesign is a scanner fed the way the connectors feed it: put hands
+// over one book update without blocking, idle reports that none of a
+// symbol's updates is still waiting for a worker, and drain closes the
+// queues, waits for the workers to finish and returns the queue counters
+type benchmarkDesign struct {
+	put   func(book exchanges.OrderbookData)
+	idle  func(symbol string) bool
+	drain func() []PipelineStats
+}
+
+// newSingleLockDesign queues updates in one conflating queue drained by a
+// single processor, as the scanner did before sharding
+func newSingleLockDesign(symbols []string) benchmarkDesign {
+	scanner := newSingleLockScanner()
+	queue := NewConflatingQueue(orderbookKey)
+	waiting := make(map[string]*atomic.Int64, len(symbols))
+	for _, symbol := range symbols {
+		waiting[symbol] = new(atomic.Int64)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			book, ok := queue.Next()
+			if !ok {
+				return
+			}
+			waiting[book.Symbol].Add(-1)
+			scanner.process(book)
+		}
+	}()
+
+	return benchmarkDesign{
+		put: func(book exchanges.OrderbookData) {
+			waiting[book.Symbol].Add(1)
+			queue.Put(book)
+		},
+		idle: func(symbol string) bool {
+			return waiting[symbol].Load() == 0
+		},
+		drain: func() []PipelineStats {
+			queue.Close()
+			<-done
+			return mergePipelineStats(&queue.eventQueue)
+		},
+	}
+}
+
+// newShardedDesign routes updates to the symbol shards like routeOrderbooks,
+// with the periodic broadcaster running and a browser client keeping up
+func newShardedDesign() benchmarkDesign {
+	scanner := newBenchmarkScanner()
+	client := &wsClient{send: make(chan []byte, clientSendBuffer)}
+	scanner.wsClients[client] = true
+	go func() {
+		for range client.send {
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	go scanner.broadcastPrices(ctx)
+
+	return benchmarkDesign{
+		put: func(book exchanges.OrderbookData) {
+			scanner.route(marketEvent{book: book, isBook: true})
+		},
+		idle: func(symbol string) bool {
+			queue := scanner.shardFor(symbol).queue
+			queue.mu.Lock()
+			defer queue.mu.Unlock()
+			return len(queue.order) == 0
+		},
+		drain: func() []PipelineStats {
+			scanner.closeShards()
+			cancel()
+			scanner.removeClient(client)
+			return scanner.pipelineStats()
+		},
+	}
+}
+
+var benchmarkSymbolCounts = []int{1, 4, 16, 64}
+
+func benchmarkSymbols(count int) []string {
+	symbols := make([]string, count)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("SYM%dUSDT", i)
+	}
+	return symbols
+}
+
+// runPerSymbol splits b.N updates over one producer per symbol and stops the
+// clock once the design has drained, so ns/op is the wall time per update
+// with every symbol busy. Each producer puts one update per source and then
+// waits for its symbol to be picked up, like feeds that never outrun the
+// scanner, so nothing is coalesced and both designs do every update.
+func runPerSymbol(b *testing.B, symbols []string, design benchmarkDesign) {
+	books := make([][]exchanges.OrderbookData, len(symbols))
+	for i, symbol := range symbols {
+		books[i] = benchmarkBooks(symbol)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var wg sync.WaitGroup
+	for i, symbol := range symbols {
+		updates := b.N / len(symbols)
+		if i < b.N%len(symbols) {
+			updates++
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cycle := books[i]
+			for n := 0; n < updates; n++ {
+				design.put(cycle[n%len(cycle)])
+				if (n+1)%len(benchmarkSources) == 0 {
+					for !design.idle(symbol) {
+						runtime.Gosched()
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	stats := design.drain()
+	b.StopTimer()
+
+	var coalesced uint64
+	for _, source := range stats {
+		coalesced += source.Coalesced
+	}
+	if coalesced > 0 {
+		b.Fatalf("%d updates coalesced; the designs must process every update", coalesced)
+	}
+}
+
+// BenchmarkScannerUpdate compares the per update cost of the sharded scanner
+// with the single lock design as the number of busy symbols grows. Both are
+// fed through a non-blocking Put and timed until their workers have drained.
+func BenchmarkScannerUpdate(b *testing.B) {
+	for _, count := range benchmarkSymbolCounts {
+		symbols := benchmarkSymbols(count)
+
+		b.Run(fmt.Sprintf("single_lock/symbols=%d", count), func(b *testing.B) {
+			runPerSymbol(b, symbols, newSingleLockDesign(symbols))
+		})
+
+		b.Run(fmt.Sprintf("sharded/symbols=%d", count), func(b *testing.B) {
+			runPerSymbol(b, symbols, newShardedDesign())
+		})
+	}
+}
+
+func TestShardSnapshot(t *testing.T) {
+	scanner := newBenchmarkScanner()
+	shard := newSymbolShard(scanner, "BTCUSDT")
+
+	if prices, changed := shard.Snapshot(); len(prices) != 0 || changed {
+		t.Fatalf("empty shard Snapshot = %v, %v", prices, changed)
+	}
+
+	for _, book := range benchmarkBooks("BTCUSDT")[:3] {
+		shard.process(marketEvent{book: book, isBook: true})
+	}
+	prices, changed := shard.Snapshot()
+	if !changed || len(prices) != 3 {
+		t.Fatalf("Snapshot = %v, %v; want 3 prices, changed", prices, changed)
+	}
+
+	// The copy is the caller's; later updates don't touch it
+	prices["binance_futures"] = exchanges.Decimal{}
+	if _, changed := shard.Snapshot(); changed {
+		t.Error("Snapshot reported a change without an update")
+	}
+	if again, _ := shard.Snapshot(); again["binance_futures"].IsZero() {
+		t.Error("Snapshot returned the shard's own map")
+	}
+}
+
+func TestBroadcastDropsSlowClient(t *testing.T) {
+	scanner := newBenchmarkScanner()
+
+	serverConns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := scanner.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		serverConns <- conn
+	}))
+	defer server.Close()
+
+	browser, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer browser.Close()
+
+	// Neither client has a writer, so the slow one's full queue never drains
+	fast := &wsClient{send: make(chan []byte, clientSendBuffer)}
+	slow := &wsClient{conn: <-serverConns, send: make(chan []byte, clientSendBuffer)}
+	for i := 0; i < clientSendBuffer; i++ {
+		slow.send <- nil
+	}
+	scanner.wsClients[fast] = true
+	scanner.wsClients[slow] = true
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner.broadcast(map[string]string{"type": "status"})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("broadcast blocked on a full client queue")
+	}
+
+	if len(fast.send) != 1 {
+		t.Errorf("fast client queued %d messages, want 1", len(fast.send))
+	}
+	if scanner.wsClients[slow] {
+		t.Error("slow client still registered")
+	}
+	browser.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := browser.ReadMessage(); err == nil {
+		t.Error("slow client connection still open")
+	}
+}
+
+func TestShardEvict(t *testing.T) {
+	scanner := newBenchmarkScanner()
+	shard := newSymbolShard(scanner, "BTCUSDT")
+
+	for _, book := range benchmarkBooks("BTCUSDT")[:3] {
+		shard.process(marketEvent{book: book, isBook: true})
+	}
+	shard.Snapshot()
+
+	evict := func(source string) {
+		shard.process(marketEvent{price: exchanges.PriceData{Symbol: "BTCUSDT", Source: source}, evict: true})
+	}
+
+	evict("bybit_futures")
+	prices, changed := shard.Snapshot()
+	if !changed {
+		t.Error("eviction did not mark the shard changed")
+	}
+	if _, ok := prices["bybit_futures"]; ok || len(prices) != 2 {
+		t.Errorf("prices after eviction = %v", prices)
+	}
+	if _, ok := shard.books["bybit_futures"]; ok {
+		t.Error("evicted source's book was kept")
+	}
+
+	// Evicting a source the shard never saw isn't a change
+	evict("kraken_futures")
+	if _, changed := shard.Snapshot(); changed {
+		t.Error("evicting an unknown source marked the shard changed")
+	}
+}
+
+func TestFeedStateChangedEvictsQueuedUpdates(t *testing.T) {
+	scanner := newBenchmarkScanner()
+	books := benchmarkBooks("BTCUSDT")[:2]
+	for _, book := range books {
+		scanner.route(marketEvent{book: book, isBook: true})
+	}
+	shard := scanner.shardFor("BTCUSDT")
+
+	// The feed goes down; whatever it had queued or still has in flight
+	// must not come back once the eviction is processed
+	scanner.feedStateChanged("bybit_futures", exchanges.StateBackoff)
+	scanner.route(marketEvent{book: books[1], isBook: true})
+	scanner.closeShards()
+
+	prices, _ := shard.Snapshot()
+	if _, ok := prices["bybit_futures"]; ok {
+		t.Errorf("down feed's price survived: %v", prices)
+	}
+	if _, ok := prices["binance_futures"]; !ok {
+		t.Errorf("other feed's price was dropped: %v", prices)
+	}
+
+	// Streaming again lets its updates through
+	scanner.feedStateChanged("bybit_futures", exchanges.StateStreaming)
+	scanner.route(marketEvent{book: books[1], isBook: true})
+	shard = scanner.shardFor("BTCUSDT")
+	if event, ok := shard.queue.Next(); !ok || event.book.Source != "bybit_futures" {
+		t.Errorf("update after recovery was not routed: %+v", event)
+	}
+}
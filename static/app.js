@@ -8,6 +8,10 @@ class FuturesArbitrageScanner {
         this.maxHistoryPoints = 500; // Reduced from 1000
         this.maxOpportunities = 25; // Reduced from 50
         this.connectedSources = new Set();
+        this.feedStatuses = [];
+        this.feedLatency = {};
+        this.feedPipeline = {};
+        this.tradeFlow = {};
         this.currentSort = { field: 'timestamp', direction: 'desc' };
         this.minProfitFilter = 0.05;
         
@@ -245,8 +249,24 @@ class FuturesArbitrageScanner {
             { key: 'okx_futures', label: 'OKX Futures', color: '#1890ff', lineStyle: LightweightCharts.LineStyle.Solid },
             { key: 'gate_futures', label: 'Gate.io Futures', color: '#6c5ce7', lineStyle: LightweightCharts.LineStyle.Solid },
             { key: 'paradex_futures', label: 'Paradex Futures', color: '#ff6b6b', lineStyle: LightweightCharts.LineStyle.Solid },
+            { key: 'deribit_futures', label: 'Deribit Futures', color: '#00cfbe', lineStyle: LightweightCharts.LineStyle.Solid },
+            { key: 'bitget_futures', label: 'Bitget Futures', color: '#00f0ff', lineStyle: LightweightCharts.LineStyle.Solid },
+            { key: 'kucoin_futures', label: 'KuCoin Futures', color: '#23af91', lineStyle: LightweightCharts.LineStyle.Solid },
+            { key: 'dydx_futures', label: 'dYdX Futures', color: '#6966ff', lineStyle: LightweightCharts.LineStyle.Solid },
+            { key: 'htx_futures', label: 'HTX Futures', color: '#2b6def', lineStyle: LightweightCharts.LineStyle.Solid },
+            { key: 'bitmex_futures', label: 'BitMEX Futures', color: '#ff5b5b', lineStyle: LightweightCharts.LineStyle.Solid },
+            { key: 'bitfinex_futures', label: 'Bitfinex Futures', color: '#16b157', lineStyle: LightweightCharts.LineStyle.Solid },
+            { key: 'binance_coinm_futures', label: 'Binance COIN-M', color: '#e6a23c', lineStyle: LightweightCharts.LineStyle.Solid },
+            { key: 'binance_quarterly_futures', label: 'Binance Quarterly', color: '#d4a017', lineStyle: LightweightCharts.LineStyle.Solid },
+            { key: 'binance_coinm_quarterly_futures', label: 'Binance COIN-M Quarterly', color: '#b8860b', lineStyle: LightweightCharts.LineStyle.Solid },
             { key: 'binance_spot', label: 'Binance Spot', color: '#f0b90b', lineStyle: LightweightCharts.LineStyle.Dashed },
             { key: 'bybit_spot', label: 'Bybit Spot', color: '#f7931a', lineStyle: LightweightCharts.LineStyle.Dashed },
+            { key: 'bitget_spot', label: 'Bitget Spot', color: '#00f0ff', lineStyle: LightweightCharts.LineStyle.Dashed },
+            { key: 'bitfinex_spot', label: 'Bitfinex Spot', color: '#16b157', lineStyle: LightweightCharts.LineStyle.Dashed },
+            { key: 'coinbase_spot', label: 'Coinbase Spot', color: '#0052ff', lineStyle: LightweightCharts.LineStyle.Dashed },
+            { key: 'okx_spot', label: 'OKX Spot', color: '#c8c8c8', lineStyle: LightweightCharts.LineStyle.Dashed },
+            { key: 'gate_spot', label: 'Gate.io Spot', color: '#e040fb', lineStyle: LightweightCharts.LineStyle.Dashed },
+            { key: 'kraken_spot', label: 'Kraken Spot', color: '#8d5ff0', lineStyle: LightweightCharts.LineStyle.Dashed },
             { key: 'pyth', label: 'Pyth Oracle', color: '#00ff88', lineStyle: LightweightCharts.LineStyle.Dotted },
         ];
 
@@ -413,8 +433,9 @@ class FuturesArbitrageScanner {
     handleBatchedPriceUpdates(priceUpdates) {
         priceUpdates.forEach(data => {
             if (data.symbol === this.currentSymbol) {
-                this.updateSourcePrice(data.source, data.price);
-                this.addPriceToHistory(data.source, data.price, data.timestamp);
+                const price = Number(data.price);
+                this.updateSourcePrice(data.source, price);
+                this.addPriceToHistory(data.source, price, data.timestamp);
             }
         });
     }
@@ -428,13 +449,114 @@ class FuturesArbitrageScanner {
             this.handleArbitrageOpportunity(data.opportunity);
         } else if (data.type === 'spreads') {
             this.handleSpreadsUpdate(data);
+        } else if (data.type === 'status') {
+            this.handleStatusUpdate(data.statuses, data.latency, data.pipeline);
+        } else if (data.type === 'trade_flow') {
+            this.tradeFlow = data.flow || {};
+            this.updateTradeFlow();
         }
     }
 
+    handleStatusUpdate(statuses, latency, pipeline) {
+        this.feedStatuses = statuses || [];
+        this.feedLatency = {};
+        for (const stats of latency || []) {
+            this.feedLatency[stats.source] = stats;
+        }
+        this.feedPipeline = {};
+        for (const stats of pipeline || []) {
+            this.feedPipeline[stats.source] = stats;
+        }
+        this.updateFeedStatus();
+    }
+
+    updateFeedStatus() {
+        const container = document.getElementById('feedStatus');
+        if (!container) return;
+
+        if (this.feedStatuses.length === 0) {
+            container.innerHTML = '<div class="loading">No feeds running</div>';
+            return;
+        }
+
+        let html = '';
+        for (const status of this.feedStatuses) {
+            const notes = [];
+            if (status.last_error) notes.push(`Last error: ${status.last_error}`);
+            if (status.last_resync) notes.push(`Last resync: ${status.last_resync}`);
+            const latency = this.feedLatency[status.source];
+            if (latency) {
+                notes.push(`Latency over ${latency.count} events, max ${latency.max_ms}ms`);
+                if (latency.skewed) notes.push(`${latency.skewed} events stamped ahead of local clock`);
+            }
+            const pipeline = this.feedPipeline[status.source];
+            if (pipeline) {
+                notes.push(`${pipeline.received} events received`);
+                if (pipeline.coalesced) notes.push(`${pipeline.coalesced} updates coalesced while the scanner was busy`);
+                if (pipeline.dropped) notes.push(`${pipeline.dropped} trades dropped`);
+            }
+            const title = notes.join('\n');
+            const resyncs = status.resyncs > 0 ? ` ⟳${status.resyncs}` : '';
+            const dropped = pipeline && pipeline.dropped > 0 ? ` ✕${pipeline.dropped}` : '';
+            const lag = latency ? `<span class="feed-latency">p50 ${latency.p50_ms}ms · p99 ${latency.p99_ms}ms</span>` : '';
+            html += `
+                <div class="feed-status-item" title="${title.replace(/"/g, '&quot;')}">
+                    <div class="source-name">${status.source.replace('_', ' ')}</div>
+                    <div>
+                        <span class="feed-state ${status.state}">${status.state}</span>
+                        <span class="feed-counters">↑${status.connects} ↓${status.disconnects}${resyncs}${dropped}</span>
+                        ${lag}
+                    </div>
+                </div>
+            `;
+        }
+        container.innerHTML = html;
+    }
+
+    updateTradeFlow() {
+        const container = document.getElementById('tradeFlow');
+        if (!container) return;
+
+        const flow = this.tradeFlow[this.currentSymbol] || {};
+        const sources = Object.keys(flow).sort();
+        if (sources.length === 0) {
+            container.innerHTML = '<div class="loading">No trades in the last minute</div>';
+            return;
+        }
+
+        let html = '';
+        for (const source of sources) {
+            const stats = flow[source];
+            const notional = stats.buy_notional + stats.sell_notional;
+            const imbalance = (stats.imbalance * 100).toFixed(0);
+            const imbalanceClass = stats.imbalance >= 0 ? 'positive' : 'negative';
+            const title = `${stats.trades} trades, VWAP $${this.formatPrice(stats.vwap)}` +
+                (stats.unsized ? `\n${stats.unsized} trades without contract size` : '');
+            html += `
+                <div class="feed-status-item" title="${title}">
+                    <div class="source-name">${this.formatSourceName(source)}</div>
+                    <div>
+                        <span class="feed-counters">$${this.formatNotional(notional)}</span>
+                        <span class="trade-imbalance ${imbalanceClass}">${stats.imbalance >= 0 ? '+' : ''}${imbalance}%</span>
+                    </div>
+                </div>
+            `;
+        }
+        container.innerHTML = html;
+    }
+
+    formatNotional(value) {
+        if (value >= 1e6) return (value / 1e6).toFixed(2) + 'M';
+        if (value >= 1e3) return (value / 1e3).toFixed(1) + 'K';
+        return value.toFixed(0);
+    }
+
     updatePrices(prices) {
         for (const [symbol, sourcePrices] of Object.entries(prices)) {
             if (symbol === this.currentSymbol) {
-                for (const [source, price] of Object.entries(sourcePrices)) {
+                // Prices arrive as exact decimal strings
+                for (const [source, value] of Object.entries(sourcePrices)) {
+                    const price = Number(value);
                     this.updateSourcePrice(source, price);
                     this.addPriceToHistory(source, price);
                 }
@@ -446,8 +568,9 @@ class FuturesArbitrageScanner {
 
     handlePriceUpdate(data) {
         if (data.symbol === this.currentSymbol) {
-            this.updateSourcePrice(data.source, data.price);
-            this.addPriceToHistory(data.source, data.price, data.timestamp);
+            const price = Number(data.price);
+            this.updateSourcePrice(data.source, price);
+            this.addPriceToHistory(data.source, price, data.timestamp);
             // UI updates will be handled by processMessageQueue
         }
     }
@@ -542,8 +665,24 @@ class FuturesArbitrageScanner {
             'okx_futures': '#1890ff',
             'gate_futures': '#6c5ce7',
             'paradex_futures': '#ff6b6b',
+            'deribit_futures': '#00cfbe',
+            'bitget_futures': '#00f0ff',
+            'kucoin_futures': '#23af91',
+            'dydx_futures': '#6966ff',
+            'htx_futures': '#2b6def',
+            'bitmex_futures': '#ff5b5b',
+            'bitfinex_futures': '#16b157',
+            'binance_coinm_futures': '#e6a23c',
+            'binance_quarterly_futures': '#d4a017',
+            'binance_coinm_quarterly_futures': '#b8860b',
             'binance_spot': '#ffb347',
             'bybit_spot': '#f7931a',
+            'bitget_spot': '#00f0ff',
+            'bitfinex_spot': '#16b157',
+            'coinbase_spot': '#0052ff',
+            'okx_spot': '#c8c8c8',
+            'gate_spot': '#e040fb',
+            'kraken_spot': '#8d5ff0',
             'pyth': '#00ff88',
         };
 
@@ -611,12 +750,7 @@ class FuturesArbitrageScanner {
     performChartUpdate() {
         if (!this.chart || this.priceHistory.size === 0) return;
 
-        const sourceNames = ['binance_futures', 'bybit_futures', 'hyperliquid_futures', 'kraken_futures', 'okx_futures', 'gate_futures', 'paradex_futures', 'binance_spot', 'bybit_spot', 'pyth'];
-        
-        sourceNames.forEach(source => {
-            const series = this.chartSeries.get(source);
-            if (!series) return;
-
+        this.chartSeries.forEach((series, source) => {
             if (this.isSourceEnabled(source)) {
                 const history = this.priceHistory.get(source) || [];
                 if (history.length > 0) {
@@ -674,6 +808,9 @@ class FuturesArbitrageScanner {
     handleArbitrageOpportunity(opportunity) {
         // Add unique ID for tracking
         opportunity.id = Date.now() + Math.random();
+        // Prices are exact decimal strings on the wire; numbers sort and format
+        opportunity.buy_price = Number(opportunity.buy_price);
+        opportunity.sell_price = Number(opportunity.sell_price);
         
         this.arbitrageOpportunities.unshift(opportunity);
         
@@ -732,7 +869,7 @@ class FuturesArbitrageScanner {
         stats.textContent = `${filteredOpportunities.length} alerts`;
 
         if (sortedOpportunities.length === 0) {
-            tbody.innerHTML = '<tr><td colspan="7" class="opportunities-empty">No alerts match current filters</td></tr>';
+            tbody.innerHTML = '<tr><td colspan="8" class="opportunities-empty">No alerts match current filters</td></tr>';
             return;
         }
 
@@ -747,10 +884,11 @@ class FuturesArbitrageScanner {
                 <tr class="${isRecent ? 'fresh' : ''}" data-id="${opp.id}">
                     <td class="symbol-cell">${opp.symbol}</td>
                     <td class="profit-cell ${profitClass}">${opp.profit_pct.toFixed(3)}%</td>
-                    <td class="source-cell">${this.formatSourceName(opp.buy_source)}</td>
+                    <td class="source-cell">${this.formatSourceName(opp.buy_source)}${this.formatContractKind(opp.buy_kind, opp.expiry)}</td>
                     <td class="price-cell">$${this.formatPrice(opp.buy_price)}</td>
-                    <td class="source-cell">${this.formatSourceName(opp.sell_source)}</td>
+                    <td class="source-cell">${this.formatSourceName(opp.sell_source)}${this.formatContractKind(opp.sell_kind, opp.expiry)}</td>
                     <td class="price-cell">$${this.formatPrice(opp.sell_price)}</td>
+                    <td class="price-cell" title="${this.formatSizeTitle(opp)}">${this.formatSize(opp.size)}</td>
                     <td class="time-cell">${timeStr}</td>
                 </tr>
             `;
@@ -759,6 +897,42 @@ class FuturesArbitrageScanner {
         tbody.innerHTML = html;
     }
 
+    formatSize(size) {
+        // Size is only known when both venues publish top of book quantities
+        if (!size) return '—';
+        if (size >= 1000) return size.toFixed(0);
+        if (size >= 1) return size.toFixed(2);
+        return size.toPrecision(3);
+    }
+
+    formatSizeTitle(opp) {
+        if (opp.buy_kind === 'quanto' || opp.sell_kind === 'quanto') {
+            return 'no size: quanto contracts have no base asset size';
+        }
+        return `buy ${this.formatSize(opp.buy_size)} / sell ${this.formatSize(opp.sell_size)}`;
+    }
+
+    // Inverse and quanto contracts price like linear ones but size
+    // differently, and dated futures include the basis to their delivery, so
+    // they are marked wherever sources are compared
+    formatContractKind(kind, expiry) {
+        if (kind === 'dated') return ` <span class="contract-kind" title="Dated future: ${this.formatExpiry(expiry)}, only compared with the same delivery">DTD</span>`;
+        if (kind === 'inverse') return ' <span class="contract-kind" title="Inverse contract: sized in USD, converted to coins at the quote price">INV</span>';
+        if (kind === 'quanto') return ' <span class="contract-kind" title="Quanto contract: comparable price, no base asset size">QNT</span>';
+        return '';
+    }
+
+    formatExpiry(expiry) {
+        if (!expiry) return 'delivery unknown';
+        return 'delivers ' + new Date(expiry).toISOString().slice(0, 16).replace('T', ' ') + ' UTC';
+    }
+
+    // Title of a spread matrix header for a source that isn't a linear perp
+    formatContractTitle(contract) {
+        if (contract.kind === 'dated') return `dated contract, ${this.formatExpiry(contract.expiry)}`;
+        return `${contract.kind} contract`;
+    }
+
     getProfitClass(profitPct) {
         if (profitPct >= 0.5) return 'high';
         if (profitPct >= 0.2) return 'medium';
@@ -777,6 +951,7 @@ class FuturesArbitrageScanner {
             this.currentSpreads.set(data.symbol, {
                 spreads: data.spreads,
                 prices: data.prices,
+                contracts: data.contracts || {},
                 timestamp: Date.now()
             });
             this.updateSpreadsMatrix();
@@ -821,13 +996,19 @@ class FuturesArbitrageScanner {
         html += '<div class="spread-header"></div>'; // Empty corner
         sources.forEach(sellSource => {
             const shortName = this.getShortSourceName(sellSource);
-            html += `<div class="spread-header">${shortName}</div>`;
+            const contract = spreadData.contracts[sellSource];
+            html += contract
+                ? `<div class="spread-header" title="${this.formatContractTitle(contract)}">${shortName}*</div>`
+                : `<div class="spread-header">${shortName}</div>`;
         });
 
         // Data rows
         sources.forEach(buySource => {
             const shortBuyName = this.getShortSourceName(buySource);
-            html += `<div class="spread-row-header">${shortBuyName}</div>`;
+            const buyContract = spreadData.contracts[buySource];
+            html += buyContract
+                ? `<div class="spread-row-header" title="${this.formatContractTitle(buyContract)}">${shortBuyName}*</div>`
+                : `<div class="spread-row-header">${shortBuyName}</div>`;
             
             sources.forEach(sellSource => {
                 if (buySource === sellSource) {
@@ -859,6 +1040,17 @@ class FuturesArbitrageScanner {
             'paradex_futures': 'PDX',
             'binance_spot': 'BIN-S',
             'bybit_spot': 'BYB-S',
+            'bitget_futures': 'BGT-F',
+            'bitget_spot': 'BGT-S',
+            'bitmex_futures': 'BMX',
+            'bitfinex_futures': 'BFX-F',
+            'bitfinex_spot': 'BFX-S',
+            'okx_spot': 'OKX-S',
+            'gate_spot': 'GAT-S',
+            'kraken_spot': 'KRK-S',
+            'binance_coinm_futures': 'BIN-CM',
+            'binance_quarterly_futures': 'BIN-Q',
+            'binance_coinm_quarterly_futures': 'BIN-CMQ',
             'pyth': 'PYTH'
         };
         return names[source] || source.substring(0, 3).toUpperCase();
@@ -906,6 +1098,7 @@ class FuturesArbitrageScanner {
         this.updateOpportunitiesTable();
         this.currentSpreads.clear();
         this.updateSpreadsMatrix();
+        this.updateTradeFlow();
         
         document.getElementById('symbolStatus').textContent = newSymbol;
         
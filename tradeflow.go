@@ -0,0 +1,128 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"futures-arbitrage-scanner/exchanges"
+)
+
+// tradeFlowWindow is the span trade flow is summed over, kept as one bucket
+// per second so old trades roll off smoothly
+const tradeFlowWindow = 60
+
+// TradeFlowStats summarizes recent trades of one symbol on one source. Volumes
+// are in base units and notionals in the quote currency, so they compare
+// across venues regardless of contract sizes.
+type TradeFlowStats struct {
+	Trades       int     `json:"trades"`
+	BuyVolume    float64 `json:"buy_volume"`
+	SellVolume   float64 `json:"sell_volume"`
+	BuyNotional  float64 `json:"buy_notional"`
+	SellNotional float64 `json:"sell_notional"`
+	// VWAP is the volume weighted average price over the window
+	VWAP float64 `json:"vwap"`
+	// Imbalance is (buy - sell) / (buy + sell) notional, from -1 to 1
+	Imbalance float64 `json:"imbalance"`
+	// Unsized counts trades whose base quantity is unknown (contract size
+	// not loaded); they are left out of the volumes
+	Unsized int `json:"unsized,omitempty"`
+}
+
+type tradeFlowBucket struct {
+	second int64
+	stats  TradeFlowStats
+}
+
+type tradeFlowKey struct {
+	symbol string
+	source string
+}
+
+// TradeFlowTracker keeps a rolling window of trade flow per symbol and source
+type TradeFlowTracker struct {
+	mu      sync.Mutex
+	buckets map[tradeFlowKey]*[tradeFlowWindow]tradeFlowBucket
+}
+
+func NewTradeFlowTracker() *TradeFlowTracker {
+	return &TradeFlowTracker{
+		buckets: make(map[tradeFlowKey]*[tradeFlowWindow]tradeFlowBucket),
+	}
+}
+
+// Add records a trade in the current second's bucket
+func (t *TradeFlowTracker) Add(trade exchanges.TradeData) {
+	second := time.Now().Unix()
+	key := tradeFlowKey{symbol: trade.Symbol, source: trade.Source}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ring := t.buckets[key]
+	if ring == nil {
+		ring = new([tradeFlowWindow]tradeFlowBucket)
+		t.buckets[key] = ring
+	}
+
+	bucket := &ring[second%tradeFlowWindow]
+	if bucket.second != second {
+		*bucket = tradeFlowBucket{second: second}
+	}
+
+	bucket.stats.Trades++
+	if trade.Quantity <= 0 {
+		bucket.stats.Unsized++
+		return
+	}
+	if trade.Side == "buy" {
+		bucket.stats.BuyVolume += trade.Quantity
+		bucket.stats.BuyNotional += trade.Notional
+	} else {
+		bucket.stats.SellVolume += trade.Quantity
+		bucket.stats.SellNotional += trade.Notional
+	}
+}
+
+// Snapshot sums the window for every symbol and source that traded in it
+func (t *TradeFlowTracker) Snapshot() map[string]map[string]TradeFlowStats {
+	oldest := time.Now().Unix() - tradeFlowWindow + 1
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	flow := make(map[string]map[string]TradeFlowStats)
+	for key, ring := range t.buckets {
+		var total TradeFlowStats
+		for _, bucket := range ring {
+			if bucket.second < oldest {
+				continue
+			}
+			total.Trades += bucket.stats.Trades
+			total.Unsized += bucket.stats.Unsized
+			total.BuyVolume += bucket.stats.BuyVolume
+			total.SellVolume += bucket.stats.SellVolume
+			total.BuyNotional += bucket.stats.BuyNotional
+			total.SellNotional += bucket.stats.SellNotional
+		}
+		if total.Trades == 0 {
+			delete(t.buckets, key)
+			continue
+		}
+
+		volume := total.BuyVolume + total.SellVolume
+		notional := total.BuyNotional + total.SellNotional
+		if volume > 0 {
+			total.VWAP = notional / volume
+		}
+		if notional > 0 {
+			total.Imbalance = (total.BuyNotional - total.SellNotional) / notional
+		}
+
+		if flow[key.symbol] == nil {
+			flow[key.symbol] = make(map[string]TradeFlowStats)
+		}
+		flow[key.symbol][key.source] = total
+	}
+	return flow
+}